@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/alex-held/devctl/pkg/cli"
 	"github.com/alex-held/devctl/pkg/cli/util"
 )
 
 func main() {
+	// cancel running commands and plugins on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	cmd := cli.NewDefaultKubectlCommand()
-	util.CheckErr(cmd.Execute())
+	util.CheckErr(cmd.ExecuteContext(ctx))
 }
@@ -0,0 +1,309 @@
+// Package backup saves the state of devctl to an archive and restores it, e.g. on a new machine.
+//
+// A backup contains the index definitions, the install receipts, the configuration files and the selected sdk versions,
+// but no binaries. Restoring a backup adds the indexes and installs the plugins through the normal install paths.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alex-held/devctl/internal/git"
+	"github.com/alex-held/devctl/pkg/constants"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/scanner"
+	"github.com/alex-held/devctl/pkg/index/spec"
+	"github.com/alex-held/devctl/pkg/index/validate"
+	"github.com/alex-held/devctl/pkg/profile"
+	"github.com/alex-held/devctl/pkg/version"
+)
+
+// FormatVersion is the version of the archive format written by Write
+const FormatVersion = 1
+
+// Names of the entries in a backup archive
+const (
+	manifestEntry = "manifest.yaml"
+	receiptsEntry = "receipts"
+	configEntry   = "config"
+)
+
+// ConfigFiles are the configuration files included in a backup, relative to the config root of devctl
+var ConfigFiles = []string{
+	"config.yaml",
+	"config/zsh/config.yaml",
+	"config/bash/config.yaml",
+}
+
+// Manifest describes the content of a backup
+type Manifest struct {
+	FormatVersion int       `json:"formatVersion"`
+	Created       time.Time `json:"created"`
+	DevctlVersion string    `json:"devctlVersion"`
+	Indexes       []Index   `json:"indexes,omitempty"`
+	// SDKs are the current versions of the sdks
+	SDKs map[string]string `json:"sdks,omitempty"`
+}
+
+// Index is the definition of an index
+type Index struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Ref is the commit the index was checked out at, restored indexes are pinned to it
+	Ref string `json:"ref,omitempty"`
+}
+
+// Backup is the content of a backup archive
+type Backup struct {
+	Manifest Manifest
+	Receipts []spec.Receipt
+	// Files are the contents of the ConfigFiles by their path
+	Files map[string][]byte
+}
+
+// Collect reads the state of f into a Backup
+func Collect(f env.Factory) (*Backup, error) {
+	b := &Backup{
+		Manifest: Manifest{
+			FormatVersion: FormatVersion,
+			Created:       time.Now().UTC(),
+			DevctlVersion: version.Get().Version,
+			SDKs:          map[string]string{},
+		},
+		Files: map[string][]byte{},
+	}
+
+	indexes, err := scanner.ListIndexes(f.Paths())
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return nil, errors.Wrap(err, "failed to list the indexes")
+	}
+	for _, idx := range indexes {
+		ref, err := git.Exec(f.Paths().IndexPath(idx.Name), "rev-parse", "HEAD")
+		if err != nil {
+			f.Logger().Warnf("failed to look up the commit of index %q, it won't be pinned: %v", idx.Name, err)
+		}
+		b.Manifest.Indexes = append(b.Manifest.Indexes, Index{Name: idx.Name, URL: strings.TrimSpace(idx.URL), Ref: ref})
+	}
+
+	if _, err := os.Stat(f.Paths().InstallReceiptsPath()); err == nil {
+		if b.Receipts, err = installation.GetInstalledPluginReceipts(f); err != nil {
+			return nil, err
+		}
+		sort.Slice(b.Receipts, func(i, j int) bool { return b.Receipts[i].Name < b.Receipts[j].Name })
+	}
+
+	for _, name := range ConfigFiles {
+		content, err := ioutil.ReadFile(f.Pather().ConfigRoot(filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", name)
+		}
+		b.Files[name] = content
+	}
+
+	sdks, err := ioutil.ReadDir(f.Pather().SDK())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to list the sdks")
+	}
+	for _, sdk := range sdks {
+		if v := profile.CurrentSDKVersion(f.Pather(), sdk.Name()); v != "" {
+			b.Manifest.SDKs[sdk.Name()] = v
+		}
+	}
+	return b, nil
+}
+
+// Write writes b as gzipped tar archive to w
+func (b *Backup) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest, err := yaml.Marshal(b.Manifest)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the manifest")
+	}
+	if err = writeEntry(tw, manifestEntry, manifest); err != nil {
+		return err
+	}
+	for _, r := range b.Receipts {
+		content, err := yaml.Marshal(r)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal the receipt of plugin %q", r.Name)
+		}
+		if err = writeEntry(tw, path.Join(receiptsEntry, r.Name+constants.ManifestExtension), content); err != nil {
+			return err
+		}
+	}
+	for _, name := range ConfigFiles {
+		if content, ok := b.Files[name]; ok {
+			if err = writeEntry(tw, path.Join(configEntry, name), content); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return errors.Wrap(err, "failed to write the archive")
+	}
+	return errors.Wrap(gz.Close(), "failed to compress the archive")
+}
+
+func writeEntry(tw *tar.Writer, name string, content []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to write %s", name)
+	}
+	_, err := tw.Write(content)
+	return errors.Wrapf(err, "failed to write %s", name)
+}
+
+// Save writes b to the file at path. The file is replaced atomically and only readable by the user,
+// because the configuration may contain secrets.
+func (b *Backup) Save(path string) error {
+	buf := &bytes.Buffer{}
+	if err := b.Write(buf); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return errors.Wrapf(err, "failed to write the backup %s", path)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrapf(err, "failed to write the backup %s", path)
+	}
+	return nil
+}
+
+// Read reads a backup archive written by Write. Entries unknown to this version of devctl are ignored.
+func Read(r io.Reader) (*Backup, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "not a gzipped backup archive")
+	}
+	defer gz.Close()
+
+	b := &Backup{Files: map[string][]byte{}}
+	hasManifest := false
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the backup archive")
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", hdr.Name)
+		}
+
+		name := path.Clean(hdr.Name)
+		switch {
+		case name == manifestEntry:
+			if err := yaml.Unmarshal(content, &b.Manifest); err != nil {
+				return nil, errors.Wrap(err, "failed to parse the manifest")
+			}
+			hasManifest = true
+		case path.Dir(name) == receiptsEntry:
+			var receipt spec.Receipt
+			if err := yaml.Unmarshal(content, &receipt); err != nil {
+				return nil, errors.Wrapf(err, "failed to parse the receipt %s", name)
+			}
+			b.Receipts = append(b.Receipts, receipt)
+		case strings.HasPrefix(name, configEntry+"/") && isConfigFile(strings.TrimPrefix(name, configEntry+"/")):
+			b.Files[strings.TrimPrefix(name, configEntry+"/")] = content
+		}
+	}
+
+	if !hasManifest {
+		return nil, errors.New("not a devctl backup, the archive has no manifest")
+	}
+	if b.Manifest.FormatVersion > FormatVersion {
+		return nil, errors.Errorf("the backup has format version %d, this devctl supports up to %d, run 'devctl self-update'", b.Manifest.FormatVersion, FormatVersion)
+	}
+	if err := b.validateNames(); err != nil {
+		return nil, err
+	}
+	sort.Slice(b.Receipts, func(i, j int) bool { return b.Receipts[i].Name < b.Receipts[j].Name })
+	return b, nil
+}
+
+// validateNames rejects the names of indexes, plugins and sdks, which are not valid names of their kind.
+// The names are used as paths while restoring, so a crafted backup could otherwise reach outside of the devctl root.
+func (b *Backup) validateNames() error {
+	for _, idx := range b.Manifest.Indexes {
+		if !scanner.IsValidIndexName(idx.Name) {
+			return errors.Errorf("the backup contains the invalid index name %q", idx.Name)
+		}
+	}
+	for _, r := range b.Receipts {
+		if !validate.IsSafePluginName(r.Name) {
+			return errors.Errorf("the backup contains the invalid plugin name %q", r.Name)
+		}
+		if !scanner.IsValidIndexName(indexOf(r)) {
+			return errors.Errorf("the backup contains plugin %q of the invalid index name %q", r.Name, indexOf(r))
+		}
+	}
+	for sdk, v := range b.Manifest.SDKs {
+		if !validate.IsSafePluginName(sdk) || !isPathElement(v) {
+			return errors.Errorf("the backup contains the invalid sdk version %s=%s", sdk, v)
+		}
+	}
+	return nil
+}
+
+// isPathElement returns true, if name is a single element of a path
+func isPathElement(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// Open reads the backup archive at path
+func Open(path string) (*Backup, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the backup")
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+func isConfigFile(name string) bool {
+	for _, f := range ConfigFiles {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// indexOf returns the index a receipt has been installed from
+func indexOf(r spec.Receipt) string {
+	if r.Status.Source.Name == "" {
+		return constants.DefaultIndexName
+	}
+	return r.Status.Source.Name
+}
@@ -0,0 +1,211 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/spec"
+	"github.com/alex-held/devctl/pkg/profile"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=devctl", "-c", "user.email=devctl@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return string(out)
+}
+
+// remote creates an index repository with two commits and returns its path and first commit
+func remote(t *testing.T, root string) (string, string) {
+	dir := filepath.Join(root, "remote")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "plugins"), 0755))
+	runGit(t, dir, "init", "-q")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "plugins", "README"), []byte("v1"), 0644))
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "v1")
+	first := runGit(t, dir, "rev-parse", "HEAD")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "plugins", "README"), []byte("v2"), 0644))
+	runGit(t, dir, "commit", "-q", "-am", "v2")
+	return dir, first[:len(first)-1]
+}
+
+func write(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmp, err := ioutil.TempDir("", "devctl-backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+	url, first := remote(t, tmp)
+
+	// the state of the old machine
+	f := env.NewFactory(env.WithPaths(filepath.Join(tmp, "old")))
+	runGit(t, tmp, "clone", "-q", url, f.Paths().IndexPath("acme"))
+	runGit(t, f.Paths().IndexPath("acme"), "reset", "-q", "--hard", first)
+	require.NoError(t, os.MkdirAll(f.Paths().InstallReceiptsPath(), 0755))
+	receipt := spec.Receipt{Status: spec.ReceiptStatus{Source: spec.SourceIndex{Name: "acme"}}}
+	receipt.Name, receipt.Spec.Version = "sdkman", "v1.0.0"
+	require.NoError(t, installation.Store(f.Fs(), receipt, f.Paths().PluginInstallReceiptPath("sdkman")))
+	write(t, f.Pather().ConfigFilePath(), "currentProfile: acme\n")
+	write(t, f.Pather().Config("zsh", "config.yaml"), "aliases: {}\n")
+	require.NoError(t, os.MkdirAll(f.Pather().SDK("go", "1.16.5"), 0755))
+	require.NoError(t, profile.LinkSDK(f.Pather(), "go", "1.16.5"))
+
+	b, err := Collect(f)
+	require.NoError(t, err)
+	archive := filepath.Join(tmp, "backup.tar.gz")
+	require.NoError(t, b.Save(archive))
+	fi, err := os.Stat(archive)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	b, err = Open(archive)
+	require.NoError(t, err)
+	assert.Equal(t, []Index{{Name: "acme", URL: url, Ref: first}}, b.Manifest.Indexes)
+	require.Len(t, b.Receipts, 1)
+	assert.Equal(t, "sdkman", b.Receipts[0].Name)
+	assert.Equal(t, "acme", b.Receipts[0].Status.Source.Name)
+	assert.Equal(t, map[string]string{"go": "1.16.5"}, b.Manifest.SDKs)
+	assert.Equal(t, map[string][]byte{
+		"config.yaml":            []byte("currentProfile: acme\n"),
+		"config/zsh/config.yaml": []byte("aliases: {}\n"),
+	}, b.Files)
+
+	// the new machine has a different configuration and go 1.16.5 installed
+	f = env.NewFactory(env.WithPaths(filepath.Join(tmp, "new")))
+	write(t, f.Pather().ConfigFilePath(), "currentProfile: default\n")
+	require.NoError(t, os.MkdirAll(f.Pather().SDK("go", "1.16.5"), 0755))
+
+	actions := func(p *Plan) map[string]Action {
+		m := map[string]Action{}
+		for _, s := range p.Steps {
+			m[string(s.Kind)+"/"+s.Name] = s.Action
+		}
+		return m
+	}
+
+	plan, err := PlanRestore(f, b, StrategyFail)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Action{
+		"index/acme":                    ActionCreate,
+		"config/config.yaml":            ActionConflict,
+		"config/config/zsh/config.yaml": ActionCreate,
+		"plugin/sdkman":                 ActionCreate,
+		"sdk/go":                        ActionCreate,
+	}, actions(plan))
+	assert.Len(t, plan.Conflicts(), 1)
+	assert.Error(t, plan.Apply(f), "conflicts are not applied")
+	assert.NoDirExists(t, f.Paths().IndexPath("acme"))
+
+	plan, err = PlanRestore(f, b, StrategySkip)
+	require.NoError(t, err)
+	assert.Equal(t, ActionSkip, actions(plan)["config/config.yaml"])
+
+	// the plugin is installed through the normal install path, which needs a plugin manifest in the index
+	b.Receipts = nil
+	plan, err = PlanRestore(f, b, StrategyOverwrite)
+	require.NoError(t, err)
+	assert.Equal(t, ActionOverwrite, actions(plan)["config/config.yaml"])
+	require.NoError(t, plan.Apply(f))
+
+	assert.Equal(t, first+"\n", runGit(t, f.Paths().IndexPath("acme"), "rev-parse", "HEAD"), "the index is pinned to the backed up commit")
+	content, err := ioutil.ReadFile(f.Pather().ConfigFilePath())
+	require.NoError(t, err)
+	assert.Equal(t, "currentProfile: acme\n", string(content))
+	assert.FileExists(t, f.Pather().Config("zsh", "config.yaml"))
+	assert.Equal(t, "1.16.5", profile.CurrentSDKVersion(f.Pather(), "go"))
+
+	plan, err = PlanRestore(f, b, StrategyFail)
+	require.NoError(t, err)
+	for _, s := range plan.Steps {
+		assert.Equal(t, ActionSkip, s.Action, "%s %s is restored already", s.Kind, s.Name)
+	}
+}
+
+// archive writes the manifest and receipts to a backup archive. Unlike Write, the receipts are stored
+// under a valid entry name regardless of their name.
+func archive(t *testing.T, m Manifest, receipts ...spec.Receipt) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	content, err := yaml.Marshal(m)
+	require.NoError(t, err)
+	require.NoError(t, writeEntry(tw, manifestEntry, content))
+	for _, r := range receipts {
+		content, err := yaml.Marshal(r)
+		require.NoError(t, err)
+		require.NoError(t, writeEntry(tw, receiptsEntry+"/receipt.yaml", content))
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf
+}
+
+func TestRead_InvalidNames(t *testing.T) {
+	traversal := spec.Receipt{}
+	traversal.Name = "../../.."
+	foreignIndex := spec.Receipt{Status: spec.ReceiptStatus{Source: spec.SourceIndex{Name: "../../.."}}}
+	foreignIndex.Name = "sdkman"
+
+	tests := []struct {
+		name     string
+		manifest Manifest
+		receipts []spec.Receipt
+	}{
+		{name: "index", manifest: Manifest{Indexes: []Index{{Name: "../../..", URL: "https://example.com/index.git"}}}},
+		{name: "plugin", receipts: []spec.Receipt{traversal}},
+		{name: "index of plugin", receipts: []spec.Receipt{foreignIndex}},
+		{name: "sdk", manifest: Manifest{SDKs: map[string]string{"../go": "1.16"}}},
+		{name: "sdk version", manifest: Manifest{SDKs: map[string]string{"go": ".."}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(archive(t, tt.manifest, tt.receipts...))
+			assert.Error(t, err)
+
+			tmp, err := ioutil.TempDir("", "devctl-backup")
+			require.NoError(t, err)
+			defer os.RemoveAll(tmp)
+			_, err = PlanRestore(env.NewFactory(env.WithPaths(tmp)), &Backup{Manifest: tt.manifest, Receipts: tt.receipts}, StrategyOverwrite)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestWriteFileAtomic_Mode(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "devctl-backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	created := filepath.Join(tmp, "created")
+	require.NoError(t, writeFileAtomic(created, []byte("token: secret")))
+	fi, err := os.Stat(created)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	existing := filepath.Join(tmp, "existing")
+	require.NoError(t, ioutil.WriteFile(existing, []byte("old"), 0640))
+	require.NoError(t, os.Chmod(existing, 0640))
+	require.NoError(t, writeFileAtomic(existing, []byte("new")))
+	fi, err = os.Stat(existing)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+}
@@ -0,0 +1,316 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/alex-held/devctl/internal/git"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/history"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/scanner"
+	"github.com/alex-held/devctl/pkg/index/spec"
+	"github.com/alex-held/devctl/pkg/profile"
+)
+
+// Strategy decides how a restore handles state, which exists already and differs from the backup
+type Strategy string
+
+const (
+	// StrategyFail refuses to restore anything, if there are conflicts
+	StrategyFail Strategy = "fail"
+	// StrategySkip keeps the existing state
+	StrategySkip Strategy = "skip"
+	// StrategyOverwrite replaces the existing state with the backup
+	StrategyOverwrite Strategy = "overwrite"
+)
+
+// Validate checks the strategy
+func (s Strategy) Validate() error {
+	switch s {
+	case StrategyFail, StrategySkip, StrategyOverwrite:
+		return nil
+	default:
+		return errors.Errorf("unknown conflict strategy %q, use fail, skip or overwrite", s)
+	}
+}
+
+// Kind is the kind of state restored by a Step
+type Kind string
+
+const (
+	KindIndex  Kind = "index"
+	KindConfig Kind = "config"
+	KindPlugin Kind = "plugin"
+	KindSDK    Kind = "sdk"
+)
+
+// Action is what a Step does
+type Action string
+
+const (
+	ActionCreate    Action = "create"
+	ActionOverwrite Action = "overwrite"
+	ActionSkip      Action = "skip"
+	// ActionConflict marks a Step, whose existing state differs from the backup, if the Strategy is StrategyFail
+	ActionConflict Action = "conflict"
+)
+
+// Step restores a single index, configuration file, plugin or sdk
+type Step struct {
+	Kind   Kind
+	Name   string
+	Action Action
+	Detail string
+
+	index   Index
+	content []byte
+	receipt spec.Receipt
+	version string
+}
+
+// Plan are the steps restoring a backup, in the order they are applied
+type Plan struct {
+	Steps []Step
+}
+
+// Conflicts returns the steps, which are in conflict with the existing state
+func (p *Plan) Conflicts() (conflicts []Step) {
+	for _, s := range p.Steps {
+		if s.Action == ActionConflict {
+			conflicts = append(conflicts, s)
+		}
+	}
+	return conflicts
+}
+
+// PlanRestore compares b to the state of f and plans the steps restoring it.
+// The indexes are restored first, followed by the configuration files, the plugins and the sdk versions.
+func PlanRestore(f env.Factory, b *Backup, strategy Strategy) (*Plan, error) {
+	if err := strategy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := b.validateNames(); err != nil {
+		return nil, err
+	}
+	p := &Plan{}
+
+	for _, idx := range b.Manifest.Indexes {
+		s := Step{Kind: KindIndex, Name: idx.Name, index: idx, Detail: idx.URL + pinnedTo(idx.Ref)}
+		dir := f.Paths().IndexPath(idx.Name)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			s.Action = ActionCreate
+		} else if url, err := git.GetRemoteURL(dir); err == nil && strings.TrimSpace(url) == idx.URL {
+			s.Action, s.Detail = ActionSkip, "exists already"
+		} else {
+			s.Detail = fmt.Sprintf("exists with the URL %q instead of %q", strings.TrimSpace(url), idx.URL)
+			s.Action = conflict(strategy)
+		}
+		p.Steps = append(p.Steps, s)
+	}
+
+	for _, name := range ConfigFiles {
+		content, ok := b.Files[name]
+		if !ok {
+			continue
+		}
+		s := Step{Kind: KindConfig, Name: name, content: content}
+		existing, err := ioutil.ReadFile(f.Pather().ConfigRoot(filepath.FromSlash(name)))
+		switch {
+		case os.IsNotExist(err):
+			s.Action = ActionCreate
+		case err != nil:
+			return nil, errors.Wrapf(err, "failed to read %s", name)
+		case bytes.Equal(existing, s.content):
+			s.Action, s.Detail = ActionSkip, "is up to date"
+		default:
+			s.Action, s.Detail = conflict(strategy), "differs from the backup"
+		}
+		p.Steps = append(p.Steps, s)
+	}
+
+	for _, r := range b.Receipts {
+		s := Step{Kind: KindPlugin, Name: r.Name, receipt: r, Detail: fmt.Sprintf("%s from index %s", r.Spec.Version, indexOf(r))}
+		installed, err := installation.Load(f.Fs(), f.Paths().PluginInstallReceiptPath(r.Name))
+		switch {
+		case indexOf(r) == "detached":
+			s.Action, s.Detail = ActionSkip, "has been installed from a local manifest, install it again using 'devctl plugin install --manifest'"
+		case os.IsNotExist(err):
+			s.Action = ActionCreate
+		case err != nil:
+			return nil, errors.Wrapf(err, "failed to look up install receipt for plugin %q", r.Name)
+		case installed.Spec.Version == r.Spec.Version && indexOf(installed) == indexOf(r):
+			s.Action, s.Detail = ActionSkip, "is installed already"
+		default:
+			s.Action = conflict(strategy)
+			s.Detail = fmt.Sprintf("%s from index %s is installed instead of %s", installed.Spec.Version, indexOf(installed), s.Detail)
+		}
+		p.Steps = append(p.Steps, s)
+	}
+
+	for _, sdk := range sortedKeys(b.Manifest.SDKs) {
+		v := b.Manifest.SDKs[sdk]
+		s := Step{Kind: KindSDK, Name: sdk, version: v, Detail: v}
+		current := profile.CurrentSDKVersion(f.Pather(), sdk)
+		switch {
+		case current == v:
+			s.Action, s.Detail = ActionSkip, v+" is current already"
+		case !isDir(f.Pather().SDK(sdk, v)):
+			s.Action, s.Detail = ActionSkip, v+" is not installed, install it using the sdk plugin"
+		case current == "":
+			s.Action = ActionCreate
+		default:
+			s.Action, s.Detail = conflict(strategy), fmt.Sprintf("%s is current instead of %s", current, v)
+		}
+		p.Steps = append(p.Steps, s)
+	}
+	return p, nil
+}
+
+func conflict(strategy Strategy) Action {
+	switch strategy {
+	case StrategySkip:
+		return ActionSkip
+	case StrategyOverwrite:
+		return ActionOverwrite
+	default:
+		return ActionConflict
+	}
+}
+
+// Apply applies the steps of p, which create or overwrite state. A failed step doesn't stop the restore,
+// the failures are reported together.
+func (p *Plan) Apply(f env.Factory) error {
+	switch conflicts := p.Conflicts(); len(conflicts) {
+	case 0:
+	case 1:
+		return errors.Errorf("%s %q conflicts with the existing state, choose how to resolve it using --on-conflict skip or overwrite", conflicts[0].Kind, conflicts[0].Name)
+	default:
+		return errors.Errorf("%d conflicts with the existing state, choose how to resolve them using --on-conflict skip or overwrite", len(conflicts))
+	}
+
+	var failed []string
+	for _, s := range p.Steps {
+		if s.Action == ActionSkip {
+			continue
+		}
+		if err := s.apply(f); err != nil {
+			f.Logger().Warnf("failed to restore %s %q: %v", s.Kind, s.Name, err)
+			failed = append(failed, fmt.Sprintf("%s %s", s.Kind, s.Name))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed to restore %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (s Step) apply(f env.Factory) error {
+	switch s.Kind {
+	case KindIndex:
+		return restoreIndex(f, s.index, s.Action == ActionOverwrite)
+	case KindConfig:
+		return writeFileAtomic(f.Pather().ConfigRoot(filepath.FromSlash(s.Name)), s.content)
+	case KindPlugin:
+		return restorePlugin(f, s.receipt, s.Action == ActionOverwrite)
+	case KindSDK:
+		before := profile.CurrentSDKVersion(f.Pather(), s.Name)
+		err := profile.LinkSDK(f.Pather(), s.Name, s.version)
+		history.Record(f, history.Entry{Action: history.ActionSDKUse, SDK: s.Name, Before: before, After: s.version}, err)
+		return err
+	default:
+		return errors.Errorf("unknown kind %q", s.Kind)
+	}
+}
+
+// restoreIndex adds the index and pins it to the commit of the backup, so that the same plugin versions get installed
+func restoreIndex(f env.Factory, idx Index, overwrite bool) error {
+	if overwrite {
+		before := history.IndexCommit(f.Paths(), idx.Name)
+		err := scanner.DeleteIndex(f.Paths(), idx.Name)
+		history.Record(f, history.Entry{Action: history.ActionIndexRemove, Index: idx.Name, Before: before}, err)
+		if err != nil {
+			return errors.Wrap(err, "failed to remove the existing index")
+		}
+	}
+
+	err := scanner.AddIndex(f.Paths(), idx.Name, idx.URL)
+	if err == nil && idx.Ref != "" {
+		if _, resetErr := git.Exec(f.Paths().IndexPath(idx.Name), "reset", "--hard", idx.Ref); resetErr != nil {
+			f.Logger().Warnf("failed to pin index %q to %s, it stays at its latest commit: %v", idx.Name, idx.Ref, resetErr)
+		}
+	}
+	history.Record(f, history.Entry{Action: history.ActionIndexAdd, Index: idx.Name, URL: idx.URL, After: history.IndexCommit(f.Paths(), idx.Name)}, err)
+	return err
+}
+
+// restorePlugin installs the plugin of r from its index
+func restorePlugin(f env.Factory, r spec.Receipt, overwrite bool) error {
+	indexName := indexOf(r)
+	plugin, err := scanner.LoadPluginByName(f, f.Paths().IndexPluginsPath(indexName), r.Name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find plugin %q in index %q", r.Name, indexName)
+	}
+	if plugin.Spec.Version != r.Spec.Version {
+		f.Logger().Warnf("index %q offers %s of plugin %q instead of the backed up %s", indexName, plugin.Spec.Version, r.Name, r.Spec.Version)
+	}
+
+	if overwrite {
+		if err := installation.Uninstall(f, r.Name); err != nil && err != installation.ErrIsNotInstalled {
+			return errors.Wrap(err, "failed to uninstall the installed version")
+		}
+	}
+	if err := os.MkdirAll(f.Paths().InstallReceiptsPath(), 0755); err != nil {
+		return errors.Wrap(err, "failed to create the receipts directory")
+	}
+	return installation.Install(f, plugin, indexName, installation.InstallOpts{})
+}
+
+// writeFileAtomic replaces the file, or the link, at path with content.
+// An existing file keeps its mode, new files are only readable by the user, as the configuration may contain secrets.
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	mode := os.FileMode(0600)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := ioutil.WriteFile(tmp, content, mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func pinnedTo(ref string) string {
+	if len(ref) < 7 {
+		return ""
+	}
+	return " at " + ref[:7]
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,59 @@
+package backup
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/backup"
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// Options are the options of 'devctl backup'
+type Options struct {
+	Output string
+}
+
+// NewCmd creates the 'devctl backup' command
+func NewCmd(f env.Factory) *cobra.Command {
+	o := &Options{}
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "save the devctl state to an archive",
+		Long: `Save the devctl state to a gzipped tar archive, e.g. to move to a new machine.
+The archive contains the indexes with their URLs and commits, the install receipts of the plugins,
+the devctl configuration, the zsh and bash config.yaml and the current sdk versions.
+It contains no binaries, 'devctl restore' installs the plugins again.
+Examples:
+  To back up the devctl state:
+    devctl backup -o devctl-backup.tar.gz
+  To restore it on a new machine:
+    devctl restore devctl-backup.tar.gz`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return o.Run(f)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "The archive to write (defaults to devctl-backup-DATE.tar.gz)")
+	return cmd
+}
+
+// Run writes the backup of f to o.Output
+func (o *Options) Run(f env.Factory) error {
+	if o.Output == "" {
+		o.Output = fmt.Sprintf("devctl-backup-%s.tar.gz", time.Now().Format("2006-01-02"))
+	}
+
+	b, err := backup.Collect(f)
+	if err != nil {
+		return err
+	}
+	if err = b.Save(o.Output); err != nil {
+		return err
+	}
+	fmt.Fprintf(f.Streams().Out, "Saved %d indexes, %d plugins, %d sdk versions and %d configuration files to %s.\n",
+		len(b.Manifest.Indexes), len(b.Receipts), len(b.Manifest.SDKs), len(b.Files), o.Output)
+	return nil
+}
@@ -0,0 +1,76 @@
+package completion
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/cli/options"
+	"github.com/alex-held/devctl/pkg/cli/util"
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+var shells = []string{"bash", "fish", "zsh"}
+
+type CompletionOptions struct {
+	options.IOStreams
+
+	Shell string
+}
+
+func NewOptions(streams options.IOStreams) *CompletionOptions {
+	return &CompletionOptions{
+		IOStreams: streams,
+	}
+}
+
+// ValidateArgs makes sure exactly one supported shell is provided
+func (o *CompletionOptions) ValidateArgs(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return util.UsageErrorf(cmd, "exactly one shell is required, one of %v", shells)
+	}
+	o.Shell = args[0]
+	return nil
+}
+
+// Run writes the completion script of the shell to Out
+func (o *CompletionOptions) Run(f env.Factory, cmd *cobra.Command) error {
+	root := cmd.Root()
+	switch o.Shell {
+	case "bash":
+		return root.GenBashCompletionV2(o.Out, true)
+	case "fish":
+		return root.GenFishCompletion(o.Out, true)
+	case "zsh":
+		return root.GenZshCompletion(o.Out)
+	default:
+		return util.UsageErrorf(cmd, "unsupported shell %q, must be one of %v", o.Shell, shells)
+	}
+}
+
+func NewCmd(f env.Factory) (cmd *cobra.Command) {
+	o := NewOptions(f.Streams())
+
+	cmd = &cobra.Command{
+		Use:                   "completion SHELL",
+		DisableFlagsInUseLine: true,
+		Short:                 "prints the shell completion script of devctl",
+		Long: fmt.Sprintf(`prints the shell completion script of devctl for one of %v.
+External devctl-* plugins found on PATH are completed as subcommands.`, shells),
+		Example: `
+		To load completions in the current zsh session:
+			source <(devctl completion zsh)
+		To load completions for every new bash session:
+			devctl completion bash > /etc/bash_completion.d/devctl
+		To load completions for every new fish session:
+			devctl completion fish > ~/.config/fish/completions/devctl.fish
+		`,
+		ValidArgs: shells,
+		Run: func(cmd *cobra.Command, args []string) {
+			util.CheckErr(o.ValidateArgs(cmd, args))
+			util.CheckErr(o.Run(f, cmd))
+		},
+	}
+
+	return cmd
+}
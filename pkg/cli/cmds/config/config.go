@@ -0,0 +1,232 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/alex-held/devctl/pkg/cli/util"
+	devctlconfig "github.com/alex-held/devctl/pkg/config"
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// NewCmd creates the 'devctl config' command
+func NewCmd(f env.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "view and modify the devctl configuration",
+		Long: `View and modify the devctl configuration file, usually ~/.devctl/config.yaml.
+Keys are dotted paths into the file. Elements of lists are selected by their index or their name.
+Examples:
+  To show whether the golang plugin is enabled:
+    devctl config get plugins.golang.enabled
+  To set a var, which can be used in the plugin entries as {{ .GOPATH }}:
+    devctl config set vars.GOPATH ~/go`,
+		Run: util.DefaultSubCommandRun(f.Streams().ErrOut),
+	}
+
+	cmd.AddCommand(newViewCmd(f))
+	cmd.AddCommand(newGetCmd(f))
+	cmd.AddCommand(newSetCmd(f))
+	cmd.AddCommand(newUnsetCmd(f))
+	cmd.AddCommand(newEditCmd(f))
+	return cmd
+}
+
+func newViewCmd(f env.Factory) *cobra.Command {
+	resolved := false
+	cmd := &cobra.Command{
+		Use:          "view",
+		Short:        "print the configuration",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			file, err := f.Config()
+			if err != nil {
+				return err
+			}
+			if resolved {
+				return printYaml(f, file.Config)
+			}
+			b, err := file.Bytes()
+			if err != nil {
+				return err
+			}
+			_, err = f.Streams().Out.Write(b)
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&resolved, "resolved", resolved, "Print the configuration with the vars templated into it")
+	return cmd
+}
+
+func newGetCmd(f env.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:          "get KEY",
+		Short:        "print the value of a key",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := f.Config()
+			if err != nil {
+				return err
+			}
+			value, err := file.Get(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(f.Streams().Out, value)
+			return nil
+		},
+	}
+}
+
+func newSetCmd(f env.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:          "set KEY VALUE",
+		Short:        "set the value of a key",
+		Long:         "Set the value of a key. The value is parsed as yaml, so 'true' is a boolean and '[a, b]' a list.",
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := f.RawConfig()
+			if err != nil {
+				return err
+			}
+			if err = checkLocationKey(file.Path, args[0]); err != nil {
+				return err
+			}
+			if err = file.Set(args[0], args[1]); err != nil {
+				return err
+			}
+			return file.Save()
+		},
+	}
+}
+
+// checkLocationKey rejects setting 'root' or 'layout' in a configuration file, which isn't read to resolve the devctl directories
+func checkLocationKey(path, key string) error {
+	switch strings.TrimPrefix(key, ".") {
+	case "root", "layout":
+	default:
+		return nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return errors.Wrap(err, "cannot get user home dir")
+	}
+	files := env.LocationFiles(home)
+	for _, file := range files {
+		if file == path {
+			return nil
+		}
+	}
+	return errors.Errorf("%s is ignored in %s, it is only read from %s; use --devctl-path, $DEVCTL_ROOT or $DEVCTL_LAYOUT instead", key, path, strings.Join(files, " or "))
+}
+
+func newUnsetCmd(f env.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:          "unset KEY",
+		Short:        "remove a key",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := f.RawConfig()
+			if err != nil {
+				return err
+			}
+			if err = file.Unset(args[0]); err != nil {
+				return err
+			}
+			return file.Save()
+		},
+	}
+}
+
+func newEditCmd(f env.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:   "edit",
+		Short: "edit the configuration using $EDITOR",
+		Long: `Edit the configuration using the editor defined by $DEVCTL_EDITOR or $EDITOR, falling back to vi.
+The configuration is only saved, if it is valid. Otherwise the edited file is kept and its path is printed.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			file, err := f.RawConfig()
+			if err != nil {
+				return err
+			}
+			return edit(f, file)
+		},
+	}
+}
+
+func edit(f env.Factory, file *devctlconfig.File) error {
+	original, err := file.Bytes()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile("", "devctl-config-*.yaml")
+	if err != nil {
+		return err
+	}
+	keep := false
+	defer func() {
+		if !keep {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(original); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	editor := strings.Fields(editorCommand())
+	c := exec.Command(editor[0], append(editor[1:], tmp.Name())...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, f.Streams().Out, f.Streams().ErrOut
+	if err = c.Run(); err != nil {
+		return errors.Wrapf(err, "editor %q failed", editor[0])
+	}
+
+	edited, err := ioutil.ReadFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if string(edited) == string(original) {
+		fmt.Fprintln(f.Streams().Out, "Edit cancelled, no changes made.")
+		return nil
+	}
+	if err = file.Replace(edited); err != nil {
+		// don't throw away the changes
+		keep = true
+		return errors.Wrapf(err, "the configuration has not been saved, the edited file is kept at %s", tmp.Name())
+	}
+	return file.Save()
+}
+
+func editorCommand() string {
+	for _, key := range []string{"DEVCTL_EDITOR", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(key)); editor != "" {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+func printYaml(f env.Factory, v interface{}) error {
+	enc := yaml.NewEncoder(f.Streams().Out)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,117 @@
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/doctor"
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// Options are the options of 'devctl doctor'
+type Options struct {
+	Fix bool
+	// Checks are the names of the checks to run, all checks are run if it is empty
+	Checks []string
+}
+
+// NewCmd creates the 'devctl doctor' command
+func NewCmd(f env.Factory) *cobra.Command {
+	o := &Options{}
+	d := doctor.Default()
+
+	var names []string
+	for _, c := range d.Checks() {
+		names = append(names, fmt.Sprintf("  %-10s %s", c.Name, c.Description))
+	}
+	cmd := &cobra.Command{
+		Use:   "doctor [CHECK...]",
+		Short: "check the health of the devctl installation",
+		Long: `Check the health of the devctl installation and print a hint for every problem found.
+Problems, which can be repaired without losing data, are repaired using --fix.
+Exits with a non-zero code, if any check failed.
+Checks:
+` + strings.Join(names, "\n"),
+		SilenceUsage: true,
+		ValidArgsFunction: func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			var checks []string
+			for _, c := range d.Checks() {
+				checks = append(checks, c.Name+"\t"+c.Description)
+			}
+			return checks, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.Checks = args
+			return o.Run(f, d)
+		},
+	}
+	cmd.Flags().BoolVar(&o.Fix, "fix", o.Fix, "If true, repair the problems which can be repaired safely")
+	return cmd
+}
+
+// Run runs the checks of d and prints their reports
+func (o *Options) Run(f env.Factory, d *doctor.Doctor) error {
+	if len(o.Checks) > 0 {
+		selected := doctor.New()
+		for _, name := range o.Checks {
+			found := false
+			for _, c := range d.Checks() {
+				if c.Name == name {
+					selected.Register(c)
+					found = true
+				}
+			}
+			if !found {
+				return errors.Errorf("unknown check %q", name)
+			}
+		}
+		d = selected
+	}
+
+	out := f.Streams().Out
+	failures, warnings, fixed := 0, 0, 0
+	for _, r := range d.Run(f, o.Fix) {
+		status := string(r.Status)
+		switch {
+		case r.Fixed:
+			status = "fixed"
+			fixed++
+		case r.Status == doctor.StatusFail:
+			failures++
+		case r.Status == doctor.StatusWarn:
+			warnings++
+		}
+		fmt.Fprintf(out, "%-6s %s: %s\n", status, r.Check, r.Message)
+		if r.Fixed || r.Status == doctor.StatusOK {
+			continue
+		}
+		if r.FixErr != nil {
+			fmt.Fprintf(out, "       failed to fix: %v\n", r.FixErr)
+		}
+		if r.Hint != "" {
+			fmt.Fprintf(out, "       hint: %s\n", r.Hint)
+		}
+	}
+
+	printSummary(out, failures, warnings, fixed)
+	switch failures {
+	case 0:
+		return nil
+	case 1:
+		return errors.New("one check failed")
+	default:
+		return errors.Errorf("%d checks failed", failures)
+	}
+}
+
+func printSummary(out io.Writer, failures, warnings, fixed int) {
+	if failures+warnings+fixed == 0 {
+		fmt.Fprintln(out, "\nno problems found")
+		return
+	}
+	fmt.Fprintf(out, "\n%d failed, %d warnings, %d fixed\n", failures, warnings, fixed)
+}
@@ -0,0 +1,168 @@
+package dotfiles
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/cli/util"
+	"github.com/alex-held/devctl/pkg/dotfiles"
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// NewCmd creates the 'devctl dotfiles' command
+func NewCmd(f env.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dotfiles",
+		Short: "apply dotfiles to the home directory",
+		Long: `Apply the dotfiles declared in the devctl configuration to the home directory.
+The dotfiles are either a git repository, which is cloned by devctl, or a local directory,
+whose path is absolute or starts with ~/, e.g. 'path: ~/dotfiles':
+  dotfiles:
+    repo: https://github.com/alex-held/dotfiles
+    values:
+      email: alex@example.com
+Every file is linked to the same path below $HOME, or copied using 'mode: copy'.
+Files ending in .tmpl are rendered as Go templates, e.g. using {{ .DEVCTL_PATH_BIN }}
+or {{ .values.email }}, and copied without the extension.
+Existing files are moved to a backup directory, 'devctl dotfiles unlink' moves them back.
+Examples:
+  To show what would be applied:
+    devctl dotfiles apply --dry-run
+  To show the dotfiles, which changed since they were applied:
+    devctl dotfiles status`,
+		Run: util.DefaultSubCommandRun(f.Streams().ErrOut),
+	}
+
+	cmd.AddCommand(newApplyCmd(f))
+	cmd.AddCommand(newStatusCmd(f))
+	cmd.AddCommand(newUnlinkCmd(f))
+	return cmd
+}
+
+// ApplyOptions are the options of 'devctl dotfiles apply'
+type ApplyOptions struct {
+	DryRun   bool
+	NoUpdate bool
+}
+
+func newApplyCmd(f env.Factory) *cobra.Command {
+	o := &ApplyOptions{}
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "link, copy and render the dotfiles into the home directory",
+		Long: `Update the dotfiles repository and link, copy and render the dotfiles into the home directory.
+Conflicting files are moved to a backup directory. Dotfiles, which have been removed from the
+dotfiles since the last apply, are removed from the home directory.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return o.Run(f)
+		},
+	}
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun, "If true, only print the changes")
+	cmd.Flags().BoolVar(&o.NoUpdate, "no-update", o.NoUpdate, "If true, don't update the dotfiles repository")
+	return cmd
+}
+
+// Run applies the dotfiles
+func (o *ApplyOptions) Run(f env.Factory) error {
+	m, err := dotfiles.New(f)
+	if err != nil {
+		return err
+	}
+	if !o.NoUpdate && !o.DryRun {
+		if err = m.Sync(); err != nil {
+			return err
+		}
+	}
+	changes, err := m.Apply(o.DryRun)
+	if perr := printChanges(f.Streams().Out, changes); err == nil {
+		err = perr
+	}
+	return err
+}
+
+func newStatusCmd(f env.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "show the dotfiles, which differ from the home directory",
+		Long: `Show the status of every dotfile: 'ok', if it is applied, 'missing', 'modified' or 'outdated',
+if the home directory or the dotfiles changed since it was applied, 'not applied' or 'removed'.
+Exits with a non-zero code, if any dotfile drifted.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			m, err := dotfiles.New(f)
+			if err != nil {
+				return err
+			}
+			status, err := m.Status()
+			if err != nil {
+				return err
+			}
+
+			drifted := 0
+			w := tabwriter.NewWriter(f.Streams().Out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "DOTFILE\tSTATUS\tTARGET")
+			for _, s := range status {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, s.Status, s.Target)
+				if s.Status != dotfiles.StatusOK {
+					drifted++
+				}
+			}
+			if err = w.Flush(); err != nil {
+				return err
+			}
+			if drifted > 0 {
+				return errors.Errorf("%d of %d dotfiles drifted, run 'devctl dotfiles apply'", drifted, len(status))
+			}
+			return nil
+		},
+	}
+}
+
+func newUnlinkCmd(f env.Factory) *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "unlink",
+		Short: "remove the applied dotfiles from the home directory",
+		Long: `Remove the applied dotfiles from the home directory and move the files they replaced back.
+Dotfiles, which have been modified since they were applied, are kept.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			m, err := dotfiles.New(f)
+			if err != nil {
+				return err
+			}
+			changes, err := m.Unlink(dryRun)
+			if perr := printChanges(f.Streams().Out, changes); err == nil {
+				err = perr
+			}
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", dryRun, "If true, only print the changes")
+	return cmd
+}
+
+func printChanges(out io.Writer, changes []dotfiles.Change) error {
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "no dotfiles")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DOTFILE\tACTION\tTARGET\tBACKUP")
+	for _, c := range changes {
+		backup := c.Backup
+		if backup == "" {
+			backup = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Name, c.Action, c.Target, backup)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,184 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/cli/completion"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/history"
+	"github.com/alex-held/devctl/pkg/index/installation"
+)
+
+// Options are the options of 'devctl history'
+type Options struct {
+	Plugin string
+	// Since is a duration like 24h, a date like 2021-07-01 or a RFC3339 timestamp
+	Since string
+}
+
+// NewCmd creates the 'devctl history' command
+func NewCmd(f env.Factory) *cobra.Command {
+	o := &Options{}
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "show the history of installs, upgrades and sdk switches",
+		Long: `Show the recorded history of the plugin installs, upgrades and uninstalls,
+of the added, removed and updated indexes and of the sdk versions selected.
+The history is kept in history.log in the devctl state directory.
+Examples:
+  To show the changes of the last day:
+    devctl history --since 24h
+  To show the changes of the sdkman plugin since July:
+    devctl history --plugin sdkman --since 2021-07-01
+  To undo the upgrade with the id 3f9a1c2e:
+    devctl history undo 3f9a1c2e`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.Run(f)
+		},
+	}
+	cmd.Flags().StringVar(&o.Plugin, "plugin", o.Plugin, "Only show the entries of the plugin")
+	cmd.Flags().StringVar(&o.Since, "since", o.Since, "Only show the entries since a duration ago (e.g. 24h), a date (e.g. 2021-07-01) or a RFC3339 timestamp")
+	_ = cmd.RegisterFlagCompletionFunc("plugin", completion.InstalledPluginCompletionFunc(f))
+
+	cmd.AddCommand(newUndoCmd(f))
+	return cmd
+}
+
+// Run prints the entries of the history matching o
+func (o *Options) Run(f env.Factory) error {
+	q := history.Query{Plugin: o.Plugin}
+	if o.Since != "" {
+		since, err := parseSince(o.Since, time.Now())
+		if err != nil {
+			return err
+		}
+		q.Since = since
+	}
+
+	entries, err := history.Load(history.Path(f.Paths()))
+	if err != nil {
+		return err
+	}
+	entries = q.Filter(entries)
+	if len(entries) == 0 {
+		fmt.Fprintln(f.Streams().ErrOut, "No history entries found.")
+		return nil
+	}
+	return printEntries(f.Streams().Out, entries)
+}
+
+func printEntries(out io.Writer, entries []history.Entry) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tUSER\tACTION\tTARGET\tBEFORE\tAFTER\tRESULT")
+	for _, e := range entries {
+		result := e.Result
+		if e.Undoes != "" {
+			result += " (undoes " + e.Undoes + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.ID, e.Time.Local().Format("2006-01-02 15:04:05"), e.User, e.Action, e.Target(),
+			orNone(shortCommit(e.Before)), orNone(shortCommit(e.After)), result)
+	}
+	return w.Flush()
+}
+
+// parseSince parses the --since flag relative to now
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", since, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.Errorf("invalid --since %q, use a duration like 24h, a date like 2021-07-01 or a RFC3339 timestamp", since)
+}
+
+// shortCommit abbreviates the git commits of index updates
+func shortCommit(s string) string {
+	if len(s) == 40 {
+		return s[:7]
+	}
+	return s
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func newUndoCmd(f env.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:   "undo ID",
+		Short: "revert an update of devctl",
+		Long: `Revert the last version change of a plugin by linking the version it had before.
+Only the last upgrade or undo of a plugin can be reverted and only while the previous
+version is still in the store. Plugins keep the version they have been upgraded from
+until their next upgrade.
+Remarks:
+  Only devctl itself is upgraded in place, by 'devctl self-update'. Other plugins are
+  reinstalled to change their version, which doesn't keep the previous version, so
+  their installs can't be undone.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return undo(f, args[0])
+		},
+	}
+}
+
+// undo links the version the plugin of the entry id had before
+func undo(f env.Factory, id string) error {
+	entries, err := history.Load(history.Path(f.Paths()))
+	if err != nil {
+		return err
+	}
+	e, ok := history.Find(entries, id)
+	if !ok {
+		return errors.Errorf("no history entry with id %q", id)
+	}
+	if last, ok := history.LastVersionChange(entries, e.Plugin); !ok || last.ID != e.ID {
+		if ok {
+			return errors.Errorf("entry %s is not the last version change of plugin %q, only %s can be undone", id, e.Plugin, last.ID)
+		}
+		return errors.Errorf("entry %s did not change the version of a plugin", id)
+	}
+	if e.Before == "" || e.After == "" {
+		return errors.Errorf("the %s of plugin %q can't be undone", e.Action, e.Plugin)
+	}
+
+	receipt, err := installation.Load(f.Fs(), f.Paths().PluginInstallReceiptPath(e.Plugin))
+	if err != nil {
+		return errors.Wrapf(err, "failed to look up install receipt for plugin %q", e.Plugin)
+	}
+	if receipt.Spec.Version != e.After {
+		return errors.Errorf("plugin %q is at version %s, not at %s", e.Plugin, receipt.Spec.Version, e.After)
+	}
+
+	err = installation.Rollback(f, e.Plugin, e.Before)
+	history.Record(f, history.Entry{
+		Action: history.ActionUndo,
+		Plugin: e.Plugin,
+		Index:  e.Index,
+		Before: e.After,
+		After:  e.Before,
+		Undoes: e.ID,
+	}, err)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(f.Streams().Out, "Reverted plugin %q from %s to %s.\n", e.Plugin, e.After, e.Before)
+	return nil
+}
@@ -1,46 +1,233 @@
 package info
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
+	"github.com/alex-held/devctl/internal/git"
 	"github.com/alex-held/devctl/pkg/cli/options"
-	"github.com/alex-held/devctl/pkg/cli/util"
 	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/scanner"
+	"github.com/alex-held/devctl/pkg/profile"
+	"github.com/alex-held/devctl/pkg/version"
 )
 
 type InfoOptions struct {
 	options.IOStreams
+
+	// Output is the output format, text or json
+	Output string
 }
 
 func NewOptions(streams options.IOStreams) *InfoOptions {
 	return &InfoOptions{
 		IOStreams: streams,
+		Output:    "text",
+	}
+}
+
+// Info describes the devctl installation
+type Info struct {
+	Version version.Info `json:"version"`
+	Layout  env.Layout   `json:"layout"`
+	Paths   Paths        `json:"paths"`
+	Indexes []Index      `json:"indexes"`
+	// Plugins is the number of plugins installed from an index
+	Plugins int `json:"installedPlugins"`
+	// SDKs are the current versions of the installed sdks
+	SDKs    map[string]string `json:"sdks"`
+	Profile string            `json:"profile,omitempty"`
+	Shell   string            `json:"shell"`
+}
+
+// Paths are the resolved paths of the devctl installation
+type Paths struct {
+	Base       string `json:"base"`
+	Bin        string `json:"bin"`
+	Store      string `json:"store"`
+	Receipts   string `json:"receipts"`
+	Index      string `json:"index"`
+	SDK        string `json:"sdk"`
+	Plugin     string `json:"plugin"`
+	Config     string `json:"config"`
+	ConfigFile string `json:"configFile"`
+	Cache      string `json:"cache"`
+	State      string `json:"state"`
+}
+
+// Index is a configured plugin index
+type Index struct {
+	Name   string `json:"name"`
+	URL    string `json:"url,omitempty"`
+	Commit string `json:"commit,omitempty"`
+}
+
+// Collect gathers the Info of the installation of f
+func Collect(f env.Factory) (*Info, error) {
+	paths, pather := f.Paths(), f.Pather()
+	info := &Info{
+		Version: version.Get(),
+		Layout:  paths.Dirs().Layout,
+		Paths: Paths{
+			Base:       paths.Base(),
+			Bin:        paths.BinPath(),
+			Store:      paths.InstallPath(),
+			Receipts:   paths.InstallReceiptsPath(),
+			Index:      paths.IndexBase(),
+			SDK:        pather.SDK(),
+			Plugin:     pather.Plugin(),
+			Config:     pather.ConfigRoot(),
+			ConfigFile: pather.ConfigFilePath(),
+			Cache:      pather.Cache(),
+			State:      paths.StatePath(),
+		},
+		Indexes: []Index{},
+		SDKs:    map[string]string{},
+		Shell:   detectShell(),
+	}
+
+	names, err := scanner.IndexNames(paths)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to list the indexes")
+	}
+	for _, name := range names {
+		dir := paths.IndexPath(name)
+		idx := Index{Name: name}
+		if url, err := git.GetRemoteURL(dir); err == nil {
+			idx.URL = strings.TrimSpace(url)
+		}
+		if commit, err := git.Exec(dir, "rev-parse", "--short", "HEAD"); err == nil {
+			idx.Commit = strings.TrimSpace(commit)
+		}
+		info.Indexes = append(info.Indexes, idx)
+	}
+
+	receipts, err := installation.GetInstalledPluginReceipts(f)
+	if err != nil {
+		return nil, err
+	}
+	info.Plugins = len(receipts)
+
+	sdks, _ := ioutil.ReadDir(pather.SDK())
+	for _, sdk := range sdks {
+		if target, err := os.Readlink(profile.SDKCurrentPath(pather, sdk.Name())); err == nil {
+			info.SDKs[sdk.Name()] = filepath.Base(target)
+		}
+	}
+
+	if file, err := f.Config(); err == nil {
+		info.Profile = file.Config.CurrentProfile
 	}
+	return info, nil
+}
+
+// detectShell returns the name of the login shell
+func detectShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return filepath.Base(shell)
+	}
+	return "unknown"
 }
 
 func (o *InfoOptions) Run(f env.Factory, cmd *cobra.Command) error {
-	f.Logger().Infof("devctl info\nPath=%s\n", f.Pather().ConfigRoot())
-	return nil
+	info, err := Collect(f)
+	if err != nil {
+		return err
+	}
+
+	switch o.Output {
+	case "json":
+		enc := json.NewEncoder(o.Out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	case "text", "":
+		return printText(o, info)
+	default:
+		return errors.Errorf("unknown output format %q, use text or json", o.Output)
+	}
+}
+
+func printText(o *InfoOptions, info *Info) error {
+	w := tabwriter.NewWriter(o.Out, 0, 0, 2, ' ', 0)
+	v := info.Version
+	fmt.Fprintf(w, "devctl %s (commit %s, %s %s)\n", v.Version, orNone(v.GitCommit), v.GoVersion, v.Platform)
+
+	fmt.Fprintf(w, "\nPaths (%s layout):\n", info.Layout)
+	p := info.Paths
+	for _, path := range [][2]string{
+		{"base", p.Base}, {"bin", p.Bin}, {"store", p.Store}, {"receipts", p.Receipts}, {"index", p.Index},
+		{"sdk", p.SDK}, {"plugin", p.Plugin}, {"config", p.Config}, {"config file", p.ConfigFile}, {"cache", p.Cache}, {"state", p.State},
+	} {
+		fmt.Fprintf(w, "  %s:\t%s\n", path[0], path[1])
+	}
+
+	fmt.Fprintln(w, "\nIndexes:")
+	if len(info.Indexes) == 0 {
+		fmt.Fprintln(w, "  <none>")
+	}
+	for _, idx := range info.Indexes {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", idx.Name, orNone(idx.URL), orNone(idx.Commit))
+	}
+
+	fmt.Fprintln(w, "\nSDKs:")
+	if len(info.SDKs) == 0 {
+		fmt.Fprintln(w, "  <none>")
+	}
+	var sdks []string
+	for sdk := range info.SDKs {
+		sdks = append(sdks, sdk)
+	}
+	sort.Strings(sdks)
+	for _, sdk := range sdks {
+		fmt.Fprintf(w, "  %s\t%s\n", sdk, info.SDKs[sdk])
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Installed plugins:\t%d\n", info.Plugins)
+	fmt.Fprintf(w, "Profile:\t%s\n", orNone(info.Profile))
+	fmt.Fprintf(w, "Shell:\t%s\n", info.Shell)
+	return w.Flush()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
 }
 
 func NewCmd(f env.Factory) (cmd *cobra.Command) {
 	o := NewOptions(f.Streams())
 
 	cmd = &cobra.Command{
-		Use:                   "info",
-		DisableFlagsInUseLine: true,
-		Short:                 "prints devctl info",
-		Long:                  "prints devctl info",
+		Use:   "info",
+		Short: "prints devctl info",
+		Long: `Print information about the devctl installation: the version, the resolved paths,
+the configured indexes with their commit, the number of installed plugins,
+the current sdk versions, the active profile and the detected shell.`,
 		Example: `
 		To get devctl info:
 			devctl info
+		To get devctl info as JSON:
+			devctl info -o json
 		`,
-		Run: func(cmd *cobra.Command, args []string) {
-			// util.CheckErr(o.Complete(f, cmd))
-			// util.CheckErr(o.ValidateArgs(cmd, args))
-			util.CheckErr(o.Run(f, cmd))
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.Run(f, cmd)
 		},
 	}
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format, one of text|json")
 
 	return cmd
 }
@@ -1,11 +1,17 @@
 package list
 
 import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"github.com/alex-held/devctl/pkg/cli/options"
 	"github.com/alex-held/devctl/pkg/cli/util"
 	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/registry"
 )
 
 type ListOptions struct {
@@ -25,6 +31,12 @@ func NewListOptions(ioStreams options.IOStreams) *ListOptions {
 
 // ValidateArgs makes sure there is no discrepancy in command options
 func (o *ListOptions) ValidateArgs(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return util.UsageErrorf(cmd, "unexpected arguments: %v", args)
+	}
+	if o.Upgradable && (o.Remote || o.All) {
+		return util.UsageErrorf(cmd, "--upgradable can't be combined with --remote or --all")
+	}
 	return nil
 }
 
@@ -37,15 +49,52 @@ func (o *ListOptions) Complete(f env.Factory, cmd *cobra.Command) error {
 
 // Run performs the listing of plugins
 func (o *ListOptions) Run(f env.Factory, cmd *cobra.Command) error {
-	return nil
+	r := registry.New(f, registry.NewEngine(f))
+
+	var entries []*registry.Entry
+	var err error
+	switch {
+	case o.All:
+		entries, err = r.All()
+	case o.Remote:
+		entries, err = r.Index()
+	default:
+		entries, err = r.Installed()
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to list plugins")
+	}
+
+	w := tabwriter.NewWriter(o.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join([]string{"NAME", "KIND", "VERSION", "STATUS"}, "\t"))
+	for _, e := range entries {
+		if o.Upgradable && !e.Upgradable() {
+			continue
+		}
+		fmt.Fprintln(w, strings.Join([]string{e.DisplayName(), string(e.Kind), e.Version, status(e)}, "\t"))
+	}
+	return w.Flush()
+}
+
+func status(e *registry.Entry) string {
+	switch {
+	case e.Upgradable():
+		return "upgradable to " + e.Spec.Spec.Version
+	case e.Installed:
+		return "installed"
+	case e.Supported:
+		return "available"
+	default:
+		return "unavailable"
+	}
 }
 
-// NewCmdCreate returns new initialized instance of create sub command
-func NewCmdCreate(f env.Factory) *cobra.Command {
+// NewCmd returns new initialized instance of list sub command
+func NewCmd(f env.Factory) *cobra.Command {
 	o := NewListOptions(f.Streams())
 
 	cmd := &cobra.Command{
-		Use:                   "create -f FILENAME",
+		Use:                   "list",
 		DisableFlagsInUseLine: true,
 		Short:                 "lists devctl plugins",
 		Long:                  "lists devctl plugins",
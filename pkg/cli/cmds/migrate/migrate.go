@@ -0,0 +1,148 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/config"
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// Options are the options of 'devctl migrate-layout'
+type Options struct {
+	To     string
+	Root   string
+	DryRun bool
+}
+
+// NewCmd creates the 'devctl migrate-layout' command
+func NewCmd(f env.Factory) *cobra.Command {
+	o := &Options{}
+	cmd := &cobra.Command{
+		Use:   "migrate-layout",
+		Short: "move the devctl directories to another layout",
+		Long: `Move the devctl directories to another layout.
+The 'root' layout keeps everything below a single directory, by default ~/.devctl.
+The 'xdg' layout follows the XDG Base Directory Specification and splits the files into
+$XDG_CONFIG_HOME/devctl, $XDG_DATA_HOME/devctl, $XDG_CACHE_HOME/devctl and $XDG_STATE_HOME/devctl.
+Examples:
+  To show what would be moved to the XDG directories:
+    devctl migrate-layout --to xdg --dry-run
+  To move everything back to ~/.devctl:
+    devctl migrate-layout --to root`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return o.Run(f)
+		},
+	}
+	cmd.Flags().StringVar(&o.To, "to", o.To, "The layout to migrate to, either 'root' or 'xdg' (defaults to the layout not used right now)")
+	cmd.Flags().StringVar(&o.Root, "root", o.Root, "The root directory when migrating to the 'root' layout (defaults to ~/.devctl)")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun, "If true, only print the moves")
+	return cmd
+}
+
+// Run migrates the directories of f to the layout o.To
+func (o *Options) Run(f env.Factory) error {
+	out := f.Streams().Out
+	from := f.Paths().Dirs()
+	to, err := o.target(from)
+	if err != nil {
+		return err
+	}
+
+	m, err := env.PlanMigration(from, to)
+	if err != nil {
+		return err
+	}
+	if len(m.Moves) == 0 {
+		fmt.Fprintln(out, "nothing to migrate")
+		return nil
+	}
+	for _, mv := range m.Moves {
+		fmt.Fprintf(out, "%s -> %s\n", mv.From, mv.To)
+	}
+	for _, path := range m.Unknown {
+		fmt.Fprintf(f.Streams().ErrOut, "Warning: %s is not known to devctl and stays where it is\n", path)
+	}
+	if o.DryRun {
+		return nil
+	}
+
+	if err := m.Execute(); err != nil {
+		return err
+	}
+	if err := saveLayout(f, to); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "migrated to the %s layout\n", to.Layout)
+	if bin := env.NewPather(from).Bin(); bin != env.NewPather(to).Bin() {
+		fmt.Fprintf(out, "replace %s with %s in your PATH\n", bin, env.NewPather(to).Bin())
+	}
+	if to.Layout == env.LayoutRoot && o.Root != "" {
+		fmt.Fprintf(out, "export DEVCTL_ROOT=%s or pass --devctl-path %s to use it\n", to.Data, to.Data)
+	}
+	return nil
+}
+
+func (o *Options) target(from env.Dirs) (env.Dirs, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return env.Dirs{}, errors.Wrap(err, "cannot get user home dir")
+	}
+
+	layout := env.Layout(o.To)
+	if layout == "" {
+		layout = env.LayoutXDG
+		if from.Layout == env.LayoutXDG {
+			layout = env.LayoutRoot
+		}
+	}
+	switch layout {
+	case env.LayoutXDG:
+		if o.Root != "" {
+			return env.Dirs{}, errors.New("--root can only be used with '--to root'")
+		}
+		return env.XDGDirs(home), nil
+	case env.LayoutRoot:
+		root := env.DefaultRoot(home)
+		if o.Root != "" {
+			if root, err = filepath.Abs(o.Root); err != nil {
+				return env.Dirs{}, err
+			}
+		}
+		return env.RootDirs(root), nil
+	default:
+		return env.Dirs{}, errors.Errorf("unknown layout %q, must be %q or %q", layout, env.LayoutRoot, env.LayoutXDG)
+	}
+}
+
+// saveLayout records the layout in the moved configuration file, so that it is picked up without DEVCTL_LAYOUT
+func saveLayout(f env.Factory, to env.Dirs) error {
+	pather := env.NewPather(to)
+	file, err := config.Load(f.Fs(), pather.ConfigFilePath(), env.BuiltinVars(pather))
+	if err != nil {
+		return err
+	}
+	if file.Config.Root == "" && file.Config.Layout == "" && to.Layout == env.LayoutRoot {
+		return nil
+	}
+	if file.Config.Root != "" {
+		if err := file.Set("root", ""); err != nil {
+			return err
+		}
+	}
+	layout := string(to.Layout)
+	if to.Layout == env.LayoutRoot {
+		layout = ""
+	}
+	if err := file.Set("layout", layout); err != nil {
+		return err
+	}
+	return file.Save()
+}
@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// newDoctorCmd creates the 'devctl plugin doctor' command
+func newDoctorCmd(f env.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the installed interpreted plugins",
+		Long: `Check the interpreted plugins installed in the plugin directory.
+Lists all plugins that have been loaded and reports every plugin,
+which could not be loaded together with the reason.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			out := f.Streams().Out
+			builtin := cmd.Root().Commands()
+			e := NewEngine(f)
+
+			interpreted, loadErrs := NewInterpretedCmds(e, builtin)
+
+			var rows [][]string
+			for _, c := range interpreted {
+				rows = append(rows, []string{c.Name(), "ok", ""})
+			}
+			for _, err := range loadErrs {
+				rows = append(rows, []string{"-", "failed", err.Error()})
+			}
+			if len(rows) == 0 {
+				fmt.Fprintf(out, "no plugins installed in %q\n", f.Pather().Plugin())
+				return nil
+			}
+			if err := printTable(out, []string{"COMMAND", "STATUS", "ERROR"}, rows); err != nil {
+				return err
+			}
+
+			if len(loadErrs) > 0 {
+				return errors.Errorf("%d plugins could not be loaded", len(loadErrs))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,172 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/plugins"
+	"github.com/alex-held/devctl/pkg/registry"
+)
+
+// InterpretedPluginAnnotation annotates commands created from interpreted plugins with the name of the plugin
+const InterpretedPluginAnnotation = "devctl.plugin"
+
+// NewEngine returns a plugins.Engine loading interpreted plugins using the factory
+func NewEngine(f env.Factory) *plugins.Engine {
+	return registry.NewEngine(f)
+}
+
+// NewInterpretedCmds loads all interpreted plugins and turns the CommandSpec of each plugin into a cobra.Command.
+// Plugins whose command collides with one of the builtin commands are skipped and reported as load errors.
+func NewInterpretedCmds(e *plugins.Engine, builtin []*cobra.Command) (cmds []*cobra.Command, loadErrs []error) {
+	taken := map[string]bool{"help": true}
+	for _, c := range builtin {
+		if _, ok := c.Annotations[InterpretedPluginAnnotation]; ok {
+			continue
+		}
+		taken[c.Name()] = true
+		for _, alias := range c.Aliases {
+			taken[alias] = true
+		}
+	}
+
+	for _, p := range e.LoadPlugins() {
+		if taken[p.Cmd] {
+			loadErrs = append(loadErrs, &plugins.LoadError{
+				Path: p.RootPath,
+				Err:  errors.Errorf("command %q of plugin %q collides with an existing command", p.Cmd, p.Name),
+			})
+			continue
+		}
+		taken[p.Cmd] = true
+		cmds = append(cmds, newInterpretedCmd(e, p, *p.CommandSpec, nil))
+	}
+	for _, err := range e.LoadErrors() {
+		loadErrs = append(loadErrs, err)
+	}
+
+	return cmds, loadErrs
+}
+
+// newInterpretedCmd creates the command for spec and its subcommands.
+// parents are the names of the subcommands between the plugin command and spec.
+func newInterpretedCmd(e *plugins.Engine, p *plugins.Plugin, spec plugins.CommandSpec, parents []string) *cobra.Command {
+	path := append(append([]string{}, parents...), spec.Cmd)
+
+	cmd := &cobra.Command{
+		Use:                spec.Cmd,
+		Short:              fmt.Sprintf("%s (plugin %s)", strings.Join(path, " "), p.Name),
+		Long:               spec.Help,
+		Annotations:        map[string]string{InterpretedPluginAnnotation: p.Name},
+		DisableFlagParsing: true,
+		SilenceUsage:       true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// flag parsing is left to the plugin, except for the flags of devctl
+			args, err := parseDevctlFlags(cmd, args)
+			if err != nil {
+				return err
+			}
+			klog.V(2).Infof("loaded plugin %s in %s (checks cached=%t)", p.Name, p.LoadTime, p.Cached)
+
+			timeout, err := time.ParseDuration(lookupFlag(cmd, timeoutFlag).Value.String())
+			if err != nil {
+				return err
+			}
+			// the plugin receives the path of subcommands below its own command
+			return execInterpreted(cmd.Context(), e, p, timeout, append(append([]string{}, path[1:]...), args...))
+		},
+	}
+	if len(parents) == 0 {
+		cmd.PersistentFlags().Duration(timeoutFlag, 0, "Abort the plugin if it does not finish within the duration (defaults to the timeout of the plugin manifest)")
+	}
+
+	for _, sub := range spec.Subcommands {
+		cmd.AddCommand(newInterpretedCmd(e, p, sub, path))
+	}
+	return cmd
+}
+
+const timeoutFlag = "timeout"
+
+// lookupFlag looks up the flag defined by the command or one of its parents
+func lookupFlag(cmd *cobra.Command, name string) *pflag.Flag {
+	if f := cmd.Flags().Lookup(name); f != nil {
+		return f
+	}
+	return cmd.InheritedFlags().Lookup(name)
+}
+
+// parseDevctlFlags parses the long flags of devctl, like --timeout or --v, and returns all other args,
+// as cobra does not parse the flags of interpreted plugins.
+// Shorthands are left to the plugin.
+func parseDevctlFlags(cmd *cobra.Command, args []string) (rest []string, err error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(rest, args[i:]...), nil
+		}
+		if !strings.HasPrefix(arg, "--") {
+			rest = append(rest, arg)
+			continue
+		}
+
+		name, value := strings.TrimPrefix(arg, "--"), ""
+		hasValue := false
+		if idx := strings.Index(name, "="); idx >= 0 {
+			name, value, hasValue = name[:idx], name[idx+1:], true
+		}
+
+		f := lookupFlag(cmd, name)
+		if f == nil {
+			rest = append(rest, arg)
+			continue
+		}
+
+		if !hasValue {
+			switch {
+			case f.NoOptDefVal != "":
+				value = f.NoOptDefVal
+			case i+1 < len(args):
+				i++
+				value = args[i]
+			default:
+				return nil, errors.Errorf("flag needs an argument: %s", arg)
+			}
+		}
+		if err = f.Value.Set(value); err != nil {
+			return nil, errors.Wrapf(err, "invalid argument %q for %s", value, arg)
+		}
+		f.Changed = true
+	}
+	return rest, nil
+}
+
+func execInterpreted(ctx context.Context, e *plugins.Engine, p *plugins.Plugin, timeout time.Duration, args []string) error {
+	cfg, err := plugins.ResolveDynamic(p.Config, e)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve config of plugin %q", p.Name)
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout > 0 {
+		// the timeout limits loading the plugin as well
+		withTimeout := *p
+		withTimeout.Timeout = timeout
+		p = &withTimeout
+	}
+	execP, err := e.NewExecutablePluginContext(ctx, p, cfg.Map())
+	if err != nil {
+		return errors.Wrapf(err, "failed to load plugin %q", p.Name)
+	}
+	return execP.Exec(args)
+}
@@ -0,0 +1,148 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/cli/util"
+	"github.com/alex-held/devctl/pkg/registry"
+)
+
+// ExternalPluginAnnotation annotates commands created for external plugins with the path of their executable
+const ExternalPluginAnnotation = "devctl.plugin.external"
+
+// NewExternalCmds creates a command for each external plugin on the PATH, which is run using h.
+// The plugins are only invoked to describe themselves, if describe is set, e.g. to show the help or to complete.
+// Otherwise the commands are created from the names found on the PATH.
+// Plugins whose name collides with one of the builtin commands are skipped.
+func NewExternalCmds(r *registry.Registry, builtin []*cobra.Command, h util.PluginHandler, describe bool) ([]*cobra.Command, error) {
+	entries, err := r.External()
+	if err != nil {
+		return nil, err
+	}
+
+	taken := map[string]bool{"help": true}
+	for _, c := range builtin {
+		taken[c.Name()] = true
+		for _, alias := range c.Aliases {
+			taken[alias] = true
+		}
+	}
+
+	var cmds []*cobra.Command
+	for _, e := range entries {
+		// devctl-foo-bar is dispatched as 'devctl foo bar', which is registered as subcommand during completion
+		if strings.Contains(e.Name, "-") || taken[e.Name] {
+			continue
+		}
+		taken[e.Name] = true
+		if describe {
+			r.Describe(e)
+		}
+
+		spec := registry.Command{Name: e.Name, Short: fmt.Sprintf("The command %s is a plugin", e.Name)}
+		if e.Description != nil {
+			spec = e.Description.Command
+			spec.Name = e.Name
+		}
+		cmds = append(cmds, newExternalCmd(h, e, spec, nil))
+	}
+	return cmds, nil
+}
+
+// newExternalCmd creates the command for spec and its subcommands.
+// parents are the names of the subcommands between the plugin command and spec.
+func newExternalCmd(h util.PluginHandler, e *registry.Entry, spec registry.Command, parents []string) *cobra.Command {
+	path := append(append([]string{}, parents...), spec.Name)
+
+	cmd := &cobra.Command{
+		Use:                spec.Name,
+		Short:              spec.Short,
+		Long:               spec.Long,
+		Aliases:            spec.Aliases,
+		Annotations:        map[string]string{ExternalPluginAnnotation: e.Path},
+		DisableFlagParsing: true,
+		SilenceUsage:       true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// external plugins are usually dispatched by HandlePluginCommand, before cobra gets to run them
+			return h.Execute(e.Path, append(append([]string{}, path[1:]...), args...), os.Environ())
+		},
+	}
+
+	// the flags are only shown in the help, as flag parsing is left to the plugin
+	for _, flag := range spec.Flags {
+		cmd.Flags().StringP(flag.Name, flag.Shorthand, flag.Default, flag.Usage)
+		if flag.Type == "bool" {
+			cmd.Flags().Lookup(flag.Name).NoOptDefVal = "true"
+		}
+	}
+
+	if e.Description != nil && e.Description.Completion {
+		cmd.ValidArgsFunction = externalCompletionFunc(e.Path, path[1:])
+	} else {
+		cmd.ValidArgsFunction = flagCompletionFunc(spec.Flags)
+	}
+
+	for _, sub := range spec.Commands {
+		cmd.AddCommand(newExternalCmd(h, e, sub, path))
+	}
+	return cmd
+}
+
+// externalCompletionFunc delegates the completion to the plugin, by invoking it with cobra's __complete command
+func externalCompletionFunc(path string, subcommands []string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		ctx, cancel := context.WithTimeout(context.Background(), registry.DefaultDescribeTimeout)
+		defer cancel()
+
+		completeArgs := append(append(append([]string{cobra.ShellCompRequestCmd}, subcommands...), args...), toComplete)
+		out, err := exec.CommandContext(ctx, path, completeArgs...).Output()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		return parseCompletions(out)
+	}
+}
+
+// parseCompletions parses the output of cobra's __complete command, whose last line is ':DIRECTIVE'
+func parseCompletions(out []byte) (completions []string, directive cobra.ShellCompDirective) {
+	directive = cobra.ShellCompDirectiveDefault
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, ":") {
+			if d, err := strconv.Atoi(line[1:]); err == nil {
+				directive = cobra.ShellCompDirective(d)
+			}
+			continue
+		}
+		if line != "" {
+			completions = append(completions, line)
+		}
+	}
+	return completions, directive
+}
+
+// flagCompletionFunc completes the described flags of plugins, which don't complete their arguments themselves
+func flagCompletionFunc(flags []registry.Flag) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if !strings.HasPrefix(toComplete, "-") {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		var completions []string
+		for _, flag := range flags {
+			if name := "--" + flag.Name; strings.HasPrefix(name, toComplete) {
+				completions = append(completions, name+"\t"+flag.Usage)
+			}
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
+	"github.com/alex-held/devctl/pkg/cli/completion"
 	"github.com/alex-held/devctl/pkg/constants"
 	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/history"
 	"github.com/alex-held/devctl/pkg/index/installation"
 	"github.com/alex-held/devctl/pkg/index/scanner"
 )
@@ -60,6 +62,7 @@ each configured index in table format.`,
 				return errInvalidIndexName
 			}
 			err := scanner.AddIndex(f.Paths(), name, args[1])
+			recordIndexAdd(f, name, args[1], err)
 			if err != nil {
 				return err
 			}
@@ -79,7 +82,8 @@ It is only safe to remove indexes without installed plugins. Removing an index
 while there are plugins installed will result in an error, unless the --force
 option is used (not recommended).`,
 
-		Args: cobra.ExactArgs(1),
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completion.IndexCompletionFunc(f),
 		RunE: func(_ *cobra.Command, args []string) error {
 			name := args[0]
 			if !scanner.IsValidIndexName(name) {
@@ -104,7 +108,11 @@ Removing indexes while there are plugins installed from is not recommended
 				return errors.Errorf("there are still plugins installed from this index")
 			}
 
+			before := history.IndexCommit(f.Paths(), name)
 			err = scanner.DeleteIndex(f.Paths(), name)
+			if !os.IsNotExist(err) {
+				history.Record(f, history.Entry{Action: history.ActionIndexRemove, Index: name, Before: before}, err)
+			}
 			if os.IsNotExist(err) {
 				if *forceIndexDelete {
 					f.Logger().Infof("Index not found, but --force is used, so not returning an error")
@@ -125,3 +133,12 @@ Removing indexes while there are plugins installed from is not recommended
 
 	return cmd
 }
+
+// recordIndexAdd records an added index and the commit it has been cloned at in the history
+func recordIndexAdd(f env.Factory, name, url string, err error) {
+	e := history.Entry{Action: history.ActionIndexAdd, Index: name, URL: url}
+	if err == nil {
+		e.After = history.IndexCommit(f.Paths(), name)
+	}
+	history.Record(f, e, err)
+}
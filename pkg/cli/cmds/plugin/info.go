@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/cli/completion"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/printutils"
+	"github.com/alex-held/devctl/pkg/registry"
+)
+
+// newInfoCmd creates the 'devctl plugin info' command
+func newInfoCmd(f env.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:   "info NAME",
+		Short: "Show information about a plugin",
+		Long: `Show information about an installed plugin or a plugin available from an index.
+Binary and interpreted plugins are supported alike.
+Examples:
+  To show information about a plugin:
+    devctl plugin info NAME
+  To show information about a plugin of a custom index:
+    devctl plugin info INDEX/NAME`,
+		Args:              cobra.ExactArgs(1),
+		SilenceUsage:      true,
+		ValidArgsFunction: completion.IndexPluginCompletionFunc(f),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := registry.New(f, NewEngine(f)).Get(args[0])
+			if err != nil {
+				return err
+			}
+			printEntryInfo(f.Streams().Out, e)
+			return nil
+		},
+	}
+}
+
+func printEntryInfo(out io.Writer, e *registry.Entry) {
+	fmt.Fprintf(out, "NAME: %s\n", e.Name)
+	fmt.Fprintf(out, "INDEX: %s\n", e.Index)
+	fmt.Fprintf(out, "KIND: %s\n", e.Kind)
+	if e.Version != "" {
+		fmt.Fprintf(out, "VERSION: %s\n", e.Version)
+	}
+	fmt.Fprintf(out, "INSTALLED: %t\n", e.Installed)
+	if e.Upgradable() {
+		fmt.Fprintf(out, "AVAILABLE VERSION: %s\n", e.Spec.Spec.Version)
+	}
+	if e.Path != "" {
+		fmt.Fprintf(out, "PATH: %s\n", e.Path)
+	}
+	if e.Plugin != nil {
+		fmt.Fprintf(out, "COMMAND: devctl %s\n", e.Plugin.Cmd)
+		if len(e.Plugin.Manifest.Permissions) > 0 {
+			fmt.Fprintf(out, "PERMISSIONS: %s\n", strings.Join(e.Plugin.Manifest.Permissions, ", "))
+		}
+	}
+
+	if e.Description != nil {
+		printDescription(out, e.Description)
+	}
+
+	if e.Spec == nil {
+		return
+	}
+	if platform, ok, err := installation.GetMatchingPlatform(e.Spec.Spec.Platforms); err == nil && ok {
+		if platform.URI != "" {
+			fmt.Fprintf(out, "URI: %s\n", platform.URI)
+			fmt.Fprintf(out, "SHA256: %s\n", platform.Sha256)
+		}
+	}
+	if e.Spec.Spec.Homepage != "" {
+		fmt.Fprintf(out, "HOMEPAGE: %s\n", e.Spec.Spec.Homepage)
+	}
+	if e.Spec.Spec.Description != "" {
+		fmt.Fprintf(out, "DESCRIPTION: \n%s\n", e.Spec.Spec.Description)
+	}
+	if e.Spec.Spec.Caveats != "" {
+		fmt.Fprintf(out, "CAVEATS:\n%s\n", printutils.Indent(e.Spec.Spec.Caveats))
+	}
+}
+
+func printDescription(out io.Writer, d *registry.Description) {
+	fmt.Fprintf(out, "COMPLETION: %t\n", d.Completion)
+	if len(d.Flags) > 0 {
+		fmt.Fprintf(out, "FLAGS:\n")
+		printFlags(out, d.Flags, "  ")
+	}
+	if len(d.Commands) > 0 {
+		fmt.Fprintf(out, "COMMANDS:\n")
+		printCommands(out, d.Commands, "  ")
+	}
+	if d.Long != "" {
+		fmt.Fprintf(out, "DESCRIPTION: \n%s\n", d.Long)
+	}
+}
+
+func printCommands(out io.Writer, cmds []registry.Command, indent string) {
+	for _, c := range cmds {
+		fmt.Fprintf(out, "%s%s\t%s\n", indent, c.Name, c.Short)
+		printFlags(out, c.Flags, indent+"  ")
+		printCommands(out, c.Commands, indent+"  ")
+	}
+}
+
+func printFlags(out io.Writer, flags []registry.Flag, indent string) {
+	for _, f := range flags {
+		fmt.Fprintf(out, "%s--%s\t%s\n", indent, f.Name, f.Usage)
+	}
+}
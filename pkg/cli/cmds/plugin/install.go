@@ -11,6 +11,7 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/klog/v2"
 
+	"github.com/alex-held/devctl/pkg/cli/completion"
 	"github.com/alex-held/devctl/pkg/constants"
 	"github.com/alex-held/devctl/pkg/env"
 	"github.com/alex-held/devctl/pkg/index/installation"
@@ -19,6 +20,8 @@ import (
 	"github.com/alex-held/devctl/pkg/index/scanner"
 	"github.com/alex-held/devctl/pkg/index/spec"
 	"github.com/alex-held/devctl/pkg/index/validate"
+	"github.com/alex-held/devctl/pkg/logging"
+	"github.com/alex-held/devctl/pkg/registry"
 )
 
 var (
@@ -78,22 +81,26 @@ Remarks:
 			}
 
 			var install []pluginEntry
+			r := registry.New(f, NewEngine(f))
 			for _, name := range pluginNames {
 				indexName, pluginName := pathutil.CanonicalPluginName(name)
 				if !validate.IsSafePluginName(pluginName) {
 					return unsafePluginNameErr(pluginName)
 				}
 
-				plugin, err := scanner.LoadPluginByName(f, f.Paths().IndexPluginsPath(indexName), pluginName)
+				entry, err := r.Get(indexName + "/" + pluginName)
 				if err != nil {
-					if os.IsNotExist(err) {
+					if errors.Is(err, registry.ErrNotFound) {
 						return errors.Errorf("plugin %q does not exist in the plugin index", name)
 					}
 					return errors.Wrapf(err, "failed to load plugin %q from the index", name)
 				}
+				if entry.Spec == nil {
+					return errors.Errorf("plugin %q is not available from an index", name)
+				}
 				install = append(install, pluginEntry{
-					p:         plugin,
-					indexName: indexName,
+					p:         *entry.Spec,
+					indexName: entry.Index,
 				})
 			}
 
@@ -129,7 +136,7 @@ Remarks:
 			var returnErr error
 			for _, entry := range install {
 				plugin := entry.p
-				f.Logger().Errorf("Installing plugin: %s\n", plugin.Name)
+				f.Logger().WithFields(logging.Fields{"plugin": plugin.Name, "index": entry.indexName, "version": plugin.Spec.Version}).Info("Installing plugin")
 				err := installation.Install(f, plugin, entry.indexName, installation.InstallOpts{
 					ArchiveFileOverride: *archiveFileOverride,
 				})
@@ -163,6 +170,7 @@ Remarks:
 			}
 			return nil
 		},
+		ValidArgsFunction: completion.IndexPluginCompletionFunc(f),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			if *manifest != "" {
 				log.Warnf("--manifest specified, not ensuring plugin index")
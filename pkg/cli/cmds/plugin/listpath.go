@@ -0,0 +1,119 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/cli/util"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/registry"
+)
+
+// newListPathCmd creates the 'devctl plugin list-path' command
+func newListPathCmd(f env.Factory) *cobra.Command {
+	o := &ListPathOptions{Out: f.Streams().Out}
+	cmd := &cobra.Command{
+		Use:   "list-path",
+		Short: "List all plugin executables on the PATH",
+		Long: `List all plugin executables on the PATH, i.e. all files starting with 'devctl-'.
+Warns about files which are not executable, plugins shadowed by a plugin of the same name
+in an earlier PATH entry and plugins which can't be invoked, because a builtin command
+of the same name exists. Exits with a non-zero code, if any warning has been found.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			o.Prefixes = validPrefixes()
+			o.Root = cmd.Root()
+			return o.Run()
+		},
+	}
+	cmd.Flags().BoolVar(&o.NameOnly, "name-only", o.NameOnly, "If true, display only the binary name of each plugin, rather than its full path")
+	return cmd
+}
+
+// ListPathOptions are the options of 'devctl plugin list-path'
+type ListPathOptions struct {
+	Out      io.Writer
+	NameOnly bool
+	Prefixes []string
+	// Root is the root command, whose builtin commands can't be overwritten by plugins
+	Root *cobra.Command
+}
+
+func validPrefixes() []string {
+	if h, ok := util.NewDefaultPluginHandler().(*util.DefaultPluginHandler); ok {
+		return h.ValidPrefixes
+	}
+	return []string{"devctl"}
+}
+
+// Run prints all plugin candidates on the PATH with their warnings
+func (o *ListPathOptions) Run() error {
+	candidates := registry.ScanPath(o.Prefixes...)
+	if len(candidates) == 0 {
+		return errors.New("unable to find any devctl plugins in your PATH")
+	}
+
+	out := o.Out
+	warnings := 0
+	fmt.Fprintln(out, "The following compatible plugins are available:")
+	fmt.Fprintln(out)
+	for _, c := range candidates {
+		if o.NameOnly {
+			fmt.Fprintln(out, c.Prefix+"-"+c.Name)
+		} else {
+			fmt.Fprintln(out, c.Path)
+		}
+		for _, w := range o.verify(c) {
+			fmt.Fprintf(out, "  - warning: %s\n", w)
+			warnings++
+		}
+	}
+
+	switch warnings {
+	case 0:
+		return nil
+	case 1:
+		return errors.New("one plugin warning was found")
+	default:
+		return errors.Errorf("%d plugin warnings were found", warnings)
+	}
+}
+
+func (o *ListPathOptions) verify(c registry.PathCandidate) (warnings []string) {
+	if !c.Executable {
+		warnings = append(warnings, fmt.Sprintf("%s identified as a plugin, but it is not executable", c.Path))
+	}
+	if c.ShadowedBy != "" {
+		warnings = append(warnings, fmt.Sprintf("%s is overshadowed by a similarly named plugin: %s", c.Path, c.ShadowedBy))
+	}
+	if o.Root != nil {
+		if cmd := o.builtin(c.Name); cmd != nil {
+			warnings = append(warnings, fmt.Sprintf("%s overwrites existing command: %q", c.Path, cmd.CommandPath()))
+		}
+	}
+	return warnings
+}
+
+// builtin returns the builtin command invoked instead of the plugin name, e.g. 'devctl foo bar' for devctl-foo-bar
+func (o *ListPathOptions) builtin(name string) *cobra.Command {
+	var path []string
+	for _, part := range strings.Split(name, "-") {
+		// devctl-foo_bar is invoked as 'devctl foo-bar'
+		path = append(path, strings.Replace(part, "_", "-", -1))
+	}
+
+	cmd, rest, err := o.Root.Find(path)
+	if err != nil || cmd == o.Root || len(rest) > 0 {
+		return nil
+	}
+	// commands listing plugins aren't builtin commands
+	if _, ok := cmd.Annotations[ExternalPluginAnnotation]; ok {
+		return nil
+	}
+	return cmd
+}
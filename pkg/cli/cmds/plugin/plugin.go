@@ -13,10 +13,14 @@ func NewCmd(f env.Factory) *cobra.Command {
 	}
 
 	cmd.AddCommand(newSearchCmd(f))
+	cmd.AddCommand(newInfoCmd(f))
+	cmd.AddCommand(newListPathCmd(f))
 	cmd.AddCommand(newUpdateCmd(f))
 	cmd.AddCommand(NewIndexCommand(f))
 	cmd.AddCommand(NewInstallCmd(f))
 	cmd.AddCommand(NewUninstallCmd(f))
-	
+	cmd.AddCommand(newDoctorCmd(f))
+	cmd.AddCommand(newVendorCmd(f))
+
 	return cmd
 }
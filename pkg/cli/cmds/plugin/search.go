@@ -5,15 +5,13 @@ import (
 	"runtime"
 	"strings"
 
-	"github.com/pkg/errors"
 	"github.com/sahilm/fuzzy"
 	"github.com/spf13/cobra"
 	"k8s.io/klog/v2"
 
 	"github.com/alex-held/devctl/pkg/env"
-	"github.com/alex-held/devctl/pkg/index/installation"
-	"github.com/alex-held/devctl/pkg/index/scanner"
 	"github.com/alex-held/devctl/pkg/index/spec"
+	"github.com/alex-held/devctl/pkg/registry"
 )
 
 type pluginEntry struct {
@@ -34,40 +32,19 @@ Examples:
   To fuzzy search plugins with a keyword:
     devctl index search KEYWORD`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			indexes, err := scanner.ListIndexes(f.Paths())
+			entries, err := registry.New(f, NewEngine(f)).Index()
 			if err != nil {
-				return errors.Wrap(err, "failed to list indexes")
+				return err
 			}
 
-			klog.V(3).Infof("found %d indexes", len(indexes))
+			klog.V(3).Infof("found %d plugins in the indexes", len(entries))
 
-			var plugins []pluginEntry
-			for _, idx := range indexes {
-				ps, err := scanner.LoadPluginsFromFS(f, idx.Name)
-				if err != nil {
-					return errors.Wrapf(err, "failed to load the list of plugins from the index %q", idx.Name)
-				}
-				for _, p := range ps {
-					plugins = append(plugins, pluginEntry{p, idx.Name})
-				}
-			}
-
-			pluginCanonicalNames := make([]string, len(plugins))
-			pluginCanonicalNameMap := make(map[string]pluginEntry, len(plugins))
-			for i, p := range plugins {
-				cn := canonicalName(p.p, p.indexName)
+			pluginCanonicalNames := make([]string, len(entries))
+			pluginCanonicalNameMap := make(map[string]*registry.Entry, len(entries))
+			for i, e := range entries {
+				cn := e.CanonicalName()
 				pluginCanonicalNames[i] = cn
-				pluginCanonicalNameMap[cn] = p
-			}
-
-			installed := make(map[string]bool)
-			receipts, err := installation.GetInstalledPluginReceipts(f)
-			if err != nil {
-				return errors.Wrap(err, "failed to load installed plugins")
-			}
-			for _, receipt := range receipts {
-				cn := canonicalName(receipt.Plugin, indexOf(receipt))
-				installed[cn] = true
+				pluginCanonicalNameMap[cn] = e
 			}
 
 			var searchResults []string
@@ -86,21 +63,19 @@ Examples:
 			}
 
 			var rows [][]string
-			cols := []string{"NAME", "DESCRIPTION", "INSTALLED"}
+			cols := []string{"NAME", "KIND", "DESCRIPTION", "INSTALLED"}
 			for _, canonicalName := range searchResults {
-				v := pluginCanonicalNameMap[canonicalName]
+				e := pluginCanonicalNameMap[canonicalName]
 				var status string
-				if installed[canonicalName] {
+				if e.Installed {
 					status = "yes"
-				} else if _, ok, err := installation.GetMatchingPlatform(v.p.Spec.Platforms); err != nil {
-					return errors.Wrapf(err, "failed to get the matching platform for plugin %s", canonicalName)
-				} else if ok {
+				} else if e.Supported {
 					status = "no"
 				} else {
 					status = fmt.Sprintf("unavailable on %v/%v", runtime.GOOS, runtime.GOARCH)
 				}
 
-				rows = append(rows, []string{displayName(v.p, v.indexName), limitString(v.p.Spec.ShortDescription, 50), status})
+				rows = append(rows, []string{e.DisplayName(), string(e.Kind), limitString(e.ShortDescription, 50), status})
 			}
 			rows = sortByFirstColumn(rows)
 			return printTable(f.Streams().Out, cols, rows)
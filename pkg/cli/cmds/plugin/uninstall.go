@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/klog/v2"
 
+	"github.com/alex-held/devctl/pkg/cli/completion"
 	"github.com/alex-held/devctl/pkg/env"
 	"github.com/alex-held/devctl/pkg/index/installation"
 	"github.com/alex-held/devctl/pkg/index/validate"
@@ -41,8 +42,9 @@ Remarks:
 		PreRunE: func(c *cobra.Command, args []string) error {
 			return checkIndex(f, c, args)
 		},
-		Args:    cobra.MinimumNArgs(1),
-		Aliases: []string{"remove"},
+		Args:              cobra.MinimumNArgs(1),
+		Aliases:           []string{"remove"},
+		ValidArgsFunction: completion.InstalledPluginCompletionFunc(f),
 	}
 }
 
@@ -29,6 +29,7 @@ import (
 	"github.com/alex-held/devctl/internal/git"
 	"github.com/alex-held/devctl/pkg/constants"
 	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/history"
 	"github.com/alex-held/devctl/pkg/index/installation"
 	"github.com/alex-held/devctl/pkg/index/scanner"
 )
@@ -61,9 +62,14 @@ func showFormattedPluginsInfo(out io.Writer, header string, plugins []string) {
 	fmt.Fprintf(out, "%s", b.String())
 }
 
+// EnsureIndexes adds the default index, if no index exists, and updates the local copies of all indexes
+func EnsureIndexes(f env.Factory) error {
+	return ensureIndexes(f, nil, nil)
+}
+
 func ensureIndexes(f env.Factory, c *cobra.Command, args []string) error {
 	log.Debugf("Will check if there are any indexes added.")
-	if err := ensureDefaultIndexIfNoneExist(f.Paths()); err != nil {
+	if err := ensureDefaultIndexIfNoneExist(f); err != nil {
 		return err
 	}
 	return ensureIndexesUpdated(f)
@@ -133,8 +139,8 @@ func loadPlugins(f env.Factory, indexes []scanner.Index) []pluginEntry {
 
 // ensureDefaultIndexIfNoneExist adds the default index automatically
 // (and informs the user about it) if no plugin index exists for krew.
-func ensureDefaultIndexIfNoneExist(paths env.Paths) error {
-	idx, err := scanner.ListIndexes(paths)
+func ensureDefaultIndexIfNoneExist(f env.Factory) error {
+	idx, err := scanner.ListIndexes(f.Paths())
 	if err != nil {
 		return errors.Wrap(err, "failed to retrieve plugin indexes")
 	}
@@ -146,8 +152,9 @@ func ensureDefaultIndexIfNoneExist(paths env.Paths) error {
 	klog.V(3).Infof("No index found, add default index.")
 	defaultIndex := scanner.DefaultIndex()
 	fmt.Fprintf(os.Stderr, "Adding \"default\" plugin index from %s.\n", defaultIndex)
-	return errors.Wrap(scanner.AddIndex(paths, constants.DefaultIndexName, defaultIndex),
-		"failed to add default plugin index in absence of no indexes")
+	err = scanner.AddIndex(f.Paths(), constants.DefaultIndexName, defaultIndex)
+	recordIndexAdd(f, constants.DefaultIndexName, defaultIndex, err)
+	return errors.Wrap(err, "failed to add default plugin index in absence of no indexes")
 }
 
 // ensureIndexesUpdated iterates over all indexes and updates them
@@ -166,7 +173,13 @@ func ensureIndexesUpdated(f env.Factory) error {
 	for _, idx := range indexes {
 		indexPath := f.Paths().IndexPath(idx.Name)
 		klog.V(1).Infof("Updating the local copy of plugin index (%s)", indexPath)
-		if err := git.EnsureUpdated(idx.URL, indexPath); err != nil {
+		before := history.IndexCommit(f.Paths(), idx.Name)
+		err := git.EnsureUpdated(idx.URL, indexPath)
+		// unchanged indexes are not recorded, they would flood the history
+		if after := history.IndexCommit(f.Paths(), idx.Name); err != nil || after != before {
+			history.Record(f, history.Entry{Action: history.ActionIndexUpdate, Index: idx.Name, Before: before, After: after}, err)
+		}
+		if err != nil {
 			klog.Warningf("failed to update index %q: %v", idx.Name, err)
 			failed = append(failed, idx.Name)
 			if returnErr == nil {
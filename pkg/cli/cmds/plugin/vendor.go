@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// newVendorCmd creates the 'devctl plugin vendor' command
+func newVendorCmd(f env.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:   "vendor [DIR]",
+		Short: "Vendor the dependencies of an interpreted plugin",
+		Long: `Vendor the dependencies of the interpreted plugin in DIR (defaults to the current directory).
+Runs 'go mod vendor' using the go.mod of the plugin and links the vendor directory
+to _gopath/src, from which the interpreter resolves the imports of the plugin.`,
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			return vendorPlugin(f, cmd, dir)
+		},
+	}
+}
+
+func vendorPlugin(f env.Factory, cmd *cobra.Command, dir string) error {
+	fs := f.Fs()
+	if _, err := fs.Stat(filepath.Join(dir, "go.mod")); err != nil {
+		return errors.Wrapf(err, "plugin directory %q has no go.mod", dir)
+	}
+	linker, ok := fs.(afero.Linker)
+	if !ok {
+		return errors.New("the file system does not support symlinks")
+	}
+
+	klog.V(2).Infof("running 'go mod vendor' in %s", dir)
+	goCmd := exec.CommandContext(cmd.Context(), "go", "mod", "vendor")
+	goCmd.Dir = dir
+	goCmd.Stdout = f.Streams().Out
+	goCmd.Stderr = f.Streams().ErrOut
+	if err := goCmd.Run(); err != nil {
+		return errors.Wrap(err, "failed to vendor the dependencies of the plugin")
+	}
+
+	gopath := filepath.Join(dir, "_gopath")
+	if err := fs.RemoveAll(gopath); err != nil {
+		return errors.Wrapf(err, "failed to remove %s", gopath)
+	}
+	if err := fs.MkdirAll(gopath, os.ModePerm); err != nil {
+		return errors.Wrapf(err, "failed to create %s", gopath)
+	}
+	if err := linker.SymlinkIfPossible(filepath.Join("..", "vendor"), filepath.Join(gopath, "src")); err != nil {
+		return errors.Wrapf(err, "failed to link the vendored dependencies into %s", gopath)
+	}
+
+	fmt.Fprintf(f.Streams().Out, "vendored the dependencies of the plugin into %s\n", gopath)
+	return nil
+}
@@ -0,0 +1,281 @@
+package profile
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/alex-held/devctl/pkg/cli/completion"
+	"github.com/alex-held/devctl/pkg/cli/util"
+	"github.com/alex-held/devctl/pkg/config"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/history"
+	"github.com/alex-held/devctl/pkg/profile"
+)
+
+// NewCmd creates the 'devctl profile' command
+func NewCmd(f env.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "switch between environment profiles",
+		Long: `Switch between named profiles of the devctl configuration, like kubectl contexts.
+A profile selects the plugin indexes to consult, the 'current' versions of the sdks,
+the zsh configuration and additional exports and aliases. The name of the active profile
+is exported to the shell as $DEVCTL_PROFILE.
+Examples:
+  To create a profile for a client project using go 1.16.5:
+    devctl profile create acme --index default --index acme --sdk go=1.16.5 --export GOPRIVATE=github.com/acme
+  To switch to it:
+    devctl profile use acme`,
+		Run: util.DefaultSubCommandRun(f.Streams().ErrOut),
+	}
+
+	cmd.AddCommand(newListCmd(f))
+	cmd.AddCommand(newUseCmd(f))
+	cmd.AddCommand(newCreateCmd(f))
+	cmd.AddCommand(newDeleteCmd(f))
+	return cmd
+}
+
+func newListCmd(f env.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:          "list",
+		Short:        "list the profiles",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			file, err := f.Config()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(f.Streams().Out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "CURRENT\tNAME\tINDEXES\tSDKS")
+			for _, name := range file.Config.ProfileNames() {
+				p := file.Config.Profiles[name]
+				current := ""
+				if name == file.Config.CurrentProfile {
+					current = "*"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", current, name, orAll(strings.Join(p.Indexes, ",")), formatSDKs(p.SDKs))
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func orAll(indexes string) string {
+	if indexes == "" {
+		return "<all>"
+	}
+	return indexes
+}
+
+func formatSDKs(sdks map[string]string) string {
+	var parts []string
+	for sdk, version := range sdks {
+		parts = append(parts, sdk+"="+version)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func newUseCmd(f env.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:               "use NAME",
+		Short:             "activate a profile",
+		Args:              cobra.ExactArgs(1),
+		SilenceUsage:      true,
+		ValidArgsFunction: profileCompletionFunc(f),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := f.Config()
+			if err != nil {
+				return err
+			}
+			return use(f, file, args[0])
+		},
+	}
+}
+
+// CreateOptions are the options of 'devctl profile create'
+type CreateOptions struct {
+	Indexes []string
+	SDKs    map[string]string
+	Zsh     string
+	Exports map[string]string
+	Aliases map[string]string
+	Use     bool
+}
+
+func newCreateCmd(f env.Factory) *cobra.Command {
+	o := &CreateOptions{}
+	cmd := &cobra.Command{
+		Use:          "create NAME",
+		Short:        "create a profile",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := f.Config()
+			if err != nil {
+				return err
+			}
+			name := args[0]
+			if !config.IsValidProfileName(name) {
+				return errors.Errorf("invalid profile name %q", name)
+			}
+			if _, ok := file.Config.Profiles[name]; ok {
+				return errors.Errorf("profile %q exists already", name)
+			}
+
+			if o.Zsh != "" && !strings.HasPrefix(o.Zsh, "{{") {
+				if o.Zsh, err = filepath.Abs(o.Zsh); err != nil {
+					return err
+				}
+			}
+			b, err := yaml.Marshal(config.Profile{
+				Indexes: o.Indexes,
+				SDKs:    o.SDKs,
+				Zsh:     o.Zsh,
+				Exports: o.Exports,
+				Aliases: o.Aliases,
+			})
+			if err != nil {
+				return err
+			}
+			if err = file.Set("profiles."+name, string(b)); err != nil {
+				return err
+			}
+			if err = file.Save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(f.Streams().Out, "Created profile %q.\n", name)
+
+			if !o.Use {
+				return nil
+			}
+			return use(f, file, name)
+		},
+	}
+	cmd.Flags().StringSliceVar(&o.Indexes, "index", o.Indexes, "A plugin index to consult, can be repeated (defaults to all indexes)")
+	cmd.Flags().StringToStringVar(&o.SDKs, "sdk", o.SDKs, "The current version of an sdk as NAME=VERSION, can be repeated")
+	cmd.Flags().StringVar(&o.Zsh, "zsh", o.Zsh, "The zsh configuration of the profile")
+	cmd.Flags().StringToStringVar(&o.Exports, "export", o.Exports, "An environment variable exported as KEY=VALUE, can be repeated")
+	cmd.Flags().StringToStringVar(&o.Aliases, "alias", o.Aliases, "A shell alias as NAME=COMMAND, can be repeated")
+	cmd.Flags().BoolVar(&o.Use, "use", o.Use, "If true, activate the profile")
+	_ = cmd.RegisterFlagCompletionFunc("index", completion.IndexCompletionFunc(f))
+	_ = cmd.RegisterFlagCompletionFunc("sdk", completion.SDKVersionCompletionFunc(f))
+	return cmd
+}
+
+// use activates the profile name and records it as current profile
+func use(f env.Factory, file *config.File, name string) error {
+	p, ok := file.Config.Profiles[name]
+	if !ok {
+		return errors.Errorf("no profile named %q", name)
+	}
+
+	before := map[string]string{}
+	for sdk := range p.SDKs {
+		before[sdk] = profile.CurrentSDKVersion(f.Pather(), sdk)
+	}
+	err := profile.Activate(f.Pather(), name, p)
+	recordSDKs(f, before, err)
+	if err != nil {
+		return err
+	}
+	if err := file.Set("currentProfile", name); err != nil {
+		return err
+	}
+	if err := file.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(f.Streams().Out, "Switched to profile %q.\n", name)
+	if p.Zsh != "" {
+		fmt.Fprintln(f.Streams().Out, "Run 'devctl zsh gen exports' and 'devctl zsh gen aliases' to render its zsh configuration.")
+	}
+	return nil
+}
+
+// recordSDKs records the sdks, whose current version has been changed by activating a profile, in the history
+func recordSDKs(f env.Factory, before map[string]string, err error) {
+	var sdks []string
+	for sdk := range before {
+		sdks = append(sdks, sdk)
+	}
+	sort.Strings(sdks)
+	for _, sdk := range sdks {
+		after := profile.CurrentSDKVersion(f.Pather(), sdk)
+		if after == before[sdk] && err == nil {
+			continue
+		}
+		history.Record(f, history.Entry{Action: history.ActionSDKUse, SDK: sdk, Before: before[sdk], After: after}, err)
+	}
+}
+
+func newDeleteCmd(f env.Factory) *cobra.Command {
+	return &cobra.Command{
+		Use:               "delete NAME",
+		Short:             "delete a profile",
+		Long:              "Delete a profile. Deleting the active profile leaves no profile active, the sdk and zsh links are kept.",
+		Args:              cobra.ExactArgs(1),
+		SilenceUsage:      true,
+		ValidArgsFunction: profileCompletionFunc(f),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := f.Config()
+			if err != nil {
+				return err
+			}
+			name := args[0]
+			if _, ok := file.Config.Profiles[name]; !ok {
+				return errors.Errorf("no profile named %q", name)
+			}
+
+			active := file.Config.CurrentProfile == name
+			if active {
+				if err = file.Unset("currentProfile"); err != nil {
+					return err
+				}
+			}
+			if err = file.Unset("profiles." + name); err != nil {
+				return err
+			}
+			if err = file.Save(); err != nil {
+				return err
+			}
+			if active {
+				if err = profile.Deactivate(f.Pather()); err != nil {
+					return err
+				}
+				fmt.Fprintf(f.Streams().Out, "Deleted the active profile %q, no profile is active now.\n", name)
+				return nil
+			}
+			fmt.Fprintf(f.Streams().Out, "Deleted profile %q.\n", name)
+			return nil
+		},
+	}
+}
+
+func profileCompletionFunc(f env.Factory) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		file, err := f.Config()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		var names []string
+		for _, name := range file.Config.ProfileNames() {
+			if strings.HasPrefix(name, toComplete) {
+				names = append(names, name)
+			}
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
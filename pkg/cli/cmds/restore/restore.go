@@ -0,0 +1,84 @@
+package restore
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/backup"
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// Options are the options of 'devctl restore'
+type Options struct {
+	DryRun     bool
+	OnConflict string
+}
+
+// NewCmd creates the 'devctl restore' command
+func NewCmd(f env.Factory) *cobra.Command {
+	o := &Options{OnConflict: string(backup.StrategyFail)}
+	cmd := &cobra.Command{
+		Use:   "restore FILE",
+		Short: "restore the devctl state from a backup",
+		Long: `Restore the devctl state from an archive written by 'devctl backup'.
+The indexes are added and pinned to their backed up commits, the configuration files are written,
+the plugins are installed from their indexes and the sdk versions are selected again.
+State, which exists already and matches the backup, is kept. State, which differs from the backup,
+is a conflict: by default nothing is restored, if there are conflicts. Use --on-conflict skip
+to keep the existing state or --on-conflict overwrite to replace it.
+Examples:
+  To show what would be restored:
+    devctl restore devctl-backup.tar.gz --dry-run
+  To restore everything, which doesn't exist yet:
+    devctl restore devctl-backup.tar.gz --on-conflict skip`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.Run(f, args[0])
+		},
+	}
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun, "If true, only print the plan")
+	cmd.Flags().StringVar(&o.OnConflict, "on-conflict", o.OnConflict, "How to handle existing state, which differs from the backup, one of fail|skip|overwrite")
+	_ = cmd.RegisterFlagCompletionFunc("on-conflict", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return []string{string(backup.StrategyFail), string(backup.StrategySkip), string(backup.StrategyOverwrite)}, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+// Run restores the backup at path
+func (o *Options) Run(f env.Factory, path string) error {
+	b, err := backup.Open(path)
+	if err != nil {
+		return err
+	}
+	plan, err := backup.PlanRestore(f, b, backup.Strategy(o.OnConflict))
+	if err != nil {
+		return err
+	}
+
+	out := f.Streams().Out
+	fmt.Fprintf(out, "Backup of devctl %s from %s:\n", b.Manifest.DevctlVersion, b.Manifest.Created.Local().Format("2006-01-02 15:04"))
+	if err = printPlan(out, plan); err != nil {
+		return err
+	}
+	if o.DryRun {
+		return nil
+	}
+	if err = plan.Apply(f); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Restored the backup.")
+	return nil
+}
+
+func printPlan(out io.Writer, plan *backup.Plan) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tACTION\tDETAIL")
+	for _, s := range plan.Steps {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Kind, s.Name, s.Action, s.Detail)
+	}
+	return w.Flush()
+}
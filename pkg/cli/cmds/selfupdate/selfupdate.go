@@ -0,0 +1,107 @@
+package selfupdate
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/cli/cmds/plugin"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/scanner"
+	"github.com/alex-held/devctl/pkg/index/spec"
+	"github.com/alex-held/devctl/pkg/selfupdate"
+)
+
+// Options are the options of 'devctl self-update'
+type Options struct {
+	Check         bool
+	NoUpdateIndex bool
+	Manifest      string
+	Archive       string
+}
+
+// NewCmd creates the 'devctl self-update' command
+func NewCmd(f env.Factory) *cobra.Command {
+	o := &Options{}
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "update devctl to the latest version",
+		Long: `Update devctl to the latest version offered by the plugin indexes.
+devctl is installed like a plugin from the "devctl" manifest of its index. The new version
+is downloaded and verified into the store, then the devctl link in the bin directory is
+replaced. The previous version is kept in the store, so that the update can be reverted
+using 'devctl history undo'; older versions are removed.
+A notice is printed at most once a day, when the local copy of the plugin indexes offers
+a new version. The indexes are not fetched for the notice, they are updated by
+'devctl self-update' and 'devctl plugin update'.
+Development builds are never updated and don't print the notice.
+Set $DEVCTL_NO_UPDATE_NOTICE to disable it.
+Examples:
+  To update devctl:
+    devctl self-update
+  To check for a new version without installing it:
+    devctl self-update --check`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return o.Run(f)
+		},
+	}
+	cmd.Flags().BoolVar(&o.Check, "check", o.Check, "If true, only print whether a new version is available")
+	cmd.Flags().BoolVar(&o.NoUpdateIndex, "no-update-index", o.NoUpdateIndex, "If true, don't update the local copy of the plugin indexes")
+	cmd.Flags().StringVar(&o.Manifest, "manifest", o.Manifest, "(Development-only) specify local devctl manifest file")
+	cmd.Flags().StringVar(&o.Archive, "archive", o.Archive, "(Development-only) force the download to use the specified file")
+	return cmd
+}
+
+// Run updates devctl
+func (o *Options) Run(f env.Factory) error {
+	if o.Archive != "" && o.Manifest == "" {
+		return errors.New("--archive can be specified only with --manifest")
+	}
+	if !o.NoUpdateIndex && o.Manifest == "" {
+		if err := plugin.EnsureIndexes(f); err != nil {
+			return err
+		}
+	}
+
+	latest, indexName, err := o.latest(f)
+	if err != nil {
+		return err
+	}
+
+	out := f.Streams().Out
+	current := selfupdate.Current(f)
+	if o.Check {
+		if selfupdate.IsNewer(latest, current) {
+			fmt.Fprintf(out, "A new version of devctl is available: %s -> %s\n", current, latest.Spec.Version)
+			return nil
+		}
+		fmt.Fprintf(out, "devctl %s is up to date.\n", current)
+		return nil
+	}
+
+	err = selfupdate.Update(f, latest, indexName, installation.InstallOpts{ArchiveFileOverride: o.Archive})
+	if err == installation.ErrIsAlreadyUpgraded {
+		fmt.Fprintf(out, "devctl %s is up to date.\n", current)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to update devctl")
+	}
+	fmt.Fprintf(out, "Updated devctl %s -> %s.\n", current, latest.Spec.Version)
+	return nil
+}
+
+func (o *Options) latest(f env.Factory) (spec.Plugin, string, error) {
+	if o.Manifest == "" {
+		return selfupdate.Latest(f)
+	}
+	p, err := scanner.ReadPluginFromFile(f.Fs(), o.Manifest)
+	if err != nil {
+		return p, "", errors.Wrap(err, "failed to load devctl manifest from file")
+	}
+	return p, "detached", nil
+}
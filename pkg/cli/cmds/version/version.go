@@ -0,0 +1,67 @@
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/version"
+)
+
+// Options are the options of 'devctl version'
+type Options struct {
+	Output string
+}
+
+// NewCmd creates the 'devctl version' command
+func NewCmd(f env.Factory) *cobra.Command {
+	o := &Options{Output: "text"}
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "print the version of devctl",
+		Long: `Print the version, the commit and the build date of devctl together with the Go version and platform it has been built for.
+Examples:
+  To print the version as JSON:
+    devctl version -o json`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return o.Run(f.Streams().Out)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format, one of text|json")
+	return cmd
+}
+
+// Run prints the build information to out
+func (o *Options) Run(out io.Writer) error {
+	info := version.Get()
+	switch o.Output {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	case "text", "":
+		w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+		fmt.Fprintf(w, "Version:\t%s\n", info.Version)
+		fmt.Fprintf(w, "Git Commit:\t%s\n", orUnknown(info.GitCommit))
+		fmt.Fprintf(w, "Build Date:\t%s\n", orUnknown(info.BuildDate))
+		fmt.Fprintf(w, "Go Version:\t%s\n", info.GoVersion)
+		fmt.Fprintf(w, "Platform:\t%s\n", info.Platform)
+		return w.Flush()
+	default:
+		return errors.Errorf("unknown output format %q, use text or json", o.Output)
+	}
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,186 @@
+package completion
+
+import (
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alex-held/devctl/pkg/constants"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/scanner"
+	"github.com/alex-held/devctl/pkg/registry"
+)
+
+// IndexNames returns the names of all configured plugin indexes
+func IndexNames(f env.Factory) (names []string) {
+	entries, err := ioutil.ReadDir(f.Paths().IndexBase())
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+// IndexPluginNames returns the names of all plugins available in the configured indexes.
+// Plugins of custom indexes are returned using the INDEX/NAME syntax.
+func IndexPluginNames(f env.Factory) (names []string) {
+	for _, index := range IndexNames(f) {
+		plugins, _ := scanner.LoadPluginsFromFS(f, index)
+		for _, p := range plugins {
+			if index == constants.DefaultIndexName {
+				names = append(names, p.Name)
+				continue
+			}
+			names = append(names, index+"/"+p.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InstalledPluginNames returns the names of all installed plugins
+func InstalledPluginNames(f env.Factory) (names []string) {
+	receipts, err := installation.GetInstalledPluginReceipts(f)
+	if err != nil {
+		return nil
+	}
+	for _, r := range receipts {
+		names = append(names, r.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SDKNames returns the names of all sdks, which have a version installed
+func SDKNames(f env.Factory) (names []string) {
+	entries, err := ioutil.ReadDir(f.Pather().SDK())
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+// SDKVersions returns the installed versions of sdk
+func SDKVersions(f env.Factory, sdk string) (versions []string) {
+	entries, err := ioutil.ReadDir(f.Pather().SDK(sdk))
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != "current" {
+			versions = append(versions, e.Name())
+		}
+	}
+	return versions
+}
+
+// PathPluginNames returns the names of all executables on PATH starting with 'prefix-',
+// with the prefix removed. Executables shadowed by an earlier PATH entry are omitted.
+func PathPluginNames(prefix string) (names []string) {
+	for _, p := range registry.PathPlugins(prefix) {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// RegisterPluginCommands adds a command for each 'devctl-*' executable on PATH to root,
+// so that external plugins are offered as subcommands during shell completion.
+// Executables named like 'devctl-foo-bar' are registered as 'foo bar'.
+func RegisterPluginCommands(root *cobra.Command) {
+	for _, name := range PathPluginNames(root.Name()) {
+		parent := root
+		for _, part := range strings.Split(name, "-") {
+			// plugins can't overwrite builtin commands
+			if cmd, _, err := parent.Find([]string{part}); err == nil && cmd != parent {
+				parent = cmd
+				continue
+			}
+			cmd := &cobra.Command{
+				Use:                part,
+				Short:              "The command " + part + " is a plugin",
+				DisableFlagParsing: true,
+				Run:                func(*cobra.Command, []string) {},
+			}
+			parent.AddCommand(cmd)
+			parent = cmd
+		}
+	}
+}
+
+// CompletionFunc returns a cobra.Command.ValidArgsFunction completing the values returned by list,
+// which haven't already been provided as arguments
+func CompletionFunc(list func() []string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		provided := map[string]bool{}
+		for _, arg := range args {
+			provided[arg] = true
+		}
+
+		var comps []string
+		for _, v := range list() {
+			if !provided[v] && strings.HasPrefix(v, toComplete) {
+				comps = append(comps, v)
+			}
+		}
+		return comps, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// IndexPluginCompletionFunc completes the names of plugins available in the configured indexes
+func IndexPluginCompletionFunc(f env.Factory) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return CompletionFunc(func() []string { return IndexPluginNames(f) })
+}
+
+// InstalledPluginCompletionFunc completes the names of installed plugins
+func InstalledPluginCompletionFunc(f env.Factory) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return CompletionFunc(func() []string { return InstalledPluginNames(f) })
+}
+
+// IndexCompletionFunc completes the name of a single configured index
+func IndexCompletionFunc(f env.Factory) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	complete := CompletionFunc(func() []string { return IndexNames(f) })
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return complete(cmd, args, toComplete)
+	}
+}
+
+// SDKVersionCompletionFunc completes an installed sdk version as NAME=VERSION,
+// e.g. for the value of a StringToString flag like '--sdk go=1.16.5'
+func SDKVersionCompletionFunc(f env.Factory) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		i := strings.Index(toComplete, "=")
+		if i < 0 {
+			var comps []string
+			for _, sdk := range SDKNames(f) {
+				if strings.HasPrefix(sdk, toComplete) {
+					comps = append(comps, sdk+"=")
+				}
+			}
+			return comps, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+		}
+
+		sdk, prefix := toComplete[:i], toComplete[i+1:]
+		var comps []string
+		for _, v := range SDKVersions(f, sdk) {
+			if strings.HasPrefix(v, prefix) {
+				comps = append(comps, sdk+"="+v)
+			}
+		}
+		return comps, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,101 @@
+package completion
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/devctl/pkg/constants"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/spec"
+)
+
+const fooManifest = `apiVersion: alexheld.io/devctl/index/v1alpha1
+kind: Plugin
+metadata:
+  name: foo
+spec:
+  version: v1.0.0
+  platforms:
+    - uri: https://example.com
+      sha256: deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef
+      bin: devctl-foo
+      files:
+        - from: "*"
+          to: "."
+      selector:
+        matchLabels:
+          os: linux
+  shortDescription: foo
+`
+
+func mkdir(t *testing.T, path ...string) string {
+	dir := filepath.Join(path...)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	return dir
+}
+
+func newTree(t *testing.T) env.Factory {
+	tmp, err := ioutil.TempDir("", "devctl-completion")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(tmp) })
+	f := env.NewFactory(env.WithPaths(tmp))
+
+	for _, index := range []string{constants.DefaultIndexName, "acme"} {
+		dir := mkdir(t, f.Paths().IndexPluginsPath(index))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "foo.yaml"), []byte(fooManifest), 0644))
+	}
+
+	mkdir(t, f.Paths().InstallReceiptsPath())
+	for _, name := range []string{"foo", "bar"} {
+		r := spec.Receipt{}
+		r.Name, r.Spec.Version = name, "v1.0.0"
+		require.NoError(t, installation.Store(f.Fs(), r, f.Paths().PluginInstallReceiptPath(name)))
+	}
+
+	mkdir(t, f.Pather().SDK("go", "1.16.5"))
+	mkdir(t, f.Pather().SDK("go", "1.17"))
+	mkdir(t, f.Pather().SDK("go", "current"))
+	mkdir(t, f.Pather().SDK("java", "11"))
+	return f
+}
+
+func complete(fn func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective), args []string, toComplete string) []string {
+	comps, _ := fn(&cobra.Command{}, args, toComplete)
+	return comps
+}
+
+func TestCompletionFuncs(t *testing.T) {
+	f := newTree(t)
+
+	assert.Equal(t, []string{"acme", constants.DefaultIndexName}, IndexNames(f))
+	assert.Equal(t, []string{"acme/foo", "foo"}, IndexPluginNames(f))
+	assert.Equal(t, []string{"bar", "foo"}, InstalledPluginNames(f))
+	assert.Equal(t, []string{"go", "java"}, SDKNames(f))
+	assert.Equal(t, []string{"1.16.5", "1.17"}, SDKVersions(f, "go"))
+
+	assert.Equal(t, []string{"acme/foo"}, complete(IndexPluginCompletionFunc(f), nil, "a"))
+	assert.Equal(t, []string{"bar"}, complete(InstalledPluginCompletionFunc(f), []string{"foo"}, ""), "provided arguments are not completed again")
+	assert.Equal(t, []string{"acme", constants.DefaultIndexName}, complete(IndexCompletionFunc(f), nil, ""))
+	assert.Empty(t, complete(IndexCompletionFunc(f), []string{"acme"}, ""), "only a single index is completed")
+}
+
+func TestSDKVersionCompletionFunc(t *testing.T) {
+	fn := SDKVersionCompletionFunc(newTree(t))
+
+	comps, directive := fn(&cobra.Command{}, nil, "")
+	assert.Equal(t, []string{"go=", "java="}, comps)
+	assert.Equal(t, cobra.ShellCompDirectiveNoFileComp|cobra.ShellCompDirectiveNoSpace, directive)
+
+	comps, directive = fn(&cobra.Command{}, nil, "go=1.1")
+	assert.Equal(t, []string{"go=1.16.5", "go=1.17"}, comps)
+	assert.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+
+	assert.Empty(t, complete(fn, nil, "rust="))
+}
@@ -1,12 +1,15 @@
 package options
 
 import (
-	"os"
+	"strings"
 	"sync"
 
 	"github.com/spf13/pflag"
 )
 
+// DevctlPathFlag is the name of the flag overriding the devctl root directory
+const DevctlPathFlag = "devctl-path"
+
 type ConfigFlags struct {
 	DevctlRoot *string
 
@@ -15,7 +18,7 @@ type ConfigFlags struct {
 
 func NewConfigFlags() *ConfigFlags {
 	return &ConfigFlags{
-		DevctlRoot: stringptr(os.ExpandEnv("$HOME/.devctl")),
+		DevctlRoot: stringptr(""),
 		lock:       sync.Mutex{},
 	}
 }
@@ -27,6 +30,22 @@ func stringptr(s string) *string {
 // AddFlags binds client configuration flags to a given flagset
 func (f *ConfigFlags) AddFlags(flags *pflag.FlagSet) {
 	if f.DevctlRoot != nil {
-		flags.StringVar(f.DevctlRoot, "devctl-path", *f.DevctlRoot, "Path to the devctl root")
+		flags.StringVar(f.DevctlRoot, DevctlPathFlag, *f.DevctlRoot, "Path to the devctl root (defaults to $DEVCTL_ROOT, the configured root or layout, or ~/.devctl)")
+	}
+}
+
+// DevctlPathFromArgs returns the value of the --devctl-path flag in args.
+// The commands are created before the flags are parsed, which is why the flag needs to be looked up in advance.
+func DevctlPathFromArgs(args []string) string {
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return ""
+		case arg == "--"+DevctlPathFlag && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, "--"+DevctlPathFlag+"="):
+			return strings.TrimPrefix(arg, "--"+DevctlPathFlag+"=")
+		}
 	}
+	return ""
 }
@@ -1,32 +1,92 @@
 package cli
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
-
-	"github.com/alex-held/devctl/pkg/cli/cmds/plugin"
+	"k8s.io/klog/v2"
+
+	cmdbackup "github.com/alex-held/devctl/pkg/cli/cmds/backup"
+	cmdcompletion "github.com/alex-held/devctl/pkg/cli/cmds/completion"
+	cmdconfig "github.com/alex-held/devctl/pkg/cli/cmds/config"
+	"github.com/alex-held/devctl/pkg/cli/cmds/doctor"
+	"github.com/alex-held/devctl/pkg/cli/cmds/dotfiles"
+	cmdhistory "github.com/alex-held/devctl/pkg/cli/cmds/history"
 	"github.com/alex-held/devctl/pkg/cli/cmds/info"
 	"github.com/alex-held/devctl/pkg/cli/cmds/list"
+	"github.com/alex-held/devctl/pkg/cli/cmds/migrate"
+	"github.com/alex-held/devctl/pkg/cli/cmds/plugin"
+	"github.com/alex-held/devctl/pkg/cli/cmds/profile"
+	"github.com/alex-held/devctl/pkg/cli/cmds/restore"
+	"github.com/alex-held/devctl/pkg/cli/cmds/selfupdate"
+	cmdversion "github.com/alex-held/devctl/pkg/cli/cmds/version"
+	"github.com/alex-held/devctl/pkg/cli/completion"
 	cliflag "github.com/alex-held/devctl/pkg/cli/flags"
 	"github.com/alex-held/devctl/pkg/cli/options"
 	"github.com/alex-held/devctl/pkg/cli/templates"
 	"github.com/alex-held/devctl/pkg/cli/util"
 	"github.com/alex-held/devctl/pkg/env"
+	devctlerrors "github.com/alex-held/devctl/pkg/errors"
+	"github.com/alex-held/devctl/pkg/logging"
+	"github.com/alex-held/devctl/pkg/pluginrpc"
+	"github.com/alex-held/devctl/pkg/registry"
+	devctlselfupdate "github.com/alex-held/devctl/pkg/selfupdate"
 )
 
 // NewDefaultKubectlCommand creates the `kubectl` command with default arguments
 func NewDefaultKubectlCommand() *cobra.Command {
-	return NewDefaultKubectlCommandWithArgs(util.NewDefaultPluginHandler(), os.Args, os.Stdin, os.Stdout, os.Stderr)
+	f := newFactory(os.Args, os.Stdin, os.Stdout, os.Stderr)
+	return newDefaultKubectlCommand(f, newPluginHandler(f), os.Args, os.Stderr)
+}
+
+// newPluginHandler returns the handler running plugins as configured for f: plugins built with the plugin SDK use
+// the plugin protocol, other plugins are executed using the configured exec mode
+func newPluginHandler(f env.Factory) util.PluginHandler {
+	return registry.NewPluginHandler(f.Paths(), pluginrpc.NewPluginHandler(f, newDefaultPluginHandler(f)))
+}
+
+// newFactory creates the factory for the devctl root selected by the --devctl-path flag in args
+func newFactory(args []string, in io.Reader, out, errout io.Writer) env.Factory {
+	var root string
+	if len(args) > 1 {
+		root = options.DevctlPathFromArgs(args[1:])
+	}
+	return env.NewFactory(env.WithIO(in, out, errout), env.WithDevctlPath(root))
+}
+
+// newDefaultPluginHandler returns a util.DefaultPluginHandler using the exec mode selected by the environment or the configuration
+func newDefaultPluginHandler(f env.Factory) util.PluginHandler {
+	h := util.NewDefaultPluginHandler().(*util.DefaultPluginHandler)
+	if os.Getenv(util.ExecModeEnvVar) != "" {
+		return h
+	}
+	file, err := f.Config()
+	if err != nil {
+		// reported by the 'devctl config' commands
+		klog.V(1).Infof("failed to load the configuration: %v", err)
+		return h
+	}
+	if file.Config.PluginExecMode != "" {
+		h.Mode = util.SelectExecMode(file.Config.PluginExecMode)
+	}
+	return h
 }
 
 // NewDefaultKubectlCommandWithArgs creates the `kubectl` command with arguments
 func NewDefaultKubectlCommandWithArgs(pluginHandler util.PluginHandler, args []string, in io.Reader, out, errout io.Writer) *cobra.Command {
-	cmd := NewDevctlCommand(in, out, errout)
+	return newDefaultKubectlCommand(newFactory(args, in, out, errout), pluginHandler, args, errout)
+}
+
+// newDefaultKubectlCommand creates the `kubectl` command using f, so that the factory, and therefore its warnings, are created once
+func newDefaultKubectlCommand(f env.Factory, pluginHandler util.PluginHandler, args []string, errout io.Writer) *cobra.Command {
+	cmd := NewDevctlCommandWithFactory(f)
 
 	if pluginHandler == nil {
 		return cmd
@@ -35,12 +95,23 @@ func NewDefaultKubectlCommandWithArgs(pluginHandler util.PluginHandler, args []s
 	if len(args) > 1 {
 		cmdPathPieces := args[1:]
 
+		// offer devctl-* plugins on PATH as subcommands while completing
+		if cmdPathPieces[0] == cobra.ShellCompRequestCmd || cmdPathPieces[0] == cobra.ShellCompNoDescRequestCmd {
+			completion.RegisterPluginCommands(cmd)
+			return cmd
+		}
+
 		// only look for suitable extension executables if
 		// the specified command does not already exist
-		if _, _, err := cmd.Find(cmdPathPieces); err != nil {
+		// external plugins are listed as commands, but still dispatched to the plugin handler
+		if found, _, err := cmd.Find(cmdPathPieces); err != nil || isExternalPluginCmd(found) {
 			if err := HandlePluginCommand(pluginHandler, cmdPathPieces); err != nil {
+				var exitErr *devctlerrors.ExitError
+				if errors.As(err, &exitErr) {
+					exit(exitErr.Code)
+				}
 				fmt.Fprintf(errout, "Error: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		}
 	}
@@ -48,8 +119,33 @@ func NewDefaultKubectlCommandWithArgs(pluginHandler util.PluginHandler, args []s
 	return cmd
 }
 
+// describesPlugins returns true, if the command line args show the help of devctl or complete,
+// which requires the descriptions of the external plugins
+func describesPlugins(args []string) bool {
+	for _, arg := range args {
+		switch {
+		case arg == "-h" || arg == "--help":
+			return true
+		case strings.HasPrefix(arg, "-"):
+			continue
+		default:
+			return arg == "help" || arg == cobra.ShellCompRequestCmd || arg == cobra.ShellCompNoDescRequestCmd
+		}
+	}
+	return true
+}
+
+// exit terminates devctl after a plugin has been executed; it is replaced in tests
+var exit = os.Exit
+
+func isExternalPluginCmd(cmd *cobra.Command) bool {
+	_, ok := cmd.Annotations[plugin.ExternalPluginAnnotation]
+	return ok
+}
+
 // HandlePluginCommand receives a pluginHandler and command-line arguments and attempts to find
 // a plugin executable on the PATH that satisfies the given arguments.
+// devctl exits once the plugin has been executed successfully, so the arguments are not handled by cobra again.
 func HandlePluginCommand(pluginHandler util.PluginHandler, cmdArgs []string) error {
 	var remainingArgs []string // all "non-flag" arguments
 	for _, arg := range cmdArgs {
@@ -87,11 +183,42 @@ func HandlePluginCommand(pluginHandler util.PluginHandler, cmdArgs []string) err
 		return err
 	}
 
+	// plugins executed as child process or using the plugin protocol return
+	exit(0)
 	return nil
 }
 
+// verbosity returns the value of the -v flag registered by klog
+func verbosity(cmd *cobra.Command) int {
+	flag := cmd.Flags().Lookup("v")
+	if flag == nil {
+		return 0
+	}
+	v, _ := strconv.Atoi(flag.Value.String())
+	return v
+}
+
+// printUpdateNotice announces a new version of devctl after cmd has run, unless the command completes
+// the command line or updates devctl anyways
+func printUpdateNotice(f env.Factory, cmd *cobra.Command) {
+	switch cmd.Name() {
+	case cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd, "completion", "self-update":
+		return
+	}
+	if notice := devctlselfupdate.Notice(f, time.Now()); notice != "" {
+		fmt.Fprintf(f.Streams().ErrOut, "\n%s\n", notice)
+	}
+}
+
+// NewDevctlCommand creates the `devctl` command for the devctl root selected by the --devctl-path flag in os.Args
 func NewDevctlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
+	return NewDevctlCommandWithFactory(newFactory(os.Args, in, out, err))
+}
+
+// NewDevctlCommandWithFactory creates the `devctl` command using f
+func NewDevctlCommandWithFactory(f env.Factory) *cobra.Command {
 	warningsAsErrors := false
+	logOptions := logging.NewOptions()
 
 	// Parent command to which all subcommands are added.
 	cmds := &cobra.Command{
@@ -104,6 +231,13 @@ func NewDevctlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			_ = cmd.Help()
 		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			logOptions.Verbosity = verbosity(cmd)
+			return f.Logger().Configure(*logOptions)
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			printUpdateNotice(f, cmd)
+		},
 	}
 
 	flags := cmds.PersistentFlags()
@@ -117,23 +251,32 @@ func NewDevctlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 
 	devctlFlags := options.NewConfigFlags()
 	devctlFlags.AddFlags(flags)
+	logOptions.AddFlags(flags)
 
 	// Updates hooks to add kubectl command headers: SIG CLI KEP 859.
 	// addCmdHeaderHooks(cmds, kubeConfigFlags)
 
-	cmds.PersistentFlags().AddGoFlagSet(flag.CommandLine)
+	// klog flags like -v, e.g. -v 2 reports plugin load timings. klog is routed through f.Logger().
+	// The flags are registered on their own flag set, as the command may be created more than once.
+	klogFlags := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(klogFlags)
+	flags.AddGoFlagSet(klogFlags)
+	// the output of klog is written by the logger, --log-file and --log-format replace the klog output flags.
+	// Only -v is shown, the other klog flags are for debugging klog itself.
+	for _, name := range []string{"add-dir-header", "alsologtostderr", "log-backtrace-at", "log-dir", "log-file-max-size", "logtostderr", "one-output", "skip-headers", "skip-log-headers", "stderrthreshold", "vmodule"} {
+		_ = flags.MarkHidden(name)
+	}
 
 	// From this point and forward we get warnings on flags that contain "_" separators
 	cmds.SetGlobalNormalizationFunc(cliflag.WarnWordSepNormalizeFunc)
 
-	f := env.NewFactory()
-
 	groups := templates.CommandGroups{
 		{
 			Message: "Basic Commands (Beginner):",
 			Commands: []*cobra.Command{
-				list.NewCmdCreate(f),
+				list.NewCmd(f),
 				info.NewCmd(f),
+				cmdversion.NewCmd(f),
 			},
 		},
 		{
@@ -142,6 +285,26 @@ func NewDevctlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 				plugin.NewCmd(f),
 			},
 		},
+		{
+			Message: "Troubleshooting and Debugging Commands:",
+			Commands: []*cobra.Command{
+				doctor.NewCmd(f),
+				cmdhistory.NewCmd(f),
+			},
+		},
+		{
+			Message: "Settings Commands:",
+			Commands: []*cobra.Command{
+				cmdbackup.NewCmd(f),
+				cmdcompletion.NewCmd(f),
+				cmdconfig.NewCmd(f),
+				dotfiles.NewCmd(f),
+				migrate.NewCmd(f),
+				profile.NewCmd(f),
+				restore.NewCmd(f),
+				selfupdate.NewCmd(f),
+			},
+		},
 		// {
 		// 	Message: "Deploy Commands:",
 		// 	Commands: []*cobra.Command{
@@ -196,6 +359,27 @@ func NewDevctlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 		// 	},
 		// },
 	}
+
+	var builtin []*cobra.Command
+	for _, group := range groups {
+		builtin = append(builtin, group.Commands...)
+	}
+	engine := plugin.NewEngine(f)
+	if interpreted, _ := plugin.NewInterpretedCmds(engine, builtin); len(interpreted) > 0 {
+		// load errors are reported by 'devctl plugin doctor'
+		groups = append(groups, templates.CommandGroup{
+			Message:  "Plugin Commands:",
+			Commands: interpreted,
+		})
+		builtin = append(builtin, interpreted...)
+	}
+	// external plugins are only invoked to describe themselves, if their descriptions are shown
+	if external, _ := plugin.NewExternalCmds(registry.New(f, engine), builtin, newPluginHandler(f), describesPlugins(os.Args[1:])); len(external) > 0 {
+		groups = append(groups, templates.CommandGroup{
+			Message:  "External Plugin Commands:",
+			Commands: external,
+		})
+	}
 	groups.Add(cmds)
 
 	//	filters := []string{"options"}
@@ -215,7 +399,6 @@ func NewDevctlCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 	// cmds.AddCommand(alpha)
 	// cmds.AddCommand(cmdconfig.NewCmdConfig(clientcmd.NewDefaultPathOptions(), ioStreams))
 	// cmds.AddCommand(plugin.NewCmdPlugin(ioStreams))
-	// cmds.AddCommand(version.NewCmdVersion(f, ioStreams))
 	// cmds.AddCommand(apiresources.NewCmdAPIVersions(f, ioStreams))
 	// cmds.AddCommand(apiresources.NewCmdAPIResources(f, ioStreams))
 	// cmds.AddCommand(options.NewCmdOptions(ioStreams.Out))
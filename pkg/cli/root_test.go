@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// countingPluginHandler provides the plugin devctl-foo and counts its executions
+type countingPluginHandler struct {
+	executions int
+}
+
+func (h *countingPluginHandler) Lookup(name string) (string, bool) {
+	return "/bin/devctl-foo", name == "foo"
+}
+
+func (h *countingPluginHandler) Execute(string, []string, []string) error {
+	h.executions++
+	return nil
+}
+
+func TestNewDefaultKubectlCommandWithArgs_ExitsAfterPlugin(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "devctl-root")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	var codes []int
+	defer func(orig func(int)) { exit = orig }(exit)
+	exit = func(code int) { codes = append(codes, code) }
+
+	h := &countingPluginHandler{}
+	out := &bytes.Buffer{}
+	NewDefaultKubectlCommandWithArgs(h, []string{"devctl", "foo", "--devctl-path", tmp}, &bytes.Buffer{}, out, out)
+	assert.Equal(t, 1, h.executions)
+	assert.Equal(t, []int{0}, codes, "devctl exits instead of running the plugin again")
+}
+
+func TestNewDevctlCommandWithFactory_Twice(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "devctl-root")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	out := &bytes.Buffer{}
+	f := env.NewFactory(env.WithPaths(tmp), env.WithIO(&bytes.Buffer{}, out, out))
+	for n := 0; n < 2; n++ {
+		assert.NotPanics(t, func() {
+			cmd := NewDevctlCommandWithFactory(f)
+			assert.NotNil(t, cmd.PersistentFlags().Lookup("v"))
+		})
+	}
+}
+
+func TestNewDevctlCommandWithFactory_Flags(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "devctl-root")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	out := &bytes.Buffer{}
+	flags := NewDevctlCommandWithFactory(env.NewFactory(env.WithPaths(tmp), env.WithIO(&bytes.Buffer{}, out, out))).PersistentFlags()
+	assert.False(t, flags.Lookup("v").Hidden)
+	for _, name := range []string{"log-backtrace-at", "vmodule", "logtostderr"} {
+		require.NotNil(t, flags.Lookup(name), name)
+		assert.True(t, flags.Lookup(name).Hidden, name)
+	}
+	assert.Nil(t, flags.Lookup("quickchecks"), "the flags of the go flag.CommandLine are not added")
+}
+
+func TestDescribesPlugins(t *testing.T) {
+	for args, expected := range map[string]bool{
+		"":                       true,
+		"help foo":               true,
+		"--help":                 true,
+		"-v=2 --help":            true,
+		"__complete foo ":        true,
+		"version":                false,
+		"zsh init":               false,
+		"foo --help":             false,
+		"--log-format json list": false,
+	} {
+		assert.Equal(t, expected, describesPlugins(strings.Fields(args)), args)
+	}
+}
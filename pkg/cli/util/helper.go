@@ -2,6 +2,7 @@ package util
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -72,9 +73,13 @@ func checkErr(err error, handleErr func(string, int)) {
 		return
 	}
 
+	var exitErr *devctlerrors.ExitError
 	switch {
 	case err == devctlerrors.ErrExit:
 		handleErr("", DefaultErrorExitCode)
+	case errors.As(err, &exitErr):
+		// the plugin has already reported the error itself
+		handleErr("", exitErr.Code)
 	default:
 		switch err := err.(type) {
 		case utilerrors.Aggregate:
@@ -1,9 +1,12 @@
 package util
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
-	"runtime"
+	"os/signal"
 	"syscall"
 
 	"github.com/spf13/afero"
@@ -16,16 +19,55 @@ type PluginHandler interface {
 	Execute(executablePath string, cmdArgs, environment []string) (err error)
 }
 
+// ExecMode selects how DefaultPluginHandler executes plugins
+type ExecMode string
+
+const (
+	// ExecModeReplace replaces the devctl process with the plugin using execve
+	ExecModeReplace ExecMode = "replace"
+	// ExecModeChild runs the plugin as child process and waits for it to exit
+	ExecModeChild ExecMode = "child"
+)
+
+// ExecModeEnvVar is the environment variable selecting the ExecMode
+const ExecModeEnvVar = "DEVCTL_PLUGIN_EXEC_MODE"
+
+// DefaultExecMode returns the ExecMode selected by ExecModeEnvVar,
+// or ExecModeChild if the platform doesn't support ExecModeReplace.
+func DefaultExecMode() ExecMode {
+	return SelectExecMode(os.Getenv(ExecModeEnvVar))
+}
+
+// SelectExecMode returns the ExecMode named mode, if the platform supports it, and the default ExecMode otherwise
+func SelectExecMode(mode string) ExecMode {
+	switch {
+	case ExecMode(mode) == ExecModeChild, !execReplaceSupported:
+		return ExecModeChild
+	default:
+		return ExecModeReplace
+	}
+}
+
 // DefaultPluginHandler implements PluginHandler
 type DefaultPluginHandler struct {
 	ValidPrefixes []string
 	Fs            afero.Fs
+	Mode          ExecMode
+
+	// Stdin, Stdout and Stderr are passed to plugins executed as child process
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
 }
 
 func NewDefaultPluginHandler() PluginHandler {
 	return &DefaultPluginHandler{
 		ValidPrefixes: []string{"devctl"},
 		Fs:            afero.NewOsFs(),
+		Mode:          DefaultExecMode(),
+		Stdin:         os.Stdin,
+		Stdout:        os.Stdout,
+		Stderr:        os.Stderr,
 	}
 }
 
@@ -40,12 +82,58 @@ func (d *DefaultPluginHandler) Lookup(name string) (executablePath string, ok bo
 	return "", false
 }
 
+// Execute runs the plugin at executablePath according to the Mode of the handler.
+// In ExecModeChild, a *devctlerrors.ExitError is returned, if the plugin exits with a non-zero code.
 func (d *DefaultPluginHandler) Execute(executablePath string, cmdArgs, environment []string) (err error) {
-	if runtime.GOOS == "windows" {
-		return devctlerrors.ErrWindowsNotSupported
+	if d.Mode == ExecModeChild {
+		return d.executeChild(executablePath, cmdArgs, environment)
+	}
+	return execReplace(executablePath, cmdArgs, environment)
+}
+
+// forwardedSignals are passed on to plugins executed as child process
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+// executeChild runs the plugin as child process, passing through stdio and forwarding signals,
+// as the terminal only delivers signals like SIGINT to the foreground process group.
+func (d *DefaultPluginHandler) executeChild(executablePath string, cmdArgs, environment []string) error {
+	cmd := exec.Command(executablePath, cmdArgs...)
+	cmd.Env = environment
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = d.Stdin, d.Stdout, d.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return err
 	}
 
-	// invoke cmd binary relaying the environment and args given
-	// append executablePath to cmdArgs, as execve will make first argument the "binary name".
-	return syscall.Exec(executablePath, append([]string{executablePath}, cmdArgs...), environment)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &devctlerrors.ExitError{Code: exitCode(exitErr)}
+	}
+	return err
+}
+
+// exitCode returns the exit code of the plugin, using the shell convention 128+N for plugins killed by signal N
+func exitCode(err *exec.ExitError) int {
+	if ws, ok := err.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return err.ExitCode()
 }
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package util
+
+import "syscall"
+
+const execReplaceSupported = true
+
+// execReplace replaces the devctl process with the plugin
+func execReplace(executablePath string, cmdArgs, environment []string) error {
+	// invoke cmd binary relaying the environment and args given
+	// append executablePath to cmdArgs, as execve will make first argument the "binary name".
+	return syscall.Exec(executablePath, append([]string{executablePath}, cmdArgs...), environment)
+}
@@ -0,0 +1,13 @@
+//go:build windows
+// +build windows
+
+package util
+
+import devctlerrors "github.com/alex-held/devctl/pkg/errors"
+
+// execReplaceSupported is false, as windows has no execve
+const execReplaceSupported = false
+
+func execReplace(string, []string, []string) error {
+	return devctlerrors.ErrWindowsNotSupported
+}
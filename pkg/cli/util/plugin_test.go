@@ -0,0 +1,113 @@
+//go:build !windows
+// +build !windows
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	devctlerrors "github.com/alex-held/devctl/pkg/errors"
+)
+
+const helperEnvVar = "DEVCTL_TEST_PLUGIN_HELPER"
+
+// TestMain turns the test binary into a plugin, when helperEnvVar is set
+func TestMain(m *testing.M) {
+	switch os.Getenv(helperEnvVar) {
+	case "":
+		os.Exit(m.Run())
+	case "echo":
+		input, _ := ioutil.ReadAll(os.Stdin)
+		fmt.Printf("args=%s stdin=%s\n", strings.Join(os.Args[1:], ","), input)
+		fmt.Fprintln(os.Stderr, "to stderr")
+		code, _ := strconv.Atoi(os.Getenv("EXIT_CODE"))
+		os.Exit(code)
+	case "signal":
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		_ = ioutil.WriteFile(os.Getenv("READY_FILE"), nil, 0644)
+		select {
+		case <-signals:
+			os.Exit(42)
+		case <-time.After(10 * time.Second):
+			os.Exit(1)
+		}
+	}
+}
+
+func newChildHandler(stdin string) (*DefaultPluginHandler, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	return &DefaultPluginHandler{
+		ValidPrefixes: []string{"devctl"},
+		Mode:          ExecModeChild,
+		Stdin:         strings.NewReader(stdin),
+		Stdout:        stdout,
+		Stderr:        stderr,
+	}, stdout, stderr
+}
+
+func TestDefaultPluginHandler_ExecuteChild(t *testing.T) {
+	sut, stdout, stderr := newChildHandler("input")
+
+	err := sut.Execute(os.Args[0], []string{"a", "b"}, []string{helperEnvVar + "=echo"})
+	require.NoError(t, err)
+	assert.Equal(t, "args=a,b stdin=input\n", stdout.String())
+	assert.Equal(t, "to stderr\n", stderr.String())
+}
+
+func TestDefaultPluginHandler_ExecuteChild_ExitCode(t *testing.T) {
+	sut, _, _ := newChildHandler("")
+
+	err := sut.Execute(os.Args[0], nil, []string{helperEnvVar + "=echo", "EXIT_CODE=7"})
+	var exitErr *devctlerrors.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 7, exitErr.Code)
+}
+
+func TestDefaultPluginHandler_ExecuteChild_ForwardsSignals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devctl-plugin-handler")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ready := filepath.Join(dir, "ready")
+
+	sut, _, _ := newChildHandler("")
+	errs := make(chan error, 1)
+	go func() {
+		errs <- sut.Execute(os.Args[0], nil, []string{helperEnvVar + "=signal", "READY_FILE=" + ready})
+	}()
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(ready)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	// the handler intercepts the signal, so it doesn't terminate the test
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+
+	var exitErr *devctlerrors.ExitError
+	require.ErrorAs(t, <-errs, &exitErr)
+	assert.Equal(t, 42, exitErr.Code)
+}
+
+func TestDefaultExecMode(t *testing.T) {
+	mode := os.Getenv(ExecModeEnvVar)
+	defer os.Setenv(ExecModeEnvVar, mode)
+
+	require.NoError(t, os.Setenv(ExecModeEnvVar, string(ExecModeChild)))
+	assert.Equal(t, ExecModeChild, DefaultExecMode())
+
+	require.NoError(t, os.Setenv(ExecModeEnvVar, ""))
+	assert.Equal(t, ExecModeReplace, DefaultExecMode())
+}
@@ -0,0 +1,346 @@
+// Package config loads the central devctl configuration file, usually ~/.devctl/config.yaml.
+//
+// The file is kept as yaml.v3 document, so that comments and ordering survive updates made by
+// 'devctl config set' and 'devctl config edit'. Vars are templated into the plugin entries:
+//
+//	vars:
+//	  GO_CONFIG: "{{ .DEVCTL_PATH_CONFIG }}/golang"
+//	plugins:
+//	  - name: golang
+//	    enabled: true
+//	    config: "{{ .GO_CONFIG }}/config.yaml"
+//
+// Profiles are switched as a whole using 'devctl profile use':
+//
+//	currentProfile: acme
+//	profiles:
+//	  acme:
+//	    indexes: [default, acme]
+//	    sdks:
+//	      go: 1.16.5
+//
+// Dotfiles are applied to $HOME using 'devctl dotfiles apply':
+//
+//	dotfiles:
+//	  repo: https://github.com/alex-held/dotfiles
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the schema of the configuration file
+type Config struct {
+	// Root is the devctl root directory, see Location
+	Root string `yaml:"root,omitempty"`
+	// Layout is the layout of the devctl directories, either 'root' or 'xdg', see Location
+	Layout string `yaml:"layout,omitempty"`
+	// Vars are templated into the plugin entries, in addition to the builtin DEVCTL_PATH_* vars
+	Vars map[string]string `yaml:"vars,omitempty"`
+	// Plugins configure the plugins
+	Plugins []PluginSpec `yaml:"plugins,omitempty"`
+	// PluginExecMode selects how binary plugins are executed, either 'replace' or 'child'
+	PluginExecMode string `yaml:"pluginExecMode,omitempty"`
+	// CurrentProfile is the name of the active profile, see 'devctl profile use'
+	CurrentProfile string `yaml:"currentProfile,omitempty"`
+	// Profiles are switched as a whole, like kubectl contexts
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+	// Dotfiles are applied to $HOME, see 'devctl dotfiles'
+	Dotfiles *Dotfiles `yaml:"dotfiles,omitempty"`
+}
+
+// PluginSpec configures a plugin
+type PluginSpec struct {
+	Name     string `yaml:"name"`
+	Enabled  bool   `yaml:"enabled"`
+	Config   string `yaml:"config,omitempty"`
+	Manifest string `yaml:"manifest,omitempty"`
+}
+
+// ValidationError is returned, if a configuration doesn't match the schema
+type ValidationError struct {
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	msg := "invalid configuration:"
+	for _, err := range e.Errors {
+		msg += "\n  - " + err
+	}
+	return msg
+}
+
+// Validate checks c against the schema
+func (c *Config) Validate() error {
+	var errs []string
+	switch c.Layout {
+	case "", "root", "xdg":
+	default:
+		errs = append(errs, fmt.Sprintf("layout: must be 'root' or 'xdg', got %q", c.Layout))
+	}
+	if c.Root != "" && c.Layout == "xdg" {
+		errs = append(errs, "root: can't be combined with the 'xdg' layout")
+	}
+
+	switch c.PluginExecMode {
+	case "", "replace", "child":
+	default:
+		errs = append(errs, fmt.Sprintf("pluginExecMode: must be 'replace' or 'child', got %q", c.PluginExecMode))
+	}
+
+	names := map[string]bool{}
+	for i, p := range c.Plugins {
+		switch {
+		case p.Name == "":
+			errs = append(errs, fmt.Sprintf("plugins.%d.name: is required", i))
+		case names[p.Name]:
+			errs = append(errs, fmt.Sprintf("plugins.%d.name: duplicate plugin %q", i, p.Name))
+		}
+		names[p.Name] = true
+	}
+	errs = append(errs, c.validateProfiles()...)
+	errs = append(errs, c.validateDotfiles()...)
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// Location is the part of the configuration deciding where devctl stores its files.
+// It is read before the directories, and therefore the builtin vars, are known.
+type Location struct {
+	Root   string `yaml:"root,omitempty"`
+	Layout string `yaml:"layout,omitempty"`
+}
+
+// ReadLocation reads the Location of the configuration file at path, without validating or templating the file.
+// A missing file results in an empty Location.
+func ReadLocation(fs afero.Fs, path string) (Location, error) {
+	loc := Location{}
+	b, err := afero.ReadFile(fs, path)
+	if os.IsNotExist(err) {
+		return loc, nil
+	}
+	if err != nil {
+		return loc, errors.Wrapf(err, "failed to read the configuration %s", path)
+	}
+	if err := yaml.Unmarshal(b, &loc); err != nil {
+		return loc, errors.Wrapf(err, "failed to load the configuration %s", path)
+	}
+	return loc, nil
+}
+
+// Plugin returns the configuration of the plugin name
+func (c *Config) Plugin(name string) (PluginSpec, bool) {
+	for _, p := range c.Plugins {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return PluginSpec{}, false
+}
+
+// File is the configuration file together with its yaml document
+type File struct {
+	fs   afero.Fs
+	Path string
+	// BuiltinVars are available for templating, unless overwritten by Vars of the file
+	BuiltinVars map[string]string
+
+	doc *yaml.Node
+	// Config is the configuration, after the vars have been templated into the plugin entries
+	Config *Config
+}
+
+// Load reads the configuration file at path. A missing file results in an empty configuration.
+func Load(fs afero.Fs, path string, builtinVars map[string]string) (*File, error) {
+	f := &File{fs: fs, Path: path, BuiltinVars: builtinVars}
+
+	b, err := afero.ReadFile(fs, path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to read the configuration %s", path)
+	}
+	if err := f.setDocument(b); err != nil {
+		return nil, errors.Wrapf(err, "failed to load the configuration %s", path)
+	}
+	return f, nil
+}
+
+// LoadRaw reads the configuration file at path without validating it, so that an invalid configuration can be repaired
+// using Set, Unset or Replace. Config is nil, until one of them succeeds. A missing file results in an empty configuration.
+func LoadRaw(fs afero.Fs, path string, builtinVars map[string]string) (*File, error) {
+	f := &File{fs: fs, Path: path, BuiltinVars: builtinVars}
+
+	b, err := afero.ReadFile(fs, path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to read the configuration %s", path)
+	}
+	if f.doc, err = parseDocument(b); err != nil {
+		return nil, errors.Wrapf(err, "failed to load the configuration %s", path)
+	}
+	return f, nil
+}
+
+// parseDocument parses b into a yaml document, which is an empty mapping for an empty file
+func parseDocument(b []byte) (*yaml.Node, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 {
+		// empty file
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	return doc, nil
+}
+
+// setDocument parses, validates and resolves b, before replacing the document of f
+func (f *File) setDocument(b []byte) error {
+	doc, err := parseDocument(b)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := decode(doc)
+	if err != nil {
+		return err
+	}
+	if err = cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg, err = resolve(cfg, f.BuiltinVars); err != nil {
+		return err
+	}
+
+	f.doc, f.Config = doc, cfg
+	return nil
+}
+
+// decode decodes doc strictly, so that unknown keys are reported
+func decode(doc *yaml.Node) (*Config, error) {
+	b, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return nil, &ValidationError{Errors: []string{err.Error()}}
+	}
+	return cfg, nil
+}
+
+// resolve templates the vars into the plugin entries of cfg
+func resolve(cfg *Config, builtinVars map[string]string) (*Config, error) {
+	vars := map[string]string{}
+	for k, v := range builtinVars {
+		vars[k] = v
+	}
+	// vars may refer to builtin vars, but not to each other
+	for _, k := range sortedKeys(cfg.Vars) {
+		v, err := render("vars."+k, cfg.Vars[k], builtinVars)
+		if err != nil {
+			return nil, err
+		}
+		vars[k] = v
+	}
+
+	resolved := *cfg
+	resolved.Plugins = make([]PluginSpec, len(cfg.Plugins))
+	for i, p := range cfg.Plugins {
+		var err error
+		if p.Config, err = render(fmt.Sprintf("plugins.%d.config", i), p.Config, vars); err != nil {
+			return nil, err
+		}
+		if p.Manifest, err = render(fmt.Sprintf("plugins.%d.manifest", i), p.Manifest, vars); err != nil {
+			return nil, err
+		}
+		resolved.Plugins[i] = p
+	}
+	if cfg.Profiles != nil {
+		resolved.Profiles = make(map[string]Profile, len(cfg.Profiles))
+		for name, p := range cfg.Profiles {
+			var err error
+			if p.Zsh, err = render("profiles."+name+".zsh", p.Zsh, vars); err != nil {
+				return nil, err
+			}
+			resolved.Profiles[name] = p
+		}
+	}
+	if cfg.Dotfiles != nil {
+		d := *cfg.Dotfiles
+		var err error
+		if d.Path, err = render("dotfiles.path", d.Path, vars); err != nil {
+			return nil, err
+		}
+		resolved.Dotfiles = &d
+	}
+	return &resolved, nil
+}
+
+func render(name, text string, vars map[string]string) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse %s", name)
+	}
+	out := &bytes.Buffer{}
+	if err := t.Execute(out, vars); err != nil {
+		return "", errors.Wrapf(err, "failed to template %s", name)
+	}
+	return out.String(), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Bytes returns the yaml document of the file, including its comments
+func (f *File) Bytes() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(f.doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	if bytes.Equal(bytes.TrimSpace(buf.Bytes()), []byte("{}")) {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// Replace validates b and replaces the document of the file with it, e.g. after it has been edited
+func (f *File) Replace(b []byte) error {
+	return f.setDocument(b)
+}
+
+// Save writes the document to the configuration file
+func (f *File) Save() error {
+	b, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := f.fs.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return errors.Wrap(err, "failed to create the configuration directory")
+	}
+	return afero.WriteFile(f.fs, f.Path, b, 0644)
+}
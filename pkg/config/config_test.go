@@ -0,0 +1,191 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `# devctl configuration
+vars:
+  GO_CONFIG: "{{ .DEVCTL_PATH_CONFIG }}/golang" # templated
+plugins:
+  # the go sdk plugin
+  - name: golang
+    enabled: true
+    config: "{{ .GO_CONFIG }}/config.yaml"
+  - name: zsh
+    enabled: false
+`
+
+var builtinVars = map[string]string{"DEVCTL_PATH_CONFIG": "/home/user/.devctl/config"}
+
+func loadTestConfig(t *testing.T, content string) (*File, afero.Fs) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/home/user/.devctl/config.yaml", []byte(content), 0644))
+	f, err := Load(fs, "/home/user/.devctl/config.yaml", builtinVars)
+	require.NoError(t, err)
+	return f, fs
+}
+
+func TestLoad(t *testing.T) {
+	f, _ := loadTestConfig(t, testConfig)
+
+	golang, ok := f.Config.Plugin("golang")
+	require.True(t, ok)
+	assert.True(t, golang.Enabled)
+	assert.Equal(t, "/home/user/.devctl/config/golang/config.yaml", golang.Config)
+}
+
+func TestLoad_Missing(t *testing.T) {
+	f, err := Load(afero.NewMemMapFs(), "/config.yaml", nil)
+	require.NoError(t, err)
+	assert.Empty(t, f.Config.Plugins)
+
+	require.NoError(t, f.Set("pluginExecMode", "child"))
+	assert.Equal(t, "child", f.Config.PluginExecMode)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown key":     "unknown: true\n",
+		"exec mode":       "pluginExecMode: fork\n",
+		"missing name":    "plugins:\n  - enabled: true\n",
+		"duplicate name":  "plugins:\n  - name: zsh\n  - name: zsh\n",
+		"missing var":     "plugins:\n  - name: zsh\n    config: '{{ .UNKNOWN }}'\n",
+		"malformed value": "plugins: [\n",
+		"unknown profile": "currentProfile: acme\n",
+		"profile name":    "profiles:\n  a.b: {}\n",
+		"export name":     "profiles:\n  acme:\n    exports:\n      FOO-BAR: baz\n",
+		"alias name":      "profiles:\n  acme:\n    aliases:\n      'x; rm -rf ~ #': ls\n",
+		"layout":          "layout: flat\n",
+		"dotfiles source": "dotfiles:\n  repo: https://example.com/dotfiles\n  path: /dotfiles\n",
+		"dotfiles mode":   "dotfiles:\n  path: /dotfiles\n  mode: hardlink\n",
+		"dotfiles path":   "dotfiles:\n  path: dotfiles\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fs, "/config.yaml", []byte(content), 0644))
+			_, err := Load(fs, "/config.yaml", nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadRaw(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/config.yaml", []byte("pluginExecMode: fork\n"), 0644))
+	_, err := Load(fs, "/config.yaml", nil)
+	require.Error(t, err)
+
+	f, err := LoadRaw(fs, "/config.yaml", nil)
+	require.NoError(t, err)
+	assert.Error(t, f.Set("layout", "flat"), "the result is still invalid")
+	assert.Nil(t, f.Config)
+
+	require.NoError(t, f.Set("pluginExecMode", "child"))
+	assert.Equal(t, "child", f.Config.PluginExecMode)
+	require.NoError(t, f.Save())
+
+	_, err = Load(fs, "/config.yaml", nil)
+	assert.NoError(t, err)
+}
+
+func TestFile_Get(t *testing.T) {
+	f, _ := loadTestConfig(t, testConfig)
+
+	for key, expected := range map[string]string{
+		"plugins.golang.enabled": "true",
+		"plugins.1.name":         "zsh",
+		"vars.GO_CONFIG":         "{{ .DEVCTL_PATH_CONFIG }}/golang",
+		"vars":                   "GO_CONFIG: \"{{ .DEVCTL_PATH_CONFIG }}/golang\" # templated",
+	} {
+		actual, err := f.Get(key)
+		require.NoError(t, err, key)
+		assert.Equal(t, expected, actual, key)
+	}
+
+	_, err := f.Get("plugins.unknown.enabled")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestFile_Set(t *testing.T) {
+	f, fs := loadTestConfig(t, testConfig)
+
+	require.NoError(t, f.Set("plugins.zsh.enabled", "true"))
+	require.NoError(t, f.Set("vars.GOPATH", "/go"))
+	require.NoError(t, f.Set("vars.GOROOT", "{{ .DEVCTL_PATH_CONFIG }}/go"))
+	require.NoError(t, f.Save())
+
+	b, err := afero.ReadFile(fs, f.Path)
+	require.NoError(t, err)
+	assert.Equal(t, `# devctl configuration
+vars:
+  GO_CONFIG: "{{ .DEVCTL_PATH_CONFIG }}/golang" # templated
+  GOPATH: /go
+  GOROOT: '{{ .DEVCTL_PATH_CONFIG }}/go'
+plugins:
+  # the go sdk plugin
+  - name: golang
+    enabled: true
+    config: "{{ .GO_CONFIG }}/config.yaml"
+  - name: zsh
+    enabled: true
+`, string(b))
+
+	zsh, _ := f.Config.Plugin("zsh")
+	assert.True(t, zsh.Enabled)
+}
+
+func TestFile_Set_Invalid(t *testing.T) {
+	f, _ := loadTestConfig(t, testConfig)
+
+	err := f.Set("plugins.zsh.enabled", "[1, 2]")
+	assert.Error(t, err)
+	err = f.Set("pluginExecMode", "fork")
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	// the document is unchanged
+	actual, err := f.Get("plugins.zsh.enabled")
+	require.NoError(t, err)
+	assert.Equal(t, "false", actual)
+	_, err = f.Get("pluginExecMode")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestFile_Unset(t *testing.T) {
+	f, _ := loadTestConfig(t, testConfig)
+
+	// golang refers to the var
+	assert.Error(t, f.Unset("vars.GO_CONFIG"))
+
+	require.NoError(t, f.Unset("plugins.golang"))
+	require.NoError(t, f.Unset("vars.GO_CONFIG"))
+	_, ok := f.Config.Plugin("golang")
+	assert.False(t, ok)
+	assert.Empty(t, f.Config.Vars)
+
+	assert.ErrorIs(t, f.Unset("vars.unknown"), ErrKeyNotFound)
+	assert.Error(t, f.Unset(""))
+}
+
+func TestConfig_ActiveProfile(t *testing.T) {
+	f, _ := loadTestConfig(t, testConfig)
+	_, _, ok := f.Config.ActiveProfile()
+	assert.False(t, ok)
+
+	require.NoError(t, f.Set("profiles.acme", "{indexes: [acme], sdks: {go: 1.16.5}, zsh: '{{ .DEVCTL_PATH_CONFIG }}/zsh/acme.yaml'}"))
+	require.NoError(t, f.Set("currentProfile", "acme"))
+	name, p, ok := f.Config.ActiveProfile()
+	require.True(t, ok)
+	assert.Equal(t, "acme", name)
+	assert.Equal(t, []string{"acme"}, p.Indexes)
+	assert.Equal(t, map[string]string{"go": "1.16.5"}, p.SDKs)
+	assert.Equal(t, "/home/user/.devctl/config/zsh/acme.yaml", p.Zsh)
+
+	// the active profile can't be removed on its own
+	assert.Error(t, f.Unset("profiles.acme"))
+}
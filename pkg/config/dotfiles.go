@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Dotfiles declares the dotfiles applied to $HOME by 'devctl dotfiles apply':
+//
+//	dotfiles:
+//	  repo: https://github.com/alex-held/dotfiles
+//	  ref: main
+//	  values:
+//	    email: alex@example.com
+//	  ignore: [README.md, "*.sh"]
+type Dotfiles struct {
+	// Repo is the git URL of the dotfiles, it is cloned into the data directory of devctl
+	Repo string `yaml:"repo,omitempty"`
+	// Ref is the branch, tag or commit of Repo to check out, defaults to the default branch
+	Ref string `yaml:"ref,omitempty"`
+	// Path is a local directory of dotfiles, which is used instead of a Repo. It is absolute or starts with ~/.
+	Path string `yaml:"path,omitempty"`
+	// Mode is either 'symlink', the default, or 'copy'. Templates are always rendered into copies.
+	Mode string `yaml:"mode,omitempty"`
+	// Values are available to the templates as {{ .values.KEY }}
+	Values map[string]string `yaml:"values,omitempty"`
+	// Ignore are glob patterns of files, which are not applied, matched against their path and their name
+	Ignore []string `yaml:"ignore,omitempty"`
+}
+
+// Dotfiles modes
+const (
+	DotfilesModeSymlink = "symlink"
+	DotfilesModeCopy    = "copy"
+)
+
+func (c *Config) validateDotfiles() (errs []string) {
+	d := c.Dotfiles
+	if d == nil {
+		return nil
+	}
+	switch {
+	case d.Repo == "" && d.Path == "":
+		errs = append(errs, "dotfiles: either repo or path is required")
+	case d.Repo != "" && d.Path != "":
+		errs = append(errs, "dotfiles: repo and path can't be combined")
+	case d.Ref != "" && d.Repo == "":
+		errs = append(errs, "dotfiles.ref: requires a repo")
+	case d.Path != "" && !filepath.IsAbs(d.Path) && !strings.HasPrefix(d.Path, "~/"):
+		errs = append(errs, fmt.Sprintf("dotfiles.path: must be absolute or start with ~/, got %q", d.Path))
+	}
+	switch d.Mode {
+	case "", DotfilesModeSymlink, DotfilesModeCopy:
+	default:
+		errs = append(errs, fmt.Sprintf("dotfiles.mode: must be 'symlink' or 'copy', got %q", d.Mode))
+	}
+	for i, pattern := range d.Ignore {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Sprintf("dotfiles.ignore.%d: invalid pattern %q", i, pattern))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrKeyNotFound is returned by Get, if the key is not set
+var ErrKeyNotFound = fmt.Errorf("key not found")
+
+// Get returns the yaml of the value at the dotted key, e.g. 'vars.GOPATH' or 'plugins.golang.enabled'.
+// Elements of sequences are selected by their index or by their name.
+func (f *File) Get(key string) (string, error) {
+	node, err := lookup(f.root(), splitKey(key), false)
+	if err != nil {
+		return "", err
+	}
+	if node.Kind == yaml.ScalarNode {
+		return node.Value, nil
+	}
+	b, err := yaml.Marshal(node)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// Set sets the value at the dotted key, creating missing mappings along the way.
+// value is parsed as yaml, so 'true' becomes a boolean, unless it starts with a template like '{{ .VAR }}'.
+// The resulting configuration is validated, the file is left untouched if it's invalid.
+func (f *File) Set(key, value string) error {
+	parsed := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(value), parsed); err != nil {
+		return fmt.Errorf("invalid value %q: %w", value, err)
+	}
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(value), "{{"):
+		// a flow mapping with a mapping as key is never meant
+		parsed = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	case parsed.Kind == 0:
+		parsed = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: ""}
+	default:
+		parsed = parsed.Content[0]
+		blockStyle(parsed)
+	}
+
+	// work on a copy, to keep the document intact on validation errors
+	b, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+	doc, err := parseDocument(b)
+	if err != nil {
+		return err
+	}
+
+	node, err := lookup(doc.Content[0], splitKey(key), true)
+	if err != nil {
+		return err
+	}
+	// keep the comments of the replaced value
+	parsed.HeadComment, parsed.LineComment, parsed.FootComment = node.HeadComment, node.LineComment, node.FootComment
+	*node = *parsed
+
+	b, err = yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return f.setDocument(b)
+}
+
+// Unset removes the dotted key, e.g. 'profiles.work'. Elements of sequences are selected by their index or by their name.
+// The resulting configuration is validated, the file is left untouched if it's invalid.
+func (f *File) Unset(key string) error {
+	path := splitKey(key)
+	if len(path) == 0 {
+		return fmt.Errorf("can't unset the whole configuration")
+	}
+
+	b, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		return err
+	}
+	if doc.Kind == 0 {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+
+	parent, err := lookup(doc.Content[0], path[:len(path)-1], false)
+	if err != nil {
+		return err
+	}
+	last := path[len(path)-1]
+	removed := false
+	switch parent.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(parent.Content); i += 2 {
+			if parent.Content[i].Value == last {
+				parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+				removed = true
+				break
+			}
+		}
+	case yaml.SequenceNode:
+		if el := sequenceElement(parent, last); el != nil {
+			for i, c := range parent.Content {
+				if c == el {
+					parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+					removed = true
+					break
+				}
+			}
+		}
+	}
+	if !removed {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+
+	b, err = yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return f.setDocument(b)
+}
+
+func (f *File) root() *yaml.Node {
+	return f.doc.Content[0]
+}
+
+func splitKey(key string) []string {
+	if key == "" || key == "." {
+		return nil
+	}
+	return strings.Split(strings.TrimPrefix(key, "."), ".")
+}
+
+// lookup returns the node at path below node. If create is set, missing mapping keys are added.
+func lookup(node *yaml.Node, path []string, create bool) (*yaml.Node, error) {
+	for i, part := range path {
+		switch node.Kind {
+		case yaml.MappingNode:
+			next := mappingValue(node, part)
+			if next == nil {
+				if !create {
+					return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, strings.Join(path[:i+1], "."))
+				}
+				next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, next)
+			}
+			node = next
+		case yaml.SequenceNode:
+			next := sequenceElement(node, part)
+			if next == nil {
+				return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, strings.Join(path[:i+1], "."))
+			}
+			node = next
+		default:
+			if !create || node.Kind != yaml.ScalarNode || node.Tag != "!!null" {
+				return nil, fmt.Errorf("%s is not a mapping or a sequence", strings.Join(path[:i], "."))
+			}
+			// replace the empty value with a mapping
+			*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			next := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, next)
+			node = next
+		}
+	}
+	return node, nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// sequenceElement selects an element of a sequence by its index or by the value of its 'name' key
+func sequenceElement(node *yaml.Node, part string) *yaml.Node {
+	if idx, err := strconv.Atoi(part); err == nil {
+		if idx >= 0 && idx < len(node.Content) {
+			return node.Content[idx]
+		}
+		return nil
+	}
+	for _, el := range node.Content {
+		if el.Kind != yaml.MappingNode {
+			continue
+		}
+		if name := mappingValue(el, "name"); name != nil && name.Value == part {
+			return el
+		}
+	}
+	return nil
+}
+
+// blockStyle formats the mappings and sequences of node like the rest of the file, instead of the flow style of the command line
+func blockStyle(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		node.Style &^= yaml.FlowStyle
+	}
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
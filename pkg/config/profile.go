@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Profile is a named set of indexes, sdk versions and shell settings, which can be switched as a whole
+type Profile struct {
+	// Indexes restricts the plugin indexes consulted while listing, searching and installing plugins.
+	// All indexes are consulted, if it is empty.
+	Indexes []string `yaml:"indexes,omitempty"`
+	// SDKs maps sdk names to the version linked as 'current', e.g. go: 1.16.5
+	SDKs map[string]string `yaml:"sdks,omitempty"`
+	// Zsh is the zsh configuration rendered while the profile is active
+	Zsh string `yaml:"zsh,omitempty"`
+	// Exports are environment variables exported to the shell
+	Exports map[string]string `yaml:"exports,omitempty"`
+	// Aliases are shell aliases
+	Aliases map[string]string `yaml:"aliases,omitempty"`
+}
+
+var (
+	profileNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+	exportKeyRegex   = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// aliasNameRegex permits the characters of shell identifiers and '-' and '.', as the names are rendered unquoted
+	aliasNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]*$`)
+)
+
+// IsValidProfileName checks whether name can be used as profile name, i.e. as a key of the configuration
+func IsValidProfileName(name string) bool {
+	return profileNameRegex.MatchString(name)
+}
+
+// ActiveProfile returns the name and the profile selected by CurrentProfile
+func (c *Config) ActiveProfile() (string, Profile, bool) {
+	if c.CurrentProfile == "" {
+		return "", Profile{}, false
+	}
+	p, ok := c.Profiles[c.CurrentProfile]
+	return c.CurrentProfile, p, ok
+}
+
+func (c *Config) validateProfiles() (errs []string) {
+	if _, ok := c.Profiles[c.CurrentProfile]; c.CurrentProfile != "" && !ok {
+		errs = append(errs, fmt.Sprintf("currentProfile: unknown profile %q", c.CurrentProfile))
+	}
+	for _, name := range sortedProfileNames(c.Profiles) {
+		p := c.Profiles[name]
+		if !IsValidProfileName(name) {
+			errs = append(errs, fmt.Sprintf("profiles.%s: invalid profile name", name))
+		}
+		for _, sdk := range sortedKeys(p.SDKs) {
+			if p.SDKs[sdk] == "" {
+				errs = append(errs, fmt.Sprintf("profiles.%s.sdks.%s: version is required", name, sdk))
+			}
+		}
+		for _, key := range sortedKeys(p.Exports) {
+			if !exportKeyRegex.MatchString(key) {
+				errs = append(errs, fmt.Sprintf("profiles.%s.exports.%s: invalid environment variable name", name, key))
+			}
+		}
+		for _, alias := range sortedKeys(p.Aliases) {
+			if !aliasNameRegex.MatchString(alias) {
+				errs = append(errs, fmt.Sprintf("profiles.%s.aliases.%s: invalid alias name", name, alias))
+			}
+		}
+	}
+	return errs
+}
+
+// ProfileNames returns the names of all profiles in order
+func (c *Config) ProfileNames() []string {
+	return sortedProfileNames(c.Profiles)
+}
+
+func sortedProfileNames(profiles map[string]Profile) []string {
+	names := make(map[string]string, len(profiles))
+	for name := range profiles {
+		names[name] = name
+	}
+	return sortedKeys(names)
+}
@@ -0,0 +1,325 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alex-held/devctl/internal/git"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/history"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/scanner"
+	"github.com/alex-held/devctl/pkg/profile"
+	"github.com/alex-held/devctl/pkg/registry"
+	"github.com/alex-held/devctl/plugins/zsh"
+)
+
+// Default returns a Doctor with all builtin checks registered
+func Default() *Doctor {
+	d := New()
+	d.Register(
+		Check{Name: "bin-path", Description: "the plugin bin directory is on the PATH", Run: checkBinPath},
+		Check{Name: "indexes", Description: "the plugin indexes are cloned and fetchable", Run: checkIndexes},
+		Check{Name: "receipts", Description: "installed plugins have their store directory and link", Run: checkReceipts},
+		Check{Name: "store", Description: "the store contains no plugins without receipt", Run: checkStore},
+		Check{Name: "links", Description: "there are no dangling plugin links", Run: checkLinks},
+		Check{Name: "sdks", Description: "the current links of the sdks are valid", Run: checkSDKs},
+		Check{Name: "zsh", Description: "the generated zsh files are up to date", Run: checkZsh},
+		Check{Name: "plugins", Description: "the interpreted plugins load", Run: checkPlugins},
+	)
+	return d
+}
+
+func checkBinPath(f env.Factory) []Result {
+	bin := f.Paths().BinPath()
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(dir) == bin {
+			return []Result{OK(fmt.Sprintf("%s is on the PATH", bin))}
+		}
+	}
+	return []Result{Warn(
+		fmt.Sprintf("%s is not on the PATH, installed plugins can't be found", bin),
+		fmt.Sprintf(`add 'export PATH="%s:$PATH"' to your shell profile or use 'eval "$(devctl zsh init)"'`, bin),
+	)}
+}
+
+func checkIndexes(f env.Factory) (results []Result) {
+	names, err := scanner.IndexNames(f.Paths())
+	if err != nil && !os.IsNotExist(err) {
+		return []Result{Fail(fmt.Sprintf("failed to list the indexes: %v", err), "check the permissions of "+f.Paths().IndexBase())}
+	}
+	if len(names) == 0 {
+		return []Result{Warn("no plugin index is configured", "run 'devctl plugin update' to clone the default index")}
+	}
+
+	for _, name := range names {
+		dir := f.Paths().IndexPath(name)
+		hint := fmt.Sprintf("remove and add the index again using 'devctl plugin index remove %s' and 'devctl plugin index add %s URL'", name, name)
+		if cloned, err := git.IsGitCloned(dir); err != nil || !cloned {
+			results = append(results, Fail(fmt.Sprintf("index %s is not a git repository", name), hint))
+			continue
+		}
+		if _, err := git.Exec(dir, "ls-remote", "--exit-code", "origin", "HEAD"); err != nil {
+			results = append(results, Warn(fmt.Sprintf("index %s can't be fetched: %v", name, err), "check your network connection and the remote URL of the index"))
+			continue
+		}
+		results = append(results, OK(fmt.Sprintf("index %s is cloned and fetchable", name)))
+	}
+	return results
+}
+
+func checkReceipts(f env.Factory) (results []Result) {
+	receipts, err := installation.GetInstalledPluginReceipts(f)
+	if err != nil {
+		return []Result{Fail(err.Error(), "remove the malformed receipt from "+f.Paths().InstallReceiptsPath())}
+	}
+
+	for _, r := range receipts {
+		hint := fmt.Sprintf("reinstall the plugin using 'devctl plugin uninstall %s' and 'devctl plugin install %s'", r.Name, r.Name)
+		store := f.Paths().PluginVersionInstallPath(r.Name, r.Spec.Version)
+		if fi, err := os.Stat(store); err != nil || !fi.IsDir() {
+			results = append(results, Fail(fmt.Sprintf("plugin %s %s is missing its store directory %s", r.Name, r.Spec.Version, store), hint))
+			continue
+		}
+
+		platform, ok, err := installation.GetMatchingPlatform(r.Spec.Platforms)
+		if err != nil || !ok {
+			results = append(results, Warn(fmt.Sprintf("plugin %s is not supported on this platform", r.Name), "uninstall it using 'devctl plugin uninstall "+r.Name+"'"))
+			continue
+		}
+		link := installation.LinkPath(f, r.Name, platform)
+		if _, err := os.Lstat(link); err != nil {
+			results = append(results, Fail(fmt.Sprintf("plugin %s is not linked at %s", r.Name, link), hint))
+			continue
+		}
+		// both are resolved, as the root may be below a symlink, e.g. /tmp on macOS
+		target, err := filepath.EvalSymlinks(link)
+		resolvedStore, storeErr := filepath.EvalSymlinks(store)
+		if err != nil || storeErr != nil || !strings.HasPrefix(target, resolvedStore+string(filepath.Separator)) {
+			results = append(results, Fail(fmt.Sprintf("the link %s of plugin %s doesn't point into %s", link, r.Name, store), hint))
+			continue
+		}
+		results = append(results, OK(fmt.Sprintf("plugin %s %s is installed", r.Name, r.Spec.Version)))
+	}
+	return results
+}
+
+func checkStore(f env.Factory) (results []Result) {
+	receipts, err := installation.GetInstalledPluginReceipts(f)
+	if err != nil {
+		// reported by the receipts check
+		return nil
+	}
+	versions := map[string]string{}
+	for _, r := range receipts {
+		versions[r.Name] = r.Spec.Version
+	}
+	// the version before the last upgrade is kept for 'devctl history undo'
+	entries, _ := history.Load(history.Path(f.Paths()))
+	previous := map[string]string{}
+	for name, version := range versions {
+		if e, ok := history.LastVersionChange(entries, name); ok && e.After == version {
+			previous[name] = e.Before
+		}
+	}
+
+	store := f.Paths().InstallPath()
+	plugins, err := ioutil.ReadDir(store)
+	if err != nil && !os.IsNotExist(err) {
+		return []Result{Fail(fmt.Sprintf("failed to read the store: %v", err), "check the permissions of "+store)}
+	}
+	for _, p := range plugins {
+		dir := filepath.Join(store, p.Name())
+		version, ok := versions[p.Name()]
+		if !ok {
+			results = append(results, Warn(fmt.Sprintf("%s has no receipt", dir), moveAsideHint(f, dir)).WithFix(moveAside(f, dir)))
+			continue
+		}
+		installed, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, v := range installed {
+			if v.Name() != version && v.Name() != previous[p.Name()] {
+				stale := filepath.Join(dir, v.Name())
+				results = append(results, Warn(fmt.Sprintf("%s is not the installed version %s", stale, version), moveAsideHint(f, stale)).WithFix(moveAside(f, stale)))
+			}
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, OK("the store contains installed plugins only"))
+	}
+	return results
+}
+
+func checkLinks(f env.Factory) (results []Result) {
+	dirs := []struct{ dir, prefix string }{
+		{f.Paths().BinPath(), "devctl-"},
+		{f.Pather().Plugin(), ""},
+	}
+	for _, d := range dirs {
+		entries, err := ioutil.ReadDir(d.dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			path := filepath.Join(d.dir, e.Name())
+			if e.Mode()&os.ModeSymlink == 0 || !strings.HasPrefix(e.Name(), d.prefix) {
+				continue
+			}
+			if _, err := os.Stat(path); err != nil {
+				target, _ := os.Readlink(path)
+				results = append(results, Warn(fmt.Sprintf("%s links to the missing %s", path, target), "remove it or run 'devctl doctor --fix'").WithFix(remove(path)))
+			}
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, OK("there are no dangling plugin links"))
+	}
+	return results
+}
+
+func checkSDKs(f env.Factory) (results []Result) {
+	pinned := map[string]string{}
+	if file, err := f.Config(); err == nil {
+		if _, p, ok := file.Config.ActiveProfile(); ok {
+			pinned = p.SDKs
+		}
+	}
+
+	sdks, err := ioutil.ReadDir(f.Pather().SDK())
+	if err != nil {
+		return nil
+	}
+	for _, sdk := range sdks {
+		if !sdk.IsDir() {
+			continue
+		}
+		name := sdk.Name()
+		current := profile.SDKCurrentPath(f.Pather(), name)
+		target, err := os.Readlink(current)
+		switch {
+		case os.IsNotExist(err):
+			results = append(results, Warn(fmt.Sprintf("sdk %s has no current version", name), "select a version using the sdk plugin or 'devctl profile use'"))
+			continue
+		case err != nil:
+			results = append(results, Fail(fmt.Sprintf("%s is not a link", current), "move it out of the way and select a version using the sdk plugin"))
+			continue
+		}
+		if _, err := os.Stat(current); err != nil {
+			results = append(results, Fail(fmt.Sprintf("the current version of sdk %s links to the missing %s", name, target), "remove it or run 'devctl doctor --fix'").WithFix(remove(current)))
+			continue
+		}
+		if version, ok := pinned[name]; ok && filepath.Base(target) != version {
+			results = append(results, Warn(
+				fmt.Sprintf("sdk %s is at %s, but the active profile selects %s", name, filepath.Base(target), version),
+				"run 'devctl profile use' with the active profile or 'devctl doctor --fix'",
+			).WithFix(func() error {
+				err := profile.LinkSDK(f.Pather(), name, version)
+				history.Record(f, history.Entry{Action: history.ActionSDKUse, SDK: name, Before: filepath.Base(target), After: version}, err)
+				return err
+			}))
+			continue
+		}
+		results = append(results, OK(fmt.Sprintf("sdk %s is at %s", name, filepath.Base(target))))
+	}
+	return results
+}
+
+func checkZsh(f env.Factory) (results []Result) {
+	path := profile.ZshConfigPath(f.Pather())
+	r, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return []Result{Fail(fmt.Sprintf("failed to read the zsh configuration: %v", err), "check the permissions of "+path)}
+	}
+	defer r.Close()
+	cfg, err := zsh.ReadConfigFile(r)
+	if err != nil {
+		return []Result{Fail(fmt.Sprintf("failed to load the zsh configuration %s: %v", path, err), "fix the configuration using 'devctl zsh diff'")}
+	}
+	cfg.Pather = f.Pather()
+	cfg.Out = ioutil.Discard
+	cfg.Context.Context = context.Background()
+	z := zsh.NewZSH(cfg)
+
+	drifts, err := z.Check()
+	if err != nil {
+		return []Result{Fail(err.Error(), "fix the zsh configuration "+path)}
+	}
+	for _, d := range drifts {
+		switch d.State {
+		case zsh.DriftNone:
+			results = append(results, OK(fmt.Sprintf("%s is up to date", d.Path)))
+		case zsh.DriftModified:
+			results = append(results, Warn(fmt.Sprintf("%s has been modified by hand", d.Path), fmt.Sprintf("review 'devctl zsh diff %s' and run 'devctl zsh gen %s'", d.Name, d.Name)))
+		default:
+			name := d.Name
+			results = append(results, Warn(fmt.Sprintf("%s is %s", d.Path, d.State), fmt.Sprintf("run 'devctl zsh gen %s' or 'devctl doctor --fix'", name)).WithFix(func() error {
+				return z.Generate(name)
+			}))
+		}
+	}
+
+	stale, err := z.CompletionCache().Stale(cfg.Completions.CLI)
+	if err != nil {
+		return append(results, Fail(fmt.Sprintf("failed to check the completion cache: %v", err), "run 'devctl zsh completions refresh'"))
+	}
+	if len(stale) > 0 {
+		results = append(results, Warn(fmt.Sprintf("the completions of %s are stale", strings.Join(stale, ", ")), "run 'devctl zsh gen completions' or 'devctl doctor --fix'").WithFix(func() error {
+			return z.Generate("completions")
+		}))
+	}
+	return results
+}
+
+func checkPlugins(f env.Factory) (results []Result) {
+	e := registry.NewEngine(f)
+	loaded := e.LoadPlugins()
+	for _, err := range e.LoadErrors() {
+		results = append(results, Fail(err.Error(), "fix or remove the plugin, 'devctl plugin doctor' lists all plugins"))
+	}
+	if len(results) == 0 {
+		results = append(results, OK(fmt.Sprintf("%d interpreted plugins loaded", len(loaded))))
+	}
+	return results
+}
+
+func remove(path string) func() error {
+	return func() error { return os.Remove(path) }
+}
+
+// movedAsidePath returns the path in the state directory, the store directory path is moved to by moveAside
+func movedAsidePath(f env.Factory, path string) string {
+	rel, err := filepath.Rel(f.Paths().InstallPath(), path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	return f.Paths().StatePath("store-orphans", rel)
+}
+
+func moveAsideHint(f env.Factory, path string) string {
+	return fmt.Sprintf("remove it or run 'devctl doctor --fix' to move it to %s", movedAsidePath(f, path))
+}
+
+// moveAside moves the store directory path into the state directory instead of removing it,
+// since it might contain data, which isn't tracked by a receipt
+func moveAside(f env.Factory, path string) func() error {
+	return func() error {
+		dst := movedAsidePath(f, path)
+		if _, err := os.Lstat(dst); err == nil {
+			dst = fmt.Sprintf("%s-%d", dst, time.Now().Unix())
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return os.Rename(path, dst)
+	}
+}
@@ -0,0 +1,101 @@
+// Package doctor runs health checks against the devctl installation.
+//
+// Each Check reports one or more Results with a Status and a remediation hint.
+// Results of problems, which can be repaired safely, carry a Fix.
+package doctor
+
+import (
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Result is a single finding of a check
+type Result struct {
+	Status  Status
+	Message string
+	// Hint describes how to resolve a warning or a failure
+	Hint string
+	// Fix repairs the problem. It is only set for repairs, which don't lose any data.
+	Fix func() error
+}
+
+// OK returns a successful Result
+func OK(msg string) Result {
+	return Result{Status: StatusOK, Message: msg}
+}
+
+// Warn returns a warning with a remediation hint
+func Warn(msg, hint string) Result {
+	return Result{Status: StatusWarn, Message: msg, Hint: hint}
+}
+
+// Fail returns a failure with a remediation hint
+func Fail(msg, hint string) Result {
+	return Result{Status: StatusFail, Message: msg, Hint: hint}
+}
+
+// WithFix returns r repaired by fix
+func (r Result) WithFix(fix func() error) Result {
+	r.Fix = fix
+	return r
+}
+
+// Check inspects one aspect of the installation
+type Check struct {
+	Name        string
+	Description string
+	Run         func(f env.Factory) []Result
+}
+
+// Report is a Result of a named check, after it has been repaired if requested
+type Report struct {
+	Check string
+	Result
+	// Fixed is set, if the problem has been repaired
+	Fixed bool
+	// FixErr is the error of a failed repair
+	FixErr error
+}
+
+// Doctor runs the registered checks
+type Doctor struct {
+	checks []Check
+}
+
+// New returns a Doctor without any checks
+func New() *Doctor {
+	return &Doctor{}
+}
+
+// Register adds checks, which are run in the order of their registration
+func (d *Doctor) Register(checks ...Check) {
+	d.checks = append(d.checks, checks...)
+}
+
+// Checks returns the registered checks
+func (d *Doctor) Checks() []Check {
+	return d.checks
+}
+
+// Run runs all checks. If fix is set, the fixable problems get repaired.
+func (d *Doctor) Run(f env.Factory, fix bool) (reports []Report) {
+	for _, c := range d.checks {
+		for _, r := range c.Run(f) {
+			report := Report{Check: c.Name, Result: r}
+			if fix && r.Fix != nil && r.Status != StatusOK {
+				report.FixErr = r.Fix()
+				report.Fixed = report.FixErr == nil
+			}
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
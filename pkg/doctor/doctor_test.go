@@ -0,0 +1,98 @@
+package doctor
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/spec"
+)
+
+func TestDoctor_Run(t *testing.T) {
+	fixed := false
+	d := New()
+	d.Register(
+		Check{Name: "ok", Run: func(env.Factory) []Result { return []Result{OK("fine")} }},
+		Check{Name: "fixable", Run: func(env.Factory) []Result {
+			return []Result{Warn("broken", "fix it").WithFix(func() error { fixed = true; return nil })}
+		}},
+		Check{Name: "unfixable", Run: func(env.Factory) []Result {
+			return []Result{Fail("broken", "").WithFix(func() error { return errors.New("nope") })}
+		}},
+	)
+
+	reports := d.Run(nil, false)
+	require.Len(t, reports, 3)
+	assert.False(t, fixed)
+	assert.False(t, reports[1].Fixed)
+
+	reports = d.Run(nil, true)
+	assert.True(t, fixed)
+	assert.Equal(t, "fixable", reports[1].Check)
+	assert.True(t, reports[1].Fixed)
+	assert.False(t, reports[2].Fixed)
+	assert.EqualError(t, reports[2].FixErr, "nope")
+}
+
+func TestCheckReceipts_SymlinkedRoot(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "devctl-doctor")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+	root := filepath.Join(tmp, "link")
+	require.NoError(t, os.MkdirAll(filepath.Join(tmp, "root"), 0755))
+	require.NoError(t, os.Symlink(filepath.Join(tmp, "root"), root))
+	f := env.NewFactory(env.WithPaths(root))
+
+	r := spec.Receipt{}
+	r.Name, r.Spec.Version = "foo", "v1.0.0"
+	r.Spec.Platforms = []spec.Platform{{Selector: &metav1.LabelSelector{}, Bin: "foo"}}
+	require.NoError(t, os.MkdirAll(f.Paths().InstallReceiptsPath(), 0755))
+	require.NoError(t, installation.Store(f.Fs(), r, f.Paths().PluginInstallReceiptPath("foo")))
+	store := f.Paths().PluginVersionInstallPath("foo", "v1.0.0")
+	require.NoError(t, os.MkdirAll(store, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(store, "foo"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.MkdirAll(f.Paths().BinPath(), 0755))
+	require.NoError(t, os.Symlink(filepath.Join(store, "foo"), installation.LinkPath(f, "foo", r.Spec.Platforms[0])))
+
+	results := checkReceipts(f)
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusOK, results[0].Status, results[0].Message)
+}
+
+func TestChecks_StoreAndLinks(t *testing.T) {
+	root, err := ioutil.TempDir("", "devctl-doctor")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	f := env.NewFactory(env.WithPaths(root))
+
+	orphan := filepath.Join(f.Paths().InstallPath(), "orphan", "v1.0.0")
+	require.NoError(t, os.MkdirAll(orphan, 0755))
+	require.NoError(t, os.MkdirAll(f.Paths().BinPath(), 0755))
+	dangling := filepath.Join(f.Paths().BinPath(), "devctl-gone")
+	require.NoError(t, os.Symlink(filepath.Join(root, "missing"), dangling))
+
+	results := checkStore(f)
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusWarn, results[0].Status)
+	require.NoError(t, results[0].Fix())
+	assert.NoDirExists(t, filepath.Dir(orphan))
+	assert.DirExists(t, f.Paths().StatePath("store-orphans", "orphan", "v1.0.0"), "the directory is moved aside")
+
+	results = checkLinks(f)
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusWarn, results[0].Status)
+	require.NoError(t, results[0].Fix())
+	_, err = os.Lstat(dangling)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, StatusOK, checkStore(f)[0].Status)
+	assert.Equal(t, StatusOK, checkLinks(f)[0].Status)
+}
@@ -0,0 +1,545 @@
+// Package dotfiles applies the dotfiles declared in the devctl configuration to the home directory.
+//
+// The dotfiles are either a git repository, which is cloned into the data directory of devctl, or a local directory.
+// Every file is linked, or copied, to the same path below $HOME. Files ending in .tmpl are rendered as Go templates,
+// using the DEVCTL_PATH_* vars and the values of the configuration, and copied without the extension.
+// Conflicting files are moved to a backup directory, which is restored by Unlink.
+package dotfiles
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/alex-held/devctl/internal/git"
+	"github.com/alex-held/devctl/pkg/config"
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// TemplateExt marks the dotfiles, which are rendered as Go templates
+const TemplateExt = ".tmpl"
+
+// ErrNotConfigured is returned, if the configuration declares no dotfiles
+var ErrNotConfigured = errors.New("no dotfiles configured, declare a dotfiles repo or path in the devctl configuration")
+
+// Kind is how a dotfile is applied
+type Kind string
+
+const (
+	KindSymlink  Kind = "symlink"
+	KindCopy     Kind = "copy"
+	KindTemplate Kind = "template"
+)
+
+// Entry is a dotfile, which has been applied to the home directory
+type Entry struct {
+	// Name is the path of the dotfile, relative to the dotfiles and to the home directory
+	Name   string `yaml:"name"`
+	Source string `yaml:"source"`
+	Target string `yaml:"target"`
+	Kind   Kind   `yaml:"kind"`
+	// Hash is the sha256 of copied and rendered content, it detects local modifications
+	Hash string `yaml:"hash,omitempty"`
+	// Backup is the conflicting file, which has been moved away while applying the dotfile
+	Backup string `yaml:"backup,omitempty"`
+}
+
+// state are the dotfiles applied by the last apply
+type state struct {
+	Entries []Entry `yaml:"entries"`
+}
+
+// Action is what applying or unlinking does to a dotfile
+type Action string
+
+const (
+	ActionCreate    Action = "create"
+	ActionUpdate    Action = "update"
+	ActionUnchanged Action = "unchanged"
+	ActionRemove    Action = "remove"
+	// ActionRestore removes the dotfile and moves the file, which it replaced, back
+	ActionRestore Action = "restore"
+	// ActionKeep keeps a dotfile, which has been modified since it was applied
+	ActionKeep Action = "keep"
+)
+
+// Change is the change applied to a target
+type Change struct {
+	Name   string
+	Target string
+	Action Action
+	// Backup is the location the conflicting target is moved to, or restored from
+	Backup string
+}
+
+// Status is the drift of a dotfile
+type Status string
+
+const (
+	StatusOK Status = "ok"
+	// StatusMissing is a dotfile, whose target has been removed
+	StatusMissing Status = "missing"
+	// StatusModified is a dotfile, whose target has been changed since it was applied
+	StatusModified Status = "modified"
+	// StatusOutdated is a dotfile, which has changed since it was applied
+	StatusOutdated Status = "outdated"
+	// StatusNotApplied is a dotfile, which has not been applied yet
+	StatusNotApplied Status = "not applied"
+	// StatusRemoved is an applied dotfile, which has been removed from the dotfiles
+	StatusRemoved Status = "removed"
+)
+
+// FileStatus is the status of a dotfile
+type FileStatus struct {
+	Name   string
+	Target string
+	Status Status
+}
+
+// Manager applies the dotfiles of a configuration to a home directory
+type Manager struct {
+	f   env.Factory
+	cfg config.Dotfiles
+	// Home is the directory the dotfiles are applied to
+	Home string
+	now  func() time.Time
+}
+
+// New returns the Manager of the dotfiles configured for f, which applies them to $HOME
+func New(f env.Factory) (*Manager, error) {
+	file, err := f.Config()
+	if err != nil {
+		return nil, err
+	}
+	if file.Config.Dotfiles == nil {
+		return nil, ErrNotConfigured
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get user home dir")
+	}
+	return NewManager(f, *file.Config.Dotfiles, home), nil
+}
+
+// NewManager returns the Manager of the dotfiles cfg, which applies them to home
+func NewManager(f env.Factory, cfg config.Dotfiles, home string) *Manager {
+	return &Manager{f: f, cfg: cfg, Home: home, now: time.Now}
+}
+
+// Source returns the absolute directory of the dotfiles, so that the links don't depend on the working directory.
+// A path starting with ~/, or a relative path, is resolved against the home directory.
+func (m *Manager) Source() string {
+	dir := m.cfg.Path
+	switch {
+	case m.cfg.Repo != "":
+		dir = m.f.Paths().DotfilesPath()
+	case strings.HasPrefix(dir, "~/"):
+		dir = filepath.Join(m.Home, dir[2:])
+	case !filepath.IsAbs(dir):
+		dir = filepath.Join(m.Home, dir)
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// Sync clones or updates the dotfiles repository and checks out the configured ref. Local dotfiles are left alone.
+func (m *Manager) Sync() error {
+	if m.cfg.Repo == "" {
+		return nil
+	}
+	dir := m.Source()
+	if m.cfg.Ref == "" {
+		return errors.Wrap(git.EnsureUpdated(m.cfg.Repo, dir), "failed to update the dotfiles repository")
+	}
+
+	if err := git.EnsureCloned(m.cfg.Repo, dir); err != nil {
+		return errors.Wrap(err, "failed to clone the dotfiles repository")
+	}
+	if _, err := git.Exec(dir, "fetch", "-q", "--tags", "origin"); err != nil {
+		return errors.Wrap(err, "failed to fetch the dotfiles repository")
+	}
+	// branches are checked out at their remote state, tags and commits as they are
+	ref := m.cfg.Ref
+	if commit, err := git.Exec(dir, "rev-parse", "-q", "--verify", "origin/"+ref+"^{commit}"); err == nil {
+		ref = commit
+	}
+	_, err := git.Exec(dir, "checkout", "-q", "--detach", ref)
+	return errors.Wrapf(err, "failed to check out %s of the dotfiles repository", m.cfg.Ref)
+}
+
+// Vars returns the variables available to the templates: the DEVCTL_PATH_* vars, like for the dynamic plugin config,
+// and the values of the configuration as .values
+func (m *Manager) Vars() map[string]interface{} {
+	vars := map[string]interface{}{"values": m.cfg.Values}
+	for k, v := range env.PathVars(m.f.Pather(), m.Home) {
+		vars[k] = v
+	}
+	return vars
+}
+
+// file is a dotfile of the source directory
+type file struct {
+	Entry
+	mode    os.FileMode
+	content []byte
+}
+
+// files returns the dotfiles of the source directory in order, rendering the templates
+func (m *Manager) files() ([]file, error) {
+	src := m.Source()
+	if fi, err := os.Stat(src); err != nil || !fi.IsDir() {
+		if m.cfg.Repo != "" {
+			return nil, errors.Errorf("the dotfiles repository has not been cloned to %s yet, run 'devctl dotfiles apply'", src)
+		}
+		return nil, errors.Errorf("the dotfiles directory %s does not exist", src)
+	}
+
+	var files []file
+	err := filepath.Walk(src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if fi.IsDir() {
+			if fi.Name() == ".git" || m.ignored(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if m.ignored(rel) {
+			return nil
+		}
+
+		f := file{Entry: Entry{Name: rel, Source: p, Kind: KindSymlink}, mode: fi.Mode().Perm()}
+		switch {
+		case strings.HasSuffix(rel, TemplateExt):
+			f.Name, f.Kind = strings.TrimSuffix(rel, TemplateExt), KindTemplate
+			if f.content, err = m.render(p, rel); err != nil {
+				return err
+			}
+		case m.cfg.Mode == config.DotfilesModeCopy:
+			f.Kind = KindCopy
+			if f.content, err = ioutil.ReadFile(p); err != nil {
+				return err
+			}
+		}
+		f.Target = filepath.Join(m.Home, filepath.FromSlash(f.Name))
+		if f.Kind != KindSymlink {
+			f.Hash = hash(f.content)
+		}
+		files = append(files, f)
+		return nil
+	})
+	return files, errors.Wrap(err, "failed to read the dotfiles")
+}
+
+// ignored matches the configured ignore patterns against the path and the name of a dotfile
+func (m *Manager) ignored(rel string) bool {
+	for _, pattern := range m.cfg.Ignore {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) render(p, name string) ([]byte, error) {
+	text, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", name)
+	}
+	out := &bytes.Buffer{}
+	if err := t.Execute(out, m.Vars()); err != nil {
+		return nil, errors.Wrapf(err, "failed to template %s", name)
+	}
+	return out.Bytes(), nil
+}
+
+func hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// inSync checks whether the target of f is what applying f would create
+func inSync(f file) bool {
+	if f.Kind == KindSymlink {
+		link, err := os.Readlink(f.Target)
+		return err == nil && link == f.Source
+	}
+	fi, err := os.Lstat(f.Target)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	content, err := ioutil.ReadFile(f.Target)
+	return err == nil && bytes.Equal(content, f.content)
+}
+
+// owned checks whether the target of e is still what has been applied, i.e. it may be replaced or removed without a backup
+func owned(e Entry) bool {
+	if e.Kind == KindSymlink {
+		link, err := os.Readlink(e.Target)
+		return err == nil && link == e.Source
+	}
+	fi, err := os.Lstat(e.Target)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	content, err := ioutil.ReadFile(e.Target)
+	return err == nil && hash(content) == e.Hash
+}
+
+func exists(p string) bool {
+	_, err := os.Lstat(p)
+	return err == nil
+}
+
+// Apply links, copies and renders the dotfiles into the home directory. Conflicting files are moved to a backup directory.
+// Dotfiles applied before, which have been removed from the dotfiles, are removed from the home directory.
+func (m *Manager) Apply(dryRun bool) ([]Change, error) {
+	files, err := m.files()
+	if err != nil {
+		return nil, err
+	}
+	st, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	applied := map[string]Entry{}
+	for _, e := range st.Entries {
+		applied[e.Name] = e
+	}
+	backupDir := m.f.Paths().StatePath("dotfiles-backup", m.now().Format("20060102-150405"))
+
+	var changes []Change
+	next := &state{}
+	// fail records the entries applied so far and the pending ones, so that unlink still restores their backups
+	fail := func(err error, pending []Entry) ([]Change, error) {
+		next.Entries = append(next.Entries, pending...)
+		if saveErr := m.save(next); saveErr != nil {
+			return changes, errors.Errorf("%v; %v", err, saveErr)
+		}
+		return changes, err
+	}
+	for _, f := range files {
+		prev, wasApplied := applied[f.Name]
+		delete(applied, f.Name)
+		f.Backup = prev.Backup
+
+		ch := Change{Name: f.Name, Target: f.Target, Action: ActionCreate}
+		switch {
+		case inSync(f):
+			ch.Action = ActionUnchanged
+		case exists(f.Target):
+			ch.Action = ActionUpdate
+			if !wasApplied || !owned(prev) {
+				ch.Backup = filepath.Join(backupDir, filepath.FromSlash(f.Name))
+			}
+		}
+		changes = append(changes, ch)
+		if ch.Backup != "" && f.Backup == "" {
+			// the original file is restored by unlink, later backups are only kept
+			f.Backup = ch.Backup
+		}
+		next.Entries = append(next.Entries, f.Entry)
+
+		if dryRun || ch.Action == ActionUnchanged {
+			continue
+		}
+		if err := m.apply(f, ch); err != nil {
+			return fail(errors.Wrapf(err, "failed to apply %s", f.Name), sortedEntries(applied))
+		}
+	}
+
+	removed := sortedEntries(applied)
+	for i, e := range removed {
+		ch, err := m.remove(e, dryRun)
+		if err != nil {
+			if dryRun {
+				return changes, err
+			}
+			return fail(err, removed[i:])
+		}
+		changes = append(changes, ch)
+		if ch.Action == ActionKeep {
+			next.Entries = append(next.Entries, e)
+		}
+	}
+
+	if dryRun {
+		return changes, nil
+	}
+	return changes, m.save(next)
+}
+
+func (m *Manager) apply(f file, ch Change) error {
+	if err := os.MkdirAll(filepath.Dir(f.Target), 0755); err != nil {
+		return err
+	}
+	switch {
+	case ch.Backup != "":
+		if err := os.MkdirAll(filepath.Dir(ch.Backup), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(f.Target, ch.Backup); err != nil {
+			return errors.Wrapf(err, "failed to back up %s", f.Target)
+		}
+	case ch.Action == ActionUpdate:
+		if err := os.Remove(f.Target); err != nil {
+			return err
+		}
+	}
+
+	if f.Kind == KindSymlink {
+		return os.Symlink(f.Source, f.Target)
+	}
+	return ioutil.WriteFile(f.Target, f.content, f.mode)
+}
+
+// remove removes the target of e, unless it has been modified, and restores its backup
+func (m *Manager) remove(e Entry, dryRun bool) (Change, error) {
+	ch := Change{Name: e.Name, Target: e.Target, Action: ActionRemove}
+	switch {
+	case !exists(e.Target):
+	case !owned(e):
+		ch.Action = ActionKeep
+		return ch, nil
+	case !dryRun:
+		if err := os.Remove(e.Target); err != nil {
+			return ch, errors.Wrapf(err, "failed to remove %s", e.Target)
+		}
+	}
+
+	if e.Backup == "" || !exists(e.Backup) {
+		return ch, nil
+	}
+	ch.Action, ch.Backup = ActionRestore, e.Backup
+	if dryRun {
+		return ch, nil
+	}
+	return ch, errors.Wrapf(os.Rename(e.Backup, e.Target), "failed to restore %s", e.Target)
+}
+
+// Status compares the dotfiles to the home directory
+func (m *Manager) Status() ([]FileStatus, error) {
+	files, err := m.files()
+	if err != nil {
+		return nil, err
+	}
+	st, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	applied := map[string]Entry{}
+	for _, e := range st.Entries {
+		applied[e.Name] = e
+	}
+
+	var status []FileStatus
+	for _, f := range files {
+		s := FileStatus{Name: f.Name, Target: f.Target}
+		prev, ok := applied[f.Name]
+		delete(applied, f.Name)
+		switch {
+		case !ok:
+			s.Status = StatusNotApplied
+		case !exists(f.Target):
+			s.Status = StatusMissing
+		case inSync(f):
+			s.Status = StatusOK
+		case owned(prev):
+			s.Status = StatusOutdated
+		default:
+			s.Status = StatusModified
+		}
+		status = append(status, s)
+	}
+	for _, e := range sortedEntries(applied) {
+		status = append(status, FileStatus{Name: e.Name, Target: e.Target, Status: StatusRemoved})
+	}
+	return status, nil
+}
+
+// Unlink removes the applied dotfiles from the home directory and restores the files they replaced.
+// Dotfiles, which have been modified since they were applied, are kept.
+func (m *Manager) Unlink(dryRun bool) ([]Change, error) {
+	st, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, e := range st.Entries {
+		ch, err := m.remove(e, dryRun)
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, ch)
+	}
+	if dryRun {
+		return changes, nil
+	}
+	if err := os.Remove(m.statePath()); err != nil && !os.IsNotExist(err) {
+		return changes, errors.Wrap(err, "failed to remove the dotfiles state")
+	}
+	return changes, nil
+}
+
+func (m *Manager) statePath() string {
+	return m.f.Paths().StatePath("dotfiles.yaml")
+}
+
+func (m *Manager) load() (*state, error) {
+	st := &state{}
+	b, err := ioutil.ReadFile(m.statePath())
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the dotfiles state")
+	}
+	return st, errors.Wrap(yaml.Unmarshal(b, st), "failed to parse the dotfiles state")
+}
+
+func (m *Manager) save(st *state) error {
+	b, err := yaml.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(m.statePath()), 0755); err != nil {
+		return errors.Wrap(err, "failed to create the state directory")
+	}
+	return errors.Wrap(ioutil.WriteFile(m.statePath(), b, 0644), "failed to write the dotfiles state")
+}
+
+func sortedEntries(entries map[string]Entry) []Entry {
+	sorted := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
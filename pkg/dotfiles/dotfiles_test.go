@@ -0,0 +1,185 @@
+package dotfiles
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/devctl/pkg/config"
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+func write(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func read(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func actions(changes []Change) map[string]Action {
+	m := map[string]Action{}
+	for _, c := range changes {
+		m[c.Name] = c.Action
+	}
+	return m
+}
+
+func statuses(status []FileStatus) map[string]Status {
+	m := map[string]Status{}
+	for _, s := range status {
+		m[s.Name] = s.Status
+	}
+	return m
+}
+
+func TestManager(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "devctl-dotfiles")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+	src, home := filepath.Join(tmp, "dotfiles"), filepath.Join(tmp, "home")
+	f := env.NewFactory(env.WithPaths(filepath.Join(tmp, "devctl")))
+
+	write(t, filepath.Join(src, ".zshrc"), "source ~/.devctl/init.zsh\n")
+	write(t, filepath.Join(src, ".gitconfig.tmpl"), "email = {{ .values.email }}\nsdks = {{ .DEVCTL_PATH_SDK }}\n")
+	write(t, filepath.Join(src, ".config", "nvim", "init.vim"), "set number\n")
+	write(t, filepath.Join(src, "README.md"), "my dotfiles\n")
+	write(t, filepath.Join(src, ".git", "HEAD"), "ref: refs/heads/main\n")
+	write(t, filepath.Join(home, ".zshrc"), "# the original zshrc\n")
+
+	m := NewManager(f, config.Dotfiles{
+		Path:   src,
+		Values: map[string]string{"email": "alex@example.com"},
+		Ignore: []string{"*.md"},
+	}, home)
+	m.now = func() time.Time { return time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC) }
+
+	changes, err := m.Apply(true)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Action{
+		".config/nvim/init.vim": ActionCreate,
+		".gitconfig":            ActionCreate,
+		".zshrc":                ActionUpdate,
+	}, actions(changes))
+	assert.Equal(t, "# the original zshrc\n", read(t, filepath.Join(home, ".zshrc")), "a dry run changes nothing")
+
+	_, err = m.Apply(false)
+	require.NoError(t, err)
+	link, err := os.Readlink(filepath.Join(home, ".zshrc"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(src, ".zshrc"), link)
+	backup := f.Paths().StatePath("dotfiles-backup", "20210701-120000", ".zshrc")
+	assert.Equal(t, "# the original zshrc\n", read(t, backup))
+	assert.Equal(t, "email = alex@example.com\nsdks = "+f.Pather().SDK()+"\n", read(t, filepath.Join(home, ".gitconfig")))
+	assert.FileExists(t, filepath.Join(home, ".config", "nvim", "init.vim"))
+	assert.NoFileExists(t, filepath.Join(home, "README.md"))
+	assert.NoDirExists(t, filepath.Join(home, ".git"))
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Status{".config/nvim/init.vim": StatusOK, ".gitconfig": StatusOK, ".zshrc": StatusOK}, statuses(status))
+
+	// drift: a local change, a changed template and a removed dotfile
+	write(t, filepath.Join(src, ".gitconfig.tmpl"), "email = {{ .values.email }}\n")
+	require.NoError(t, os.Remove(filepath.Join(src, ".config", "nvim", "init.vim")))
+	write(t, filepath.Join(src, ".vimrc"), "set number\n")
+	status, err = m.Status()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Status{
+		".config/nvim/init.vim": StatusRemoved,
+		".gitconfig":            StatusOutdated,
+		".vimrc":                StatusNotApplied,
+		".zshrc":                StatusOK,
+	}, statuses(status))
+	write(t, filepath.Join(home, ".gitconfig"), "email = me@example.com\n")
+	status, err = m.Status()
+	require.NoError(t, err)
+	assert.Equal(t, StatusModified, statuses(status)[".gitconfig"])
+
+	m.now = func() time.Time { return time.Date(2021, 7, 2, 12, 0, 0, 0, time.UTC) }
+	changes, err = m.Apply(false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Action{
+		".config/nvim/init.vim": ActionRemove,
+		".gitconfig":            ActionUpdate,
+		".vimrc":                ActionCreate,
+		".zshrc":                ActionUnchanged,
+	}, actions(changes))
+	assert.Equal(t, "email = me@example.com\n", read(t, f.Paths().StatePath("dotfiles-backup", "20210702-120000", ".gitconfig")), "local changes are backed up")
+	assert.NoFileExists(t, filepath.Join(home, ".config", "nvim", "init.vim"))
+
+	changes, err = m.Unlink(false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Action{
+		".gitconfig": ActionRestore,
+		".vimrc":     ActionRemove,
+		".zshrc":     ActionRestore,
+	}, actions(changes))
+	assert.Equal(t, "# the original zshrc\n", read(t, filepath.Join(home, ".zshrc")))
+	assert.Equal(t, "email = me@example.com\n", read(t, filepath.Join(home, ".gitconfig")))
+	assert.NoFileExists(t, filepath.Join(home, ".vimrc"))
+
+	status, err = m.Status()
+	require.NoError(t, err)
+	for name, s := range statuses(status) {
+		assert.Equal(t, StatusNotApplied, s, name)
+	}
+}
+
+func TestManager_ApplyError(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "devctl-dotfiles")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+	src, home := filepath.Join(tmp, "dotfiles"), filepath.Join(tmp, "home")
+	f := env.NewFactory(env.WithPaths(filepath.Join(tmp, "devctl")))
+
+	write(t, filepath.Join(src, ".zshrc"), "source ~/.devctl/init.zsh\n")
+	write(t, filepath.Join(src, "bin", "hello"), "echo hello\n")
+	write(t, filepath.Join(home, ".zshrc"), "# the original zshrc\n")
+	// the target of bin/hello can't be created below a file
+	write(t, filepath.Join(home, "bin"), "not a directory\n")
+
+	m := NewManager(f, config.Dotfiles{Path: src}, home)
+	_, err = m.Apply(false)
+	assert.Error(t, err)
+
+	changes, err := m.Unlink(false)
+	require.NoError(t, err)
+	assert.Equal(t, ActionRestore, actions(changes)[".zshrc"], "the backup is recorded although apply failed")
+	assert.Equal(t, "# the original zshrc\n", read(t, filepath.Join(home, ".zshrc")))
+}
+
+func TestManager_MissingValue(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "devctl-dotfiles")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+	write(t, filepath.Join(tmp, "dotfiles", ".gitconfig.tmpl"), "email = {{ .values.email }}\n")
+
+	m := NewManager(env.NewFactory(env.WithPaths(filepath.Join(tmp, "devctl"))), config.Dotfiles{Path: filepath.Join(tmp, "dotfiles")}, filepath.Join(tmp, "home"))
+	_, err = m.Apply(false)
+	assert.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(tmp, "home", ".gitconfig"))
+}
+
+func TestManager_Source(t *testing.T) {
+	f := env.NewFactory(env.WithPaths("devctl"))
+	for path, expected := range map[string]string{
+		"/dotfiles":   "/dotfiles",
+		"~/dotfiles":  "/home/user/dotfiles",
+		"dotfiles":    "/home/user/dotfiles",
+		"~/a/../b/./": "/home/user/b",
+	} {
+		assert.Equal(t, expected, NewManager(f, config.Dotfiles{Path: path}, "/home/user").Source(), path)
+	}
+
+	repo := NewManager(f, config.Dotfiles{Repo: "https://example.com/dotfiles"}, "/home/user").Source()
+	assert.True(t, filepath.IsAbs(repo), "a relative devctl root results in links to absolute sources")
+}
@@ -0,0 +1,198 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alex-held/devctl-kit/pkg/devctlpath"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+
+	"github.com/alex-held/devctl/pkg/config"
+	"github.com/alex-held/devctl/pkg/constants"
+)
+
+// Layout is the way the devctl directories are laid out on disk
+type Layout string
+
+const (
+	// LayoutRoot keeps everything below a single root directory, by default ~/.devctl
+	LayoutRoot Layout = "root"
+	// LayoutXDG splits the directories according to the XDG Base Directory Specification
+	LayoutXDG Layout = "xdg"
+)
+
+// DEVCTL_LAYOUT_KEY selects the Layout, unless a root has been set explicitly
+const DEVCTL_LAYOUT_KEY = "DEVCTL_LAYOUT"
+
+// Dirs are the directories devctl stores its files in
+type Dirs struct {
+	Layout Layout
+	// Config contains the configuration file and the plugin configurations
+	Config string
+	// Data contains the indexes, receipts, installed plugins, sdks and binaries
+	Data string
+	// Cache contains files which can be recreated at any time
+	Cache string
+	// State contains files like logs and histories, which aren't worth a backup
+	State string
+	// ConfigFile is the configuration file 'root' or 'layout' has been read from, see ResolveDirs.
+	// It stays the configuration file, so that the setting can be changed again. It defaults to config.yaml in Config.
+	ConfigFile string
+}
+
+// RootDirs returns the Dirs of the LayoutRoot below root
+func RootDirs(root string) Dirs {
+	return Dirs{
+		Layout: LayoutRoot,
+		Config: root,
+		Data:   root,
+		Cache:  filepath.Join(root, "cache"),
+		State:  root,
+	}
+}
+
+// XDGDirs returns the Dirs of the LayoutXDG, honoring the XDG_*_HOME environment variables
+func XDGDirs(home string) Dirs {
+	dir := func(key string, fallback ...string) string {
+		if v := os.Getenv(key); filepath.IsAbs(v) {
+			return filepath.Join(v, "devctl")
+		}
+		return filepath.Join(append([]string{home}, append(fallback, "devctl")...)...)
+	}
+	return Dirs{
+		Layout: LayoutXDG,
+		Config: dir("XDG_CONFIG_HOME", ".config"),
+		Data:   dir("XDG_DATA_HOME", ".local", "share"),
+		Cache:  dir("XDG_CACHE_HOME", ".cache"),
+		State:  dir("XDG_STATE_HOME", ".local", "state"),
+	}
+}
+
+// DefaultRoot returns the default root directory ~/.devctl
+func DefaultRoot(home string) string {
+	return filepath.Join(home, constants.DefaultDevctlDir)
+}
+
+// ResolveDirs resolves the directories of devctl. The first match wins:
+//
+//  1. root, usually the value of the --devctl-path flag
+//  2. the DEVCTL_ROOT environment variable
+//  3. the DEVCTL_LAYOUT environment variable
+//  4. 'root' or 'layout' of the LocationFiles ~/.devctl/config.yaml, followed by $XDG_CONFIG_HOME/devctl/config.yaml
+//  5. the default root ~/.devctl
+//
+// In the 4th case, the file declaring 'root' or 'layout' remains the configuration file.
+func ResolveDirs(fs afero.Fs, root string) (Dirs, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return Dirs{}, errors.Wrap(err, "cannot get user home dir")
+	}
+
+	if root != "" {
+		return rootDirs(home, root)
+	}
+	if fromEnv := os.Getenv(constants.DEVCTL_ROOT_KEY); fromEnv != "" {
+		return rootDirs(home, fromEnv)
+	}
+	if fromEnv := os.Getenv(DEVCTL_LAYOUT_KEY); fromEnv != "" {
+		return layoutDirs(home, Layout(fromEnv))
+	}
+
+	for _, path := range LocationFiles(home) {
+		loc, err := config.ReadLocation(fs, path)
+		if err != nil {
+			return Dirs{}, err
+		}
+		var dirs Dirs
+		switch {
+		case loc.Root != "":
+			dirs, err = rootDirs(home, loc.Root)
+		case loc.Layout != "":
+			dirs, err = layoutDirs(home, Layout(loc.Layout))
+		default:
+			continue
+		}
+		if path != filepath.Join(dirs.Config, "config.yaml") {
+			dirs.ConfigFile = path
+		}
+		return dirs, err
+	}
+	return RootDirs(DefaultRoot(home)), nil
+}
+
+// LocationFiles are the configuration files, which are read for 'root' and 'layout' before the directories are known
+func LocationFiles(home string) []string {
+	return []string{
+		filepath.Join(DefaultRoot(home), "config.yaml"),
+		filepath.Join(XDGDirs(home).Config, "config.yaml"),
+	}
+}
+
+func rootDirs(home, root string) (Dirs, error) {
+	root = os.ExpandEnv(root)
+	if root == "~" || strings.HasPrefix(root, "~/") {
+		root = filepath.Join(home, root[1:])
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return Dirs{}, errors.Wrap(err, "cannot get absolute path")
+	}
+	return RootDirs(root), nil
+}
+
+func layoutDirs(home string, layout Layout) (Dirs, error) {
+	switch layout {
+	case LayoutRoot:
+		return RootDirs(DefaultRoot(home)), nil
+	case LayoutXDG:
+		return XDGDirs(home), nil
+	default:
+		return Dirs{}, errors.Errorf("unknown layout %q, must be %q or %q", layout, LayoutRoot, LayoutXDG)
+	}
+}
+
+// NewPather returns a devctlpath.Pather, which resolves its paths below d
+func NewPather(d Dirs) devctlpath.Pather {
+	return dirsPather{d: d}
+}
+
+type dirsPather struct {
+	d Dirs
+}
+
+func (p dirsPather) ConfigFilePath() string {
+	if p.d.ConfigFile != "" {
+		return p.d.ConfigFile
+	}
+	return filepath.Join(p.d.Config, "config.yaml")
+}
+
+func (p dirsPather) ConfigRoot(elem ...string) string { return join(p.d.Config, elem) }
+
+func (p dirsPather) Config(elem ...string) string {
+	return join(p.d.Config, append([]string{"config"}, elem...))
+}
+
+func (p dirsPather) Bin(elem ...string) string {
+	return join(p.d.Data, append([]string{"bin"}, elem...))
+}
+
+func (p dirsPather) Download(elem ...string) string {
+	return join(p.d.Data, append([]string{"downloads"}, elem...))
+}
+
+func (p dirsPather) SDK(elem ...string) string {
+	return join(p.d.Data, append([]string{"sdks"}, elem...))
+}
+
+func (p dirsPather) Cache(elem ...string) string { return join(p.d.Cache, elem) }
+
+func (p dirsPather) Plugin(elem ...string) string {
+	return join(p.d.Data, append([]string{"plugins"}, elem...))
+}
+
+func join(base string, elem []string) string {
+	return filepath.Join(base, filepath.Join(elem...))
+}
@@ -0,0 +1,152 @@
+package env
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setenv sets the environment variables and returns a func restoring them
+func setenv(t *testing.T, kv map[string]string) func() {
+	old := map[string]*string{}
+	for k, v := range kv {
+		if prev, ok := os.LookupEnv(k); ok {
+			old[k] = &prev
+		} else {
+			old[k] = nil
+		}
+		require.NoError(t, os.Setenv(k, v))
+	}
+	return func() {
+		for k, v := range old {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+func TestResolveDirs(t *testing.T) {
+	home := "/home/user"
+	tests := []struct {
+		name   string
+		flag   string
+		env    map[string]string
+		files  map[string]string
+		expect Dirs
+		err    string
+	}{
+		{name: "default", expect: RootDirs("/home/user/.devctl")},
+		{name: "flag", flag: "/flag", env: map[string]string{"DEVCTL_ROOT": "/env"}, expect: RootDirs("/flag")},
+		{name: "flag with tilde", flag: "~/flag", expect: RootDirs("/home/user/flag")},
+		{name: "env root", env: map[string]string{"DEVCTL_ROOT": "/env", "DEVCTL_LAYOUT": "xdg"}, expect: RootDirs("/env")},
+		{name: "env layout", env: map[string]string{"DEVCTL_LAYOUT": "xdg"}, expect: XDGDirs(home)},
+		{name: "env unknown layout", env: map[string]string{"DEVCTL_LAYOUT": "foo"}, err: `unknown layout "foo"`},
+		{
+			name:   "configured root",
+			files:  map[string]string{"/home/user/.devctl/config.yaml": "root: /configured\n"},
+			expect: Dirs{Layout: LayoutRoot, Config: "/configured", Data: "/configured", Cache: "/configured/cache", State: "/configured", ConfigFile: "/home/user/.devctl/config.yaml"},
+		},
+		{
+			name:   "configured xdg layout in the root configuration",
+			files:  map[string]string{"/home/user/.devctl/config.yaml": "layout: xdg\n"},
+			expect: Dirs{Layout: LayoutXDG, Config: "/home/user/.config/devctl", Data: "/home/user/.local/share/devctl", Cache: "/home/user/.cache/devctl", State: "/home/user/.local/state/devctl", ConfigFile: "/home/user/.devctl/config.yaml"},
+		},
+		{
+			name:   "configured xdg layout",
+			files:  map[string]string{"/xdg/config/devctl/config.yaml": "layout: xdg\n"},
+			env:    map[string]string{"XDG_CONFIG_HOME": "/xdg/config", "XDG_DATA_HOME": "/xdg/data"},
+			expect: Dirs{Layout: LayoutXDG, Config: "/xdg/config/devctl", Data: "/xdg/data/devctl", Cache: "/home/user/.cache/devctl", State: "/home/user/.local/state/devctl"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"HOME": home, "DEVCTL_ROOT": "", "DEVCTL_LAYOUT": "", "XDG_CONFIG_HOME": "", "XDG_DATA_HOME": "", "XDG_CACHE_HOME": "", "XDG_STATE_HOME": ""}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			defer setenv(t, env)()
+			fs := afero.NewMemMapFs()
+			for path, content := range tt.files {
+				require.NoError(t, afero.WriteFile(fs, path, []byte(content), 0644))
+			}
+
+			dirs, err := ResolveDirs(fs, tt.flag)
+			if tt.err != "" {
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, dirs)
+		})
+	}
+}
+
+func TestNewPather(t *testing.T) {
+	root := NewPather(RootDirs("/root/.devctl"))
+	assert.Equal(t, "/root/.devctl/config.yaml", root.ConfigFilePath())
+	assert.Equal(t, "/root/.devctl/bin", root.Bin())
+	assert.Equal(t, "/root/.devctl/cache/plugins", root.Cache("plugins"))
+
+	assert.Equal(t, "/home/user/.devctl/config.yaml", NewPather(Dirs{Config: "/other", ConfigFile: "/home/user/.devctl/config.yaml"}).ConfigFilePath())
+
+	xdg := NewPather(Dirs{Layout: LayoutXDG, Config: "/c", Data: "/d", Cache: "/x", State: "/s"})
+	assert.Equal(t, "/c/config.yaml", xdg.ConfigFilePath())
+	assert.Equal(t, "/c/config/golang", xdg.Config("golang"))
+	assert.Equal(t, "/d/sdks/go", xdg.SDK("go"))
+	assert.Equal(t, "/d/plugins", xdg.Plugin())
+	assert.Equal(t, "/x", xdg.Cache())
+}
+
+func TestMigration(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "devctl-migrate")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	from := RootDirs(filepath.Join(tmp, ".devctl"))
+	to := Dirs{
+		Layout: LayoutXDG,
+		Config: filepath.Join(tmp, "config"),
+		Data:   filepath.Join(tmp, "data"),
+		Cache:  filepath.Join(tmp, "cache"),
+		State:  filepath.Join(tmp, "state"),
+	}
+	store := filepath.Join(from.Data, "store", "foo", "v1.0.0")
+	require.NoError(t, os.MkdirAll(store, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(store, "foo"), []byte("#!/bin/sh"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(from.Data, "bin"), 0755))
+	require.NoError(t, os.Symlink(filepath.Join(store, "foo"), filepath.Join(from.Data, "bin", "devctl-foo")))
+	require.NoError(t, os.MkdirAll(from.Cache, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(from.Data, "config.yaml"), []byte("vars: {}\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(from.Data, "notes.txt"), nil, 0644))
+
+	m, err := PlanMigration(from, to)
+	require.NoError(t, err)
+	assert.Equal(t, []Move{
+		{From: filepath.Join(from.Config, "config.yaml"), To: filepath.Join(to.Config, "config.yaml")},
+		{From: filepath.Join(from.Data, "store"), To: filepath.Join(to.Data, "store")},
+		{From: filepath.Join(from.Data, "bin"), To: filepath.Join(to.Data, "bin")},
+		{From: from.Cache, To: to.Cache},
+	}, m.Moves)
+	assert.Equal(t, []string{filepath.Join(from.Data, "notes.txt")}, m.Unknown)
+
+	require.NoError(t, m.Execute())
+	assert.FileExists(t, filepath.Join(to.Config, "config.yaml"))
+	assert.DirExists(t, to.Cache)
+	target, err := os.Readlink(filepath.Join(to.Data, "bin", "devctl-foo"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(to.Data, "store", "foo", "v1.0.0", "foo"), target)
+
+	_, err = PlanMigration(to, to)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(from.Data, "bin"), 0755))
+	_, err = PlanMigration(from, to)
+	assert.Error(t, err)
+}
@@ -1,24 +1,26 @@
 package env
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"sync"
 
 	"github.com/alex-held/devctl-kit/pkg/devctlpath"
-	"github.com/alex-held/devctl-kit/pkg/log"
 	"github.com/alex-held/devctl-kit/pkg/system"
 	"github.com/spf13/afero"
 
 	"github.com/alex-held/devctl/pkg/cli/options"
 	"github.com/alex-held/devctl/pkg/cli/util"
+	"github.com/alex-held/devctl/pkg/config"
+	"github.com/alex-held/devctl/pkg/logging"
 	"github.com/alex-held/devctl/pkg/validation"
 )
 
 type factory struct {
 	runtimeInfoGetter system.RuntimeInfoGetter
 	pather            devctlpath.Pather
-	logger            log.Logger
+	logger            logging.Log
 
 	// Caches OpenAPI document and parsed resources
 	//	openAPIParser *openapi.CachedOpenAPIParser
@@ -28,6 +30,11 @@ type factory struct {
 	streams options.IOStreams
 	fs      afero.Fs
 	paths   Paths
+
+	configPath string
+	configOnce sync.Once
+	config     *config.File
+	configErr  error
 }
 
 func (f *factory) RuntimeInfo() system.RuntimeInfo {
@@ -42,7 +49,7 @@ func (f *factory) Fs() afero.Fs {
 	return f.fs
 }
 
-func (f *factory) Logger() log.Logger {
+func (f *factory) Logger() logging.Log {
 	return f.logger
 }
 
@@ -57,6 +64,49 @@ func (f *factory) Streams() options.IOStreams {
 	return f.streams
 }
 
+// Config loads the configuration file once, templating the DEVCTL_PATH_* vars into it
+func (f *factory) Config() (*config.File, error) {
+	f.configOnce.Do(func() {
+		f.config, f.configErr = config.Load(f.fs, f.configFilePath(), BuiltinVars(f.pather))
+	})
+	return f.config, f.configErr
+}
+
+// RawConfig loads the configuration file without validating it
+func (f *factory) RawConfig() (*config.File, error) {
+	return config.LoadRaw(f.fs, f.configFilePath(), BuiltinVars(f.pather))
+}
+
+func (f *factory) configFilePath() string {
+	if f.configPath != "" {
+		return f.configPath
+	}
+	return f.pather.ConfigFilePath()
+}
+
+// BuiltinVars returns the vars available for templating the configuration file
+func BuiltinVars(pather devctlpath.Pather) map[string]string {
+	return map[string]string{
+		"DEVCTL_PATH_ROOT":     pather.ConfigRoot(),
+		"DEVCTL_PATH_CONFIG":   pather.Config(),
+		"DEVCTL_PATH_BIN":      pather.Bin(),
+		"DEVCTL_PATH_SDK":      pather.SDK(),
+		"DEVCTL_PATH_PLUGIN":   pather.Plugin(),
+		"DEVCTL_PATH_CACHE":    pather.Cache(),
+		"DEVCTL_PATH_DOWNLOAD": pather.Download(),
+	}
+}
+
+// PathVars returns the BuiltinVars together with the home directory and $DEVCTL_ROOT.
+// They are available to the dynamic plugin config, the fs permissions of plugins and the dotfile templates.
+func PathVars(pather devctlpath.Pather, home string) map[string]string {
+	vars := BuiltinVars(pather)
+	vars["HOME"] = home
+	vars["DEVCTL_ROOT"] = pather.ConfigRoot()
+	vars["DEVCTL_PATH_USERHOME"] = home
+	return vars
+}
+
 func (f *factory) Validator(validate bool) (validation.Schema, error) {
 	return validation.NullSchema{}, nil
 }
@@ -70,7 +120,8 @@ type Factory interface {
 	// and which implements the common patterns for CLI interactions with generic resources.
 	NewBuilder() *util.Builder
 
-	Logger() log.Logger
+	// Logger is the logging facade, which is configured by the -v, --log-format and --log-file flags
+	Logger() logging.Log
 
 	Pather() devctlpath.Pather
 
@@ -80,6 +131,13 @@ type Factory interface {
 
 	Streams() options.IOStreams
 
+	// Config returns the central configuration file, usually ~/.devctl/config.yaml
+	Config() (*config.File, error)
+
+	// RawConfig returns the configuration file without validating it, so that it can be repaired.
+	// Changes are validated when they are applied using Set, Unset or Replace.
+	RawConfig() (*config.File, error)
+
 	// Returns a schema that can validate objects stored on disk.
 	Validator(validate bool) (validation.Schema, error)
 
@@ -90,12 +148,17 @@ type Factory interface {
 }
 
 type FactoryConfig struct {
-	Pather            devctlpath.Pather
-	Paths             Paths
-	LoggerConfig      *log.Config
+	Pather devctlpath.Pather
+	Paths  Paths
+	// Logger defaults to a Logger writing warnings and errors to the error stream
+	Logger            logging.Log
 	Streams           *options.IOStreams
 	RuntimeInfoGetter system.RuntimeInfoGetter
 	Fs                afero.Fs
+	// ConfigPath overrides the path of the configuration file
+	ConfigPath string
+	// DevctlPath is the devctl root directory, usually set by the --devctl-path flag, see ResolveDirs
+	DevctlPath string
 }
 
 type FactoryOption func(*FactoryConfig) *FactoryConfig
@@ -111,13 +174,33 @@ func WithIO(in io.Reader, out, err io.Writer) FactoryOption {
 	}
 }
 
+// WithPaths configures the paths and the pather of the factory to use the devctl root directory base
+func WithPaths(base string) FactoryOption {
+	return WithDirs(RootDirs(base))
+}
+
+// WithDirs configures the paths and the pather of the factory to use dirs
+func WithDirs(dirs Dirs) FactoryOption {
+	return func(c *FactoryConfig) *FactoryConfig {
+		c.Paths = NewPathsFromDirs(dirs)
+		c.Pather = NewPather(dirs)
+		return c
+	}
+}
+
+// WithDevctlPath configures the devctl root directory, which takes precedence over the environment
+// and the configuration. An empty root is ignored.
+func WithDevctlPath(root string) FactoryOption {
+	return func(c *FactoryConfig) *FactoryConfig {
+		c.DevctlPath = root
+		return c
+	}
+}
+
 func NewFactory(opts ...FactoryOption) Factory {
 	cfg := &FactoryConfig{
-		Pather:            devctlpath.DefaultPather(),
-		LoggerConfig:      &log.DefaultConfig,
 		Fs:                afero.NewOsFs(),
 		RuntimeInfoGetter: system.OSRuntimeInfoGetter{},
-		Paths:             MustGetPaths(),
 	}
 	defaults := []FactoryOption{
 		WithIO(os.Stdin, os.Stdout, os.Stdout),
@@ -127,13 +210,34 @@ func NewFactory(opts ...FactoryOption) Factory {
 		opt(cfg)
 	}
 
+	// Paths and Pather share the same directories, unless configured explicitly
+	if cfg.Pather == nil || cfg.Paths == (Paths{}) {
+		dirs, err := ResolveDirs(cfg.Fs, cfg.DevctlPath)
+		if err != nil {
+			home, _ := os.UserHomeDir()
+			dirs = RootDirs(DefaultRoot(home))
+			fmt.Fprintf(cfg.Streams.ErrOut, "Warning: %v, using %s\n", err, dirs.Data)
+		}
+		if cfg.Pather == nil {
+			cfg.Pather = NewPather(dirs)
+		}
+		if cfg.Paths == (Paths{}) {
+			cfg.Paths = NewPathsFromDirs(dirs)
+		}
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = logging.NewLogger(logging.WithOutputs(cfg.Streams.ErrOut), logging.WithLevel(logging.LogLevelWarn))
+	}
+
 	return &factory{
 		runtimeInfoGetter: cfg.RuntimeInfoGetter,
 		pather:            cfg.Pather,
 		paths:             cfg.Paths,
-		logger:            log.New(cfg.LoggerConfig),
+		logger:            cfg.Logger,
 		getter:            sync.Once{},
 		streams:           *cfg.Streams,
 		fs:                cfg.Fs,
+		configPath:        cfg.ConfigPath,
 	}
 }
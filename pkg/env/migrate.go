@@ -0,0 +1,147 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// layoutEntries are the files and directories devctl keeps in its Dirs
+var layoutEntries = []struct {
+	name string
+	dir  func(Dirs) string
+}{
+	{"config.yaml", func(d Dirs) string { return d.Config }},
+	{"config", func(d Dirs) string { return d.Config }},
+	{"index", func(d Dirs) string { return d.Data }},
+	{"receipts", func(d Dirs) string { return d.Data }},
+	{"store", func(d Dirs) string { return d.Data }},
+	{"bin", func(d Dirs) string { return d.Data }},
+	{"sdks", func(d Dirs) string { return d.Data }},
+	{"plugins", func(d Dirs) string { return d.Data }},
+	{"downloads", func(d Dirs) string { return d.Data }},
+	{"dotfiles", func(d Dirs) string { return d.Data }},
+	{"update-check", func(d Dirs) string { return d.State }},
+	{"history.log", func(d Dirs) string { return d.State }},
+	{"dotfiles.yaml", func(d Dirs) string { return d.State }},
+	{"dotfiles-backup", func(d Dirs) string { return d.State }},
+}
+
+// Move moves the file or directory From to To
+type Move struct {
+	From string
+	To   string
+}
+
+// Migration moves the devctl tree from one Dirs to another, e.g. from the root to the XDG layout
+type Migration struct {
+	From  Dirs
+	To    Dirs
+	Moves []Move
+	// Unknown are the files in the directories of From, which are not known to devctl and therefore stay where they are
+	Unknown []string
+}
+
+// PlanMigration plans the moves from one Dirs to another. It fails, if any of the targets exists already.
+func PlanMigration(from, to Dirs) (*Migration, error) {
+	m := &Migration{From: from, To: to}
+	known := map[string]bool{from.Cache: true}
+	for _, e := range layoutEntries {
+		src, dst := filepath.Join(e.dir(from), e.name), filepath.Join(e.dir(to), e.name)
+		if e.name == "config.yaml" {
+			src, dst = NewPather(from).ConfigFilePath(), NewPather(to).ConfigFilePath()
+		}
+		known[src] = true
+		if err := m.add(src, dst); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.add(from.Cache, to.Cache); err != nil {
+		return nil, err
+	}
+
+	for _, dir := range []string{from.Config, from.Data, from.State} {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "failed to read %s", dir)
+		}
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			if !known[path] {
+				known[path] = true
+				m.Unknown = append(m.Unknown, path)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *Migration) add(src, dst string) error {
+	if src == dst {
+		return nil
+	}
+	if _, err := os.Lstat(src); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return errors.Errorf("can't move %s, because %s exists already", src, dst)
+	}
+	m.Moves = append(m.Moves, Move{From: src, To: dst})
+	return nil
+}
+
+// Execute performs the moves and updates the symbolic links pointing into moved directories,
+// e.g. the links in the bin directory pointing to the installed plugins in the store.
+func (m *Migration) Execute() error {
+	for _, mv := range m.Moves {
+		if err := os.MkdirAll(filepath.Dir(mv.To), 0755); err != nil {
+			return errors.Wrapf(err, "failed to create the parent directory of %s", mv.To)
+		}
+		if err := os.Rename(mv.From, mv.To); err != nil {
+			return errors.Wrapf(err, "failed to move %s to %s", mv.From, mv.To)
+		}
+	}
+	for _, mv := range m.Moves {
+		if err := filepath.Walk(mv.To, m.relink); err != nil {
+			return err
+		}
+	}
+	// the directories of From are left behind, unless they are empty
+	for _, dir := range []string{m.From.Config, m.From.Data, m.From.State} {
+		_ = os.Remove(dir)
+	}
+	return nil
+}
+
+func (m *Migration) relink(path string, fi os.FileInfo, err error) error {
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return err
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the symbolic link %s", path)
+	}
+	moved, ok := m.Moved(target)
+	if !ok {
+		return nil
+	}
+	if err := os.Remove(path); err != nil {
+		return errors.Wrapf(err, "failed to remove the symbolic link %s", path)
+	}
+	return errors.Wrapf(os.Symlink(moved, path), "failed to link %s to %s", path, moved)
+}
+
+// Moved returns the new location of path, if path is moved by the migration
+func (m *Migration) Moved(path string) (string, bool) {
+	for _, mv := range m.Moves {
+		if path == mv.From {
+			return mv.To, true
+		}
+		if strings.HasPrefix(path, mv.From+string(filepath.Separator)) {
+			return filepath.Join(mv.To, strings.TrimPrefix(path, mv.From)), true
+		}
+	}
+	return "", false
+}
@@ -4,8 +4,8 @@ import (
 	"os"
 	"path/filepath"
 
-	"github.com/alex-held/devctl-kit/pkg/log"
 	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
 
 	"github.com/alex-held/devctl/pkg/constants"
 )
@@ -13,36 +13,47 @@ import (
 type Paths struct {
 	base string
 	tmp  string
+	dirs Dirs
 }
 
 // MustGetPaths returns the inferred paths for devctl. By default, it assumes
 // $HOME/.devctl as the base path, but can be overridden via DEVCTL_ROOT environment
-// variable.
+// variable, the DEVCTL_LAYOUT environment variable or the configuration, see ResolveDirs.
 func MustGetPaths() Paths {
-	homeDir, err := os.UserHomeDir()
+	dirs, err := ResolveDirs(fs, "")
 	if err != nil {
-		panic(errors.Wrap(err, "cannot get user home dir"))
+		panic(err)
 	}
-	base := filepath.Join(homeDir, constants.DefaultDevctlDir)
 	if fromEnv := os.Getenv(constants.DEVCTL_ROOT_KEY); fromEnv != "" {
-		base = fromEnv
-		log.Infof("using environment override %s=%s", constants.DEVCTL_ROOT_KEY, fromEnv)
+		klog.V(1).Infof("using environment override %s=%s", constants.DEVCTL_ROOT_KEY, fromEnv)
 	}
-
-	base, err = filepath.Abs(base)
-	if err != nil {
-		panic(errors.Wrap(err, "cannot get absolute path"))
-	}
-	return NewPaths(base)
+	return NewPathsFromDirs(dirs)
 }
 
+// NewPaths returns the paths of the root layout below base
 func NewPaths(base string) Paths {
+	return NewPathsFromDirs(RootDirs(base))
+}
+
+// NewPathsFromDirs returns the paths below dirs, where the data directory is the base directory
+func NewPathsFromDirs(dirs Dirs) Paths {
 	return Paths{
-		base: base,
+		base: dirs.Data,
 		tmp:  os.TempDir(),
+		dirs: dirs,
 	}
 }
 
+// Dirs returns the directories the paths are resolved in
+func (p Paths) Dirs() Dirs { return p.dirs }
+
+// StatePath returns a path below the state directory, e.g. for logs and histories.
+//
+// e.g. {StateDir}/{elem}
+func (p Paths) StatePath(elem ...string) string {
+	return filepath.Join(p.dirs.State, filepath.Join(elem...))
+}
+
 // Base returns the devctl base directory
 func (p Paths) Base() string { return p.base }
 
@@ -113,6 +124,11 @@ func (p Paths) PluginVersionInstallPath(plugin, version string) string {
 	return filepath.Join(p.InstallPath(), plugin, version)
 }
 
+// DotfilesPath returns the directory where the dotfiles repository is cloned.
+//
+// e.g. {BasePath}/dotfiles
+func (p Paths) DotfilesPath() string { return filepath.Join(p.base, "dotfiles") }
+
 // Realpath evaluates symbolic links. If the path is not a symbolic link, it
 // returns the cleaned path. Symbolic links with relative paths return error.
 func Realpath(path string) (string, error) {
@@ -2,7 +2,17 @@ package errors
 
 import (
 	"errors"
+	"fmt"
 )
 
 var ErrExit = errors.New("devctl has exited unsuccessfully")
 var ErrWindowsNotSupported = errors.New("error: windows is not supported")
+
+// ExitError is returned, if a plugin exits with a non-zero exit code, which devctl should exit with as well
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
@@ -0,0 +1,243 @@
+// Package history records the state-changing operations of devctl in an audit log.
+//
+// Every install, upgrade and uninstall of a plugin, every change of an index and every switch of an sdk version
+// is appended as a JSON line to the history.log in the state directory, usually ~/.devctl/history.log.
+package history
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/alex-held/devctl/internal/git"
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// Action is the kind of operation recorded by an Entry
+type Action string
+
+const (
+	ActionInstall     Action = "install"
+	ActionUpgrade     Action = "upgrade"
+	ActionUninstall   Action = "uninstall"
+	ActionIndexAdd    Action = "index-add"
+	ActionIndexRemove Action = "index-remove"
+	ActionIndexUpdate Action = "index-update"
+	ActionSDKUse      Action = "sdk-use"
+	// ActionUndo links the version a plugin had before the entry Undoes
+	ActionUndo Action = "undo"
+)
+
+// Results of an Entry
+const (
+	ResultOK     = "ok"
+	ResultFailed = "failed"
+)
+
+// Entry is a single recorded operation
+type Entry struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Command string    `json:"command"`
+	Action  Action    `json:"action"`
+
+	Plugin string `json:"plugin,omitempty"`
+	Index  string `json:"index,omitempty"`
+	SDK    string `json:"sdk,omitempty"`
+	// URL is the remote of an added index
+	URL string `json:"url,omitempty"`
+	// Before and After are the versions of a plugin or sdk, or the commits of an updated index
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	// IndexCommit is the commit of the index a plugin has been installed from
+	IndexCommit string `json:"indexCommit,omitempty"`
+	// Undoes is the ID of the entry reverted by an ActionUndo
+	Undoes string `json:"undoes,omitempty"`
+
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Target returns the plugin, sdk or index the entry is about
+func (e Entry) Target() string {
+	switch {
+	case e.Plugin != "":
+		return e.Plugin
+	case e.SDK != "":
+		return e.SDK
+	default:
+		return e.Index
+	}
+}
+
+// ChangesVersion returns true, if the entry successfully changed the installed version of a plugin
+func (e Entry) ChangesVersion() bool {
+	if e.Plugin == "" || e.Result != ResultOK {
+		return false
+	}
+	switch e.Action {
+	case ActionInstall, ActionUpgrade, ActionUninstall, ActionUndo:
+		return true
+	}
+	return false
+}
+
+// Path returns the history log of paths
+func Path(paths env.Paths) string {
+	return paths.StatePath("history.log")
+}
+
+// Record appends e with the result err to the history log. The ID, time, user and command line are set, if they are empty.
+// Failing to record an entry doesn't fail the operation, it is reported as a warning.
+func Record(f env.Factory, e Entry, err error) {
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.User == "" {
+		e.User = currentUser()
+	}
+	if e.Command == "" {
+		e.Command = strings.Join(os.Args, " ")
+	}
+	e.Result = ResultOK
+	if err != nil {
+		e.Result, e.Error = ResultFailed, err.Error()
+	}
+
+	if err := appendEntry(Path(f.Paths()), e); err != nil {
+		f.Logger().Warnf("failed to record %s in the history: %v", e.Action, err)
+	}
+}
+
+func appendEntry(path string, e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	// a single write per line keeps concurrent devctl processes from interleaving entries
+	if _, err = file.Write(append(b, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load reads all entries of the history log at path in the order they have been recorded
+func Load(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the history")
+	}
+	defer file.Close()
+
+	var entries []Entry
+	s := bufio.NewScanner(file)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; s.Scan(); line++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, errors.Wrapf(err, "malformed history entry in line %d of %s", line, path)
+		}
+		entries = append(entries, e)
+	}
+	return entries, errors.Wrap(s.Err(), "failed to read the history")
+}
+
+// Query selects entries of the history
+type Query struct {
+	// Plugin selects the entries of a plugin
+	Plugin string
+	// Since selects the entries recorded at or after the time
+	Since time.Time
+}
+
+// Filter returns the entries matching q
+func (q Query) Filter(entries []Entry) []Entry {
+	var matches []Entry
+	for _, e := range entries {
+		if q.Plugin != "" && e.Plugin != q.Plugin {
+			continue
+		}
+		if !q.Since.IsZero() && e.Time.Before(q.Since) {
+			continue
+		}
+		matches = append(matches, e)
+	}
+	return matches
+}
+
+// Find returns the entry with the id
+func Find(entries []Entry, id string) (Entry, bool) {
+	for _, e := range entries {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// LastVersionChange returns the last entry, which changed the installed version of plugin
+func LastVersionChange(entries []Entry, plugin string) (Entry, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Plugin == plugin && entries[i].ChangesVersion() {
+			return entries[i], true
+		}
+	}
+	return Entry{}, false
+}
+
+// IndexCommit returns the commit the index is checked out at, or an empty string
+func IndexCommit(paths env.Paths, index string) string {
+	if index == "" {
+		return ""
+	}
+	dir := paths.IndexPath(index)
+	if _, err := os.Stat(dir); err != nil {
+		return ""
+	}
+	commit, err := git.Exec(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(commit)
+}
+
+func newID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("150405.000")
+	}
+	return hex.EncodeToString(b)
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,75 @@
+package history
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+func TestRecord(t *testing.T) {
+	root, err := ioutil.TempDir("", "devctl-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	f := env.NewFactory(env.WithPaths(root))
+
+	entries, err := Load(Path(f.Paths()))
+	require.NoError(t, err)
+	assert.Empty(t, entries, "a missing history is empty")
+
+	Record(f, Entry{Action: ActionInstall, Plugin: "sdkman", Index: "default", After: "v1.0.0"}, nil)
+	Record(f, Entry{Action: ActionUpgrade, Plugin: "sdkman", Index: "default", Before: "v1.0.0", After: "v1.1.0"}, errors.New("checksum mismatch"))
+	Record(f, Entry{Action: ActionSDKUse, SDK: "go", Before: "1.16.4", After: "1.16.5"}, nil)
+
+	entries, err = Load(Path(f.Paths()))
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	install := entries[0]
+	assert.Len(t, install.ID, 8)
+	assert.NotEqual(t, install.ID, entries[1].ID)
+	assert.WithinDuration(t, time.Now(), install.Time, time.Minute)
+	assert.NotEmpty(t, install.Command)
+	assert.Equal(t, ResultOK, install.Result)
+	assert.Equal(t, "sdkman", install.Target())
+
+	assert.Equal(t, ResultFailed, entries[1].Result)
+	assert.Equal(t, "checksum mismatch", entries[1].Error)
+	assert.Equal(t, "go", entries[2].Target())
+
+	last, ok := LastVersionChange(entries, "sdkman")
+	require.True(t, ok, "failed upgrades don't change the version")
+	assert.Equal(t, install.ID, last.ID)
+	_, ok = LastVersionChange(entries, "go")
+	assert.False(t, ok)
+
+	found, ok := Find(entries, entries[2].ID)
+	require.True(t, ok)
+	assert.Equal(t, ActionSDKUse, found.Action)
+}
+
+func TestQuery_Filter(t *testing.T) {
+	now := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{ID: "1", Time: now.Add(-48 * time.Hour), Plugin: "sdkman"},
+		{ID: "2", Time: now.Add(-time.Hour), Plugin: "sdkman"},
+		{ID: "3", Time: now, Index: "default"},
+	}
+
+	ids := func(entries []Entry) (ids []string) {
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, ids(Query{}.Filter(entries)))
+	assert.Equal(t, []string{"1", "2"}, ids(Query{Plugin: "sdkman"}.Filter(entries)))
+	assert.Equal(t, []string{"2", "3"}, ids(Query{Since: now.Add(-24 * time.Hour)}.Filter(entries)))
+	assert.Equal(t, []string{"2"}, ids(Query{Plugin: "sdkman", Since: now.Add(-24 * time.Hour)}.Filter(entries)))
+}
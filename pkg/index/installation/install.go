@@ -15,9 +15,12 @@ import (
 
 	"github.com/alex-held/devctl/pkg/constants"
 	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/history"
 	"github.com/alex-held/devctl/pkg/index/download"
+	"github.com/alex-held/devctl/pkg/index/installation/semver"
 	"github.com/alex-held/devctl/pkg/index/pathutil"
 	"github.com/alex-held/devctl/pkg/index/spec"
+	"github.com/alex-held/devctl/pkg/logging"
 )
 
 // InstallOpts specifies options for plugin installation operation.
@@ -31,6 +34,7 @@ type installOperation struct {
 
 	installDir string
 	binDir     string
+	pluginDir  string
 }
 
 // Plugin lifecycle errors
@@ -42,14 +46,24 @@ var (
 
 // Install will download and install a plugin. The operation tries
 // to not get the plugin dir in a bad state if it fails during the process.
-func Install(p env.Factory, plugin spec.Plugin, indexName string, opts InstallOpts) error {
-	log.Infof("Looking for installed versions")
-	_, err := Load(p.Fs(), p.Paths().PluginInstallReceiptPath(plugin.Name))
+func Install(p env.Factory, plugin spec.Plugin, indexName string, opts InstallOpts) (err error) {
+	logger := p.Logger().WithFields(logging.Fields{"plugin": plugin.Name, "index": indexName, "version": plugin.Spec.Version})
+	logger.Info("Looking for installed versions")
+	_, err = Load(p.Fs(), p.Paths().PluginInstallReceiptPath(plugin.Name))
 	if err == nil {
 		return ErrIsAlreadyInstalled
 	} else if !os.IsNotExist(err) {
 		return errors.Wrap(err, "failed to look up plugin receipt")
 	}
+	defer func() {
+		history.Record(p, history.Entry{
+			Action:      history.ActionInstall,
+			Plugin:      plugin.Name,
+			Index:       indexName,
+			After:       plugin.Spec.Version,
+			IndexCommit: history.IndexCommit(p.Paths(), indexName),
+		}, err)
+	}()
 
 	// Find available installation candidate
 	candidate, ok, err := GetMatchingPlatform(plugin.Spec.Platforms)
@@ -62,18 +76,19 @@ func Install(p env.Factory, plugin spec.Plugin, indexName string, opts InstallOp
 
 	// The actual install should be the last action so that a failure during receipt
 	// saving does not result in an installed plugin without receipt.
-	log.Infof("Install plugin %s at version=%s", plugin.Name, plugin.Spec.Version)
+	logger.Info("Install plugin")
 	if err := install(p.Fs(), installOperation{
 		pluginName: plugin.Name,
 		platform:   candidate,
 
 		binDir:     p.Paths().BinPath(),
+		pluginDir:  p.Pather().Plugin(),
 		installDir: p.Paths().PluginVersionInstallPath(plugin.Name, plugin.Spec.Version),
 	}, opts); err != nil {
 		return errors.Wrap(err, "install failed")
 	}
 
-	log.Infof("Storing install receipt for plugin %s", plugin.Name)
+	logger.Info("Storing install receipt")
 	err = Store(p.Fs(), New(plugin, indexName, metav1.Now()), p.Paths().PluginInstallReceiptPath(plugin.Name))
 	return errors.Wrap(err, "installation receipt could not be stored, uninstall may fail")
 }
@@ -105,7 +120,11 @@ func install(fs afero.Fs, op installOperation, opts InstallOpts) error {
 	if err != nil {
 		return errors.Wrapf(err, "failed to get the absolute fullPath of %q", op.installDir)
 	}
-	fullPath := filepath.Join(op.installDir, filepath.FromSlash(op.platform.Bin))
+	target := op.platform.Bin
+	if op.platform.IsInterpreted() {
+		target = op.platform.Source
+	}
+	fullPath := filepath.Join(op.installDir, filepath.FromSlash(target))
 	pathAbs, err := filepath.Abs(fullPath)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get the absolute fullPath of %q", fullPath)
@@ -113,6 +132,15 @@ func install(fs afero.Fs, op installOperation, opts InstallOpts) error {
 	if _, ok := pathutil.IsSubPath(subPathAbs, pathAbs); !ok {
 		return errors.Wrapf(err, "the fullPath %q does not extend the sub-fullPath %q", fullPath, op.installDir)
 	}
+
+	if op.platform.IsInterpreted() {
+		err = createOrUpdatePackageLink(fs, op.pluginDir, pathAbs, op.pluginName)
+		return errors.Wrap(err, "failed to link installed plugin package")
+	}
+	if op.pluginName == constants.DevctlPluginName {
+		err = replaceLink(fs, filepath.Join(op.binDir, selfBin(IsWindows())), fullPath)
+		return errors.Wrap(err, "failed to link installed devctl")
+	}
 	err = createOrUpdateLink(fs, op.binDir, fullPath, op.pluginName)
 	return errors.Wrap(err, "failed to link installed plugin")
 }
@@ -138,48 +166,202 @@ func downloadAndExtract(fs afero.Fs, extractDir, uri, sha256sum, overrideFile st
 	return errors.Wrap(err, "failed to unpack the plugin archive")
 }
 
+// Upgrade will reinstall and upgrade a plugin. The operation tries
+// to not get the plugin dir in a bad state if it fails during the process.
+func Upgrade(p env.Factory, plugin spec.Plugin, indexName string, opts InstallOpts) (err error) {
+	logger := p.Logger().WithFields(logging.Fields{"plugin": plugin.Name, "index": indexName, "version": plugin.Spec.Version})
+	installReceipt, err := Load(p.Fs(), p.Paths().PluginInstallReceiptPath(plugin.Name))
+	if err != nil {
+		return errors.Wrapf(err, "failed to load install receipt for plugin %q", plugin.Name)
+	}
+
+	curVersion := installReceipt.Spec.Version
+	curv, err := semver.Parse(curVersion)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse installed plugin version (%q) as a semver value", curVersion)
+	}
+
+	// Find available installation candidate
+	candidate, ok, err := GetMatchingPlatform(plugin.Spec.Platforms)
+	if err != nil {
+		return errors.Wrap(err, "failed trying to find a matching platform in plugin spec")
+	}
+	if !ok {
+		return errors.Errorf("plugin %q does not offer installation for this platform (%s)", plugin.Name, OSArch())
+	}
+
+	newVersion := plugin.Spec.Version
+	newv, err := semver.Parse(newVersion)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse candidate version spec (%q)", newVersion)
+	}
+	logger.Infof("Comparing versions: current=%s target=%s", curv, newv)
+
+	// See if it's a newer version
+	if !semver.Less(curv, newv) {
+		logger.Infof("Plugin does not need upgrade (%s ≥ %s)", curv, newv)
+		return ErrIsAlreadyUpgraded
+	}
+	logger.Infof("Plugin needs upgrade (%s < %s)", curv, newv)
+	defer func() {
+		history.Record(p, history.Entry{
+			Action:      history.ActionUpgrade,
+			Plugin:      plugin.Name,
+			Index:       indexName,
+			Before:      curVersion,
+			After:       newVersion,
+			IndexCommit: history.IndexCommit(p.Paths(), indexName),
+		}, err)
+	}()
+
+	logger.Infof("Upgrade plugin from version=%s", curVersion)
+	if err := install(p.Fs(), installOperation{
+		pluginName: plugin.Name,
+		platform:   candidate,
+
+		binDir:     p.Paths().BinPath(),
+		pluginDir:  p.Pather().Plugin(),
+		installDir: p.Paths().PluginVersionInstallPath(plugin.Name, newVersion),
+	}, opts); err != nil {
+		return errors.Wrap(err, "failed to install new version")
+	}
+
+	logger.Info("Upgrading install receipt")
+	if err = Store(p.Fs(), New(plugin, indexName, installReceipt.CreationTimestamp), p.Paths().PluginInstallReceiptPath(plugin.Name)); err != nil {
+		return errors.Wrap(err, "installation receipt could not be stored, uninstall may fail")
+	}
+
+	return cleanupInstallation(p, plugin, curVersion)
+}
+
+// cleanupInstallation removes the stale versions of an upgraded plugin.
+// The version the plugin has been upgraded from is kept in the store, so that the upgrade can be undone using Rollback.
+func cleanupInstallation(p env.Factory, plugin spec.Plugin, oldVersion string) error {
+	if plugin.Name == constants.DevctlPluginName && IsWindows() {
+		// the running executable can't be removed, it is cleaned up by the next self-update
+		p.Logger().WithField("plugin", plugin.Name).Info("Not removing old versions of devctl during upgrade on windows")
+		return nil
+	}
+	return CleanupStaleKrewInstallations(p.Fs(), p.Paths().PluginInstallPath(plugin.Name), plugin.Spec.Version, oldVersion)
+}
+
 // Uninstall will uninstall a plugin.
-func Uninstall(p env.Factory, name string) error {
+func Uninstall(p env.Factory, name string) (err error) {
+	logger := p.Logger().WithField("plugin", name)
 	if name == constants.DevctlPluginName {
-		log.Errorf("Removing krew through krew is not supported.")
+		logger.Errorf("Removing krew through krew is not supported.")
 		if !IsWindows() { // assume POSIX-like
-			log.Errorf("If you’d like to uninstall krew altogether, run:\n\trm -rf -- %q", p.Paths().Base())
+			logger.Errorf("If you’d like to uninstall krew altogether, run:\n\trm -rf -- %q", p.Paths().Base())
 		}
 		return errors.New("self-uninstall not allowed")
 	}
-	log.Infof("Finding installed version to delete")
+	logger.Info("Finding installed version to delete")
 
-	if _, err := Load(p.Fs(), p.Paths().PluginInstallReceiptPath(name)); err != nil {
+	receipt, err := Load(p.Fs(), p.Paths().PluginInstallReceiptPath(name))
+	if err != nil {
 		if os.IsNotExist(err) {
 			return ErrIsNotInstalled
 		}
 		return errors.Wrapf(err, "failed to look up install receipt for plugin %q", name)
 	}
+	defer func() {
+		history.Record(p, history.Entry{
+			Action: history.ActionUninstall,
+			Plugin: name,
+			Index:  receipt.Status.Source.Name,
+			Before: receipt.Spec.Version,
+		}, err)
+	}()
 
-	log.Infof("Deleting plugin %s", name)
+	logger.Info("Deleting plugin")
 
-	symlinkPath := filepath.Join(p.Paths().BinPath(), pluginNameToBin(name, IsWindows()))
-	log.Infof("Unlink %q", symlinkPath)
-	if err := removeLink(symlinkPath); err != nil {
+	platform, _, err := GetMatchingPlatform(receipt.Spec.Platforms)
+	if err != nil {
+		return errors.Wrap(err, "failed trying to find a matching platform in plugin spec")
+	}
+	linkPath := LinkPath(p, name, platform)
+	logger.Infof("Unlink %q", linkPath)
+	if err := removeLink(p.Fs(), linkPath); err != nil {
 		return errors.Wrap(err, "could not uninstall symlink of plugin")
 	}
 
 	pluginInstallPath := p.Paths().PluginInstallPath(name)
-	log.Infof("Deleting path %q", pluginInstallPath)
-	if err := os.RemoveAll(pluginInstallPath); err != nil {
+	logger.Infof("Deleting path %q", pluginInstallPath)
+	if err := p.Fs().RemoveAll(pluginInstallPath); err != nil {
 		return errors.Wrapf(err, "could not remove plugin directory %q", pluginInstallPath)
 	}
 	pluginReceiptPath := p.Paths().PluginInstallReceiptPath(name)
-	log.Infof("Deleting plugin receipt %q", pluginReceiptPath)
-	err := os.Remove(pluginReceiptPath)
+	logger.Infof("Deleting plugin receipt %q", pluginReceiptPath)
+	err = p.Fs().Remove(pluginReceiptPath)
 	return errors.Wrapf(err, "could not remove plugin receipt %q", pluginReceiptPath)
 }
 
+// Rollback links the version of an installed plugin, that is still in the store, and updates its receipt.
+// It is used to undo an upgrade.
+func Rollback(p env.Factory, name, version string) error {
+	receipt, err := Load(p.Fs(), p.Paths().PluginInstallReceiptPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrIsNotInstalled
+		}
+		return errors.Wrapf(err, "failed to look up install receipt for plugin %q", name)
+	}
+
+	installDir := p.Paths().PluginVersionInstallPath(name, version)
+	if _, err := p.Fs().Stat(installDir); err != nil {
+		if os.IsNotExist(err) {
+			return errors.Errorf("version %s of plugin %q is no longer in the store", version, name)
+		}
+		return errors.Wrapf(err, "failed to look up version %s of plugin %q", version, name)
+	}
+
+	platform, ok, err := GetMatchingPlatform(receipt.Spec.Platforms)
+	if err != nil {
+		return errors.Wrap(err, "failed trying to find a matching platform in plugin spec")
+	}
+	if !ok {
+		return errors.Errorf("plugin %q does not offer installation for this platform (%s)", name, OSArch())
+	}
+
+	p.Logger().WithFields(logging.Fields{"plugin": name, "version": version}).Infof("Roll back plugin from version=%s", receipt.Spec.Version)
+	target := platform.Bin
+	if platform.IsInterpreted() {
+		target = platform.Source
+	}
+	fullPath := filepath.Join(installDir, filepath.FromSlash(target))
+	switch {
+	case platform.IsInterpreted():
+		err = errors.Wrap(createOrUpdatePackageLink(p.Fs(), p.Pather().Plugin(), fullPath, name), "failed to link plugin package")
+	case name == constants.DevctlPluginName:
+		err = errors.Wrap(replaceLink(p.Fs(), LinkPath(p, name, platform), fullPath), "failed to link devctl")
+	default:
+		err = errors.Wrap(createOrUpdateLink(p.Fs(), p.Paths().BinPath(), fullPath, name), "failed to link plugin")
+	}
+	if err != nil {
+		return err
+	}
+
+	receipt.Spec.Version = version
+	err = Store(p.Fs(), receipt, p.Paths().PluginInstallReceiptPath(name))
+	return errors.Wrap(err, "installation receipt could not be stored")
+}
+
+// LinkPath returns the path, at which a plugin installed using platform gets linked.
+// Binaries are linked into the bin directory, interpreted plugins into the plugin directory.
+func LinkPath(p env.Factory, name string, platform spec.Platform) string {
+	if platform.IsInterpreted() {
+		return p.Pather().Plugin(name)
+	}
+	if name == constants.DevctlPluginName {
+		return filepath.Join(p.Paths().BinPath(), selfBin(IsWindows()))
+	}
+	return filepath.Join(p.Paths().BinPath(), pluginNameToBin(name, IsWindows()))
+}
+
 func createOrUpdateLink(fs afero.Fs, binDir, binary, plugin string) error {
 	dst := filepath.Join(binDir, pluginNameToBin(plugin, IsWindows()))
 
-	// TODO: afero.Fs
-	if err := removeLink(dst); err != nil {
+	if err := removeLink(fs, dst); err != nil {
 		return errors.Wrap(err, "failed to remove old symlink")
 	}
 	if _, err := fs.Stat(binary); os.IsNotExist(err) {
@@ -188,9 +370,7 @@ func createOrUpdateLink(fs afero.Fs, binDir, binary, plugin string) error {
 
 	// Create new
 	log.Infof("Creating symlink to %q at %q", binary, dst)
-
-	// TODO: afero.Fs
-	if err := os.Symlink(binary, dst); err != nil {
+	if err := symlink(fs, binary, dst); err != nil {
 		return errors.Wrapf(err, "failed to create a symlink from %q to %q", binary, dst)
 	}
 	log.Infof("Created symlink at %q", dst)
@@ -198,10 +378,70 @@ func createOrUpdateLink(fs afero.Fs, binDir, binary, plugin string) error {
 	return nil
 }
 
+// createOrUpdatePackageLink links the package of an interpreted plugin into the plugin directory,
+// where it gets loaded by the plugin engine.
+func createOrUpdatePackageLink(fs afero.Fs, pluginDir, pkgDir, plugin string) error {
+	dst := filepath.Join(pluginDir, plugin)
+
+	if err := removeLink(fs, dst); err != nil {
+		return errors.Wrap(err, "failed to remove old symlink")
+	}
+	if _, err := fs.Stat(filepath.Join(pkgDir, "plugin.yaml")); os.IsNotExist(err) {
+		return errors.Wrapf(err, "can't create symbolic link, plugin package (%q) has no plugin.yaml", pkgDir)
+	}
+	if err := fs.MkdirAll(pluginDir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create the plugin directory %q", pluginDir)
+	}
+
+	log.Infof("Creating symlink to %q at %q", pkgDir, dst)
+	if err := symlink(fs, pkgDir, dst); err != nil {
+		return errors.Wrapf(err, "failed to create a symlink from %q to %q", pkgDir, dst)
+	}
+	log.Infof("Created symlink at %q", dst)
+
+	return nil
+}
+
+// replaceLink atomically replaces the file at dst with a symlink to binary,
+// so that there is no moment without an executable at dst. It is used to update devctl itself.
+func replaceLink(fs afero.Fs, dst, binary string) error {
+	if _, err := fs.Stat(binary); os.IsNotExist(err) {
+		return errors.Wrapf(err, "can't create symbolic link, source binary (%q) cannot be found in extracted archive", binary)
+	}
+	if err := fs.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create the bin directory %q", filepath.Dir(dst))
+	}
+
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
+	_ = fs.Remove(tmp)
+	log.Infof("Creating symlink to %q at %q", binary, tmp)
+	if err := symlink(fs, binary, tmp); err != nil {
+		return errors.Wrapf(err, "failed to create a symlink from %q to %q", binary, tmp)
+	}
+	if err := fs.Rename(tmp, dst); err != nil {
+		_ = fs.Remove(tmp)
+		return errors.Wrapf(err, "failed to replace %q", dst)
+	}
+	log.Infof("Replaced symlink at %q", dst)
+	return nil
+}
+
+// symlink creates newname as a symbolic link to oldname, if fs supports symlinks.
+func symlink(fs afero.Fs, oldname, newname string) error {
+	linker, ok := fs.(afero.Linker)
+	if !ok {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: afero.ErrNoSymlink}
+	}
+	return linker.SymlinkIfPossible(oldname, newname)
+}
+
 // removeLink removes a symlink reference if exists.
-func removeLink(path string) error {
-	// TODO: afero.Fs
-	fi, err := os.Lstat(path)
+func removeLink(fs afero.Fs, path string) error {
+	lstater, ok := fs.(afero.Lstater)
+	if !ok {
+		return errors.Errorf("failed to read the symlink in %q: %s does not support symlinks", path, fs.Name())
+	}
+	fi, _, err := lstater.LstatIfPossible(path)
 	if os.IsNotExist(err) {
 		log.Infof("No file found at %q", path)
 		return nil
@@ -212,8 +452,7 @@ func removeLink(path string) error {
 	if fi.Mode()&os.ModeSymlink == 0 {
 		return errors.Errorf("file %q is not a symlink (mode=%s)", path, fi.Mode())
 	}
-	// TODO: afero.Fs
-	if err := os.Remove(path); err != nil {
+	if err := fs.Remove(path); err != nil {
 		return errors.Wrapf(err, "failed to remove the symlink in %q", path)
 	}
 	log.Infof("Removed symlink from %q", path)
@@ -240,8 +479,16 @@ func pluginNameToBin(name string, isWindows bool) string {
 	return name
 }
 
-// CleanupStaleKrewInstallations removes the versions that aren't the current version.
-func CleanupStaleKrewInstallations(fs afero.Fs, dir, currentVersion string) error {
+// selfBin returns the name of the devctl executable in the bin directory.
+func selfBin(isWindows bool) string {
+	if isWindows {
+		return constants.DevctlPluginName + ".exe"
+	}
+	return constants.DevctlPluginName
+}
+
+// CleanupStaleKrewInstallations removes the versions that aren't the current version or one of the kept versions.
+func CleanupStaleKrewInstallations(fs afero.Fs, dir, currentVersion string, keep ...string) error {
 	ls, err := afero.ReadDir(fs, dir)
 	if err != nil {
 		return errors.Wrap(err, "failed to read krew store directory")
@@ -249,7 +496,7 @@ func CleanupStaleKrewInstallations(fs afero.Fs, dir, currentVersion string) erro
 	log.Infof("Found %d entries in krew store directory", len(ls))
 	for _, d := range ls {
 		log.Infof("Found a krew installation: %s (%s)", d.Name(), d.Mode())
-		if d.IsDir() && d.Name() != currentVersion {
+		if d.IsDir() && d.Name() != currentVersion && !contains(keep, d.Name()) {
 			log.Debugf("Deleting stale krew install directory: %s", d.Name())
 			p := filepath.Join(dir, d.Name())
 			if err := fs.RemoveAll(p); err != nil {
@@ -260,3 +507,12 @@ func CleanupStaleKrewInstallations(fs afero.Fs, dir, currentVersion string) erro
 	}
 	return nil
 }
+
+func contains(versions []string, version string) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package installation
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alex-held/devctl/pkg/constants"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/index/spec"
+)
+
+// archive writes a .tar.gz containing files and returns its path and sha256 sum
+func archive(t *testing.T, dir string, files map[string]string) (string, string) {
+	b := &bytes.Buffer{}
+	gz := gzip.NewWriter(b)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	path := filepath.Join(dir, "archive.tar.gz")
+	require.NoError(t, ioutil.WriteFile(path, b.Bytes(), 0644))
+	sum := sha256.Sum256(b.Bytes())
+	return path, hex.EncodeToString(sum[:])
+}
+
+func plugin(name string, platform spec.Platform) spec.Plugin {
+	platform.URI = "https://example.com/" + name + ".tar.gz"
+	platform.Selector = &metav1.LabelSelector{}
+	return spec.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       spec.PluginSpec{Version: "v1.0.0", Platforms: []spec.Platform{platform}},
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	root, err := ioutil.TempDir("", "devctl-install")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	f := env.NewFactory(env.WithPaths(root))
+	require.NoError(t, os.MkdirAll(f.Paths().BinPath(), 0755))
+	require.NoError(t, os.MkdirAll(f.Paths().InstallReceiptsPath(), 0755))
+
+	path, sum := archive(t, root, map[string]string{"devctl-foo": "#!/bin/sh\n"})
+	require.NoError(t, Install(f, plugin("foo", spec.Platform{Sha256: sum, Bin: "devctl-foo"}), constants.DefaultIndexName, InstallOpts{ArchiveFileOverride: path}))
+
+	path, sum = archive(t, root, map[string]string{"pkg/plugin.yaml": "plugin:\n  name: bar\n", "pkg/main.go": "package bar\n"})
+	require.NoError(t, Install(f, plugin("bar", spec.Platform{Sha256: sum, Source: "pkg"}), constants.DefaultIndexName, InstallOpts{ArchiveFileOverride: path}))
+
+	// a local interpreted plugin named like the binary plugin
+	local := f.Pather().Plugin("foo")
+	require.NoError(t, os.MkdirAll(local, 0755))
+
+	require.NoError(t, Uninstall(f, "foo"))
+	assert.NoFileExists(t, filepath.Join(f.Paths().BinPath(), "devctl-foo"))
+	assert.DirExists(t, local, "only the link of the installed platform is removed")
+
+	require.NoError(t, Uninstall(f, "bar"))
+	_, err = os.Lstat(f.Pather().Plugin("bar"))
+	assert.True(t, os.IsNotExist(err), "the package link is removed")
+	assert.NoDirExists(t, f.Paths().PluginInstallPath("bar"))
+}
@@ -129,7 +129,7 @@ func moveFiles(fs afero.Fs, fromDir, toDir string, fo spec.FileOperation) error
 	}
 
 	for _, m := range moves {
-		log.Warnf("Move file from %q to %q", m.from, m.to)
+		log.Debugf("Move file from %q to %q", m.from, m.to)
 		if err := fs.MkdirAll(filepath.Dir(m.to), 0755); err != nil {
 			return errors.Wrapf(err, "failed to create move path %q", filepath.Dir(m.to))
 		}
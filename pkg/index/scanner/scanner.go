@@ -210,6 +210,23 @@ func ListIndexes(paths env.Paths) ([]Index, error) {
 	return indexes, nil
 }
 
+// IndexNames returns the names of the configured indexes without resolving their remote URLs.
+// If the index base directory does not exist, it returns an error that can be tested by os.IsNotExist.
+func IndexNames(paths env.Paths) ([]string, error) {
+	entries, err := ioutil.ReadDir(paths.IndexBase())
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
 // AddIndex initializes a new index to install plugins from.
 func AddIndex(paths env.Paths, name, url string) error {
 	dir := paths.IndexPath(name)
@@ -35,7 +35,18 @@ type Platform struct {
 	// Bin specifies the path to the plugin executable.
 	// The path is relative to the root of the installation folder.
 	// The binary will be linked after all FileOperations are executed.
-	Bin string `json:"bin"`
+	Bin string `json:"bin,omitempty"`
+
+	// Source specifies the path to the package of an interpreted plugin, containing its plugin.yaml.
+	// The path is relative to the root of the installation folder.
+	// The package will be linked into the plugin directory after all FileOperations are executed.
+	// Either Bin or Source has to be set.
+	Source string `json:"source,omitempty"`
+}
+
+// IsInterpreted returns true, if the platform installs an interpreted source package instead of a binary
+func (p Platform) IsInterpreted() bool {
+	return p.Source != ""
 }
 
 // FileOperation specifies a file copying operation from plugin archive to the
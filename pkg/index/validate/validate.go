@@ -105,8 +105,11 @@ func validatePlatform(p spec.Platform) error {
 	if !isValidSHA256(p.Sha256) {
 		return errors.Errorf("`sha256` value %s is not valid, must match pattern %s", p.Sha256, sha256Pattern)
 	}
-	if p.Bin == "" {
-		return errors.New("`bin` has to be set")
+	if p.Bin == "" && p.Source == "" {
+		return errors.New("either `bin` or `source` has to be set")
+	}
+	if p.Bin != "" && p.Source != "" {
+		return errors.New("`bin` and `source` can't be set at the same time")
 	}
 	if err := validateFiles(p.Files); err != nil {
 		return errors.Wrap(err, "`files` is invalid")
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"flag"
+	"strings"
+
+	kitlog "github.com/alex-held/devctl-kit/pkg/log"
+	"k8s.io/klog/v2"
+)
+
+// bridgeKlog routes the output of klog through l. klog keeps deciding which V(n) messages are written,
+// l decides which severities are logged and how.
+func bridgeKlog(l Log) {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	_ = fs.Set("logtostderr", "false")
+	_ = fs.Set("alsologtostderr", "false")
+	_ = fs.Set("stderrthreshold", "FATAL")
+	_ = fs.Set("one_output", "true")
+	klog.SetOutput(klogWriter{l: l})
+}
+
+// klogWriter logs the lines written by klog, keeping their severity and caller.
+//
+// e.g. "W0701 12:00:00.000000   12345 install.go:42] failed to install plugin"
+type klogWriter struct {
+	l Log
+}
+
+func (w klogWriter) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+	if line == "" {
+		return len(p), nil
+	}
+
+	msg, fields := line, Fields{}
+	if i := strings.Index(line, "] "); i > 0 {
+		header := strings.Fields(line[:i])
+		if len(header) > 0 {
+			fields["caller"] = header[len(header)-1]
+		}
+		msg = line[i+2:]
+	}
+
+	entry := w.l.WithFields(fields)
+	switch line[0] {
+	case 'E', 'F':
+		entry.Error(msg)
+	case 'W':
+		entry.Warn(msg)
+	default:
+		entry.Info(msg)
+	}
+	return len(p), nil
+}
+
+// bridgeKit routes the package level logs of the devctl-kit log package through l
+func bridgeKit(l Log) {
+	kitlog.SetDefault(kitLogger{l})
+}
+
+type kitLogger struct {
+	Log
+}
+
+func (k kitLogger) Logf(lvl kitlog.Level, format string, args ...interface{}) {
+	switch lvl {
+	case kitlog.Debug:
+		k.Debugf(format, args...)
+	case kitlog.Warn:
+		k.Warnf(format, args...)
+	case kitlog.Error:
+		k.Errorf(format, args...)
+	case kitlog.FATAL:
+		k.Fatalf(format, args...)
+	default:
+		k.Infof(format, args...)
+	}
+}
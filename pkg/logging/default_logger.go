@@ -41,6 +41,11 @@ const (
 	LogLevelError LogLevel = 3
 )
 
+// Fields are structured values attached to a log entry, e.g. the plugin, index and version an entry is about
+type Fields = logrus.Fields
+
+// Log is the logging facade of devctl. The Logger returned by env.Factory also receives the logs of klog
+// and of the devctl-kit log package, once it has been configured.
 type Log interface {
 	logrus.FieldLogger
 	Fatalf(format string, args ...interface{})
@@ -53,6 +58,8 @@ type Log interface {
 
 	Child(name string) Log
 	SetLevel(level LogLevel)
+	// Configure applies the options and routes klog and the devctl-kit log package through the Log
+	Configure(o Options) error
 }
 
 func (l *Logger) Fatalf(format string, args ...interface{}) {
@@ -2,6 +2,7 @@ package logging
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/muesli/termenv"
@@ -46,7 +47,7 @@ func (c DevCtlFormatter) Format(entry *logrus.Entry) (bytes []byte, err error) {
 	sb := &strings.Builder{}
 	lvlStyle := lLevel(entry.Level).LevelStyle()
 
-	msg := entry.Message
+	msg := strings.TrimRight(entry.Message, "\n")
 	lLevel(entry.Level).LevelStyle()
 	lvl := lvlStyle.String()
 
@@ -69,6 +70,14 @@ func (c DevCtlFormatter) Format(entry *logrus.Entry) (bytes []byte, err error) {
 	sb.Write(prefixnd)
 	sb.Write(prefixSpacer)
 	sb.Write([]byte(msg))
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.Write(normalizeStr(fmt.Sprintf(" %s=%q", k, fmt.Sprint(entry.Data[k]))))
+	}
 	sb.Write([]byte("\n"))
 
 	text := sb.String()
@@ -17,6 +17,9 @@ type Logger struct {
 	level     LogLevel
 	name      string
 	exitFn    func(c int)
+	file      *os.File
+	// terminal is set, if all outputs are terminals
+	terminal bool
 }
 
 func (l *Logger) WithField(key string, value interface{}) *logrus.Entry {
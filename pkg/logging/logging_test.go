@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	kitlog "github.com/alex-held/devctl-kit/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2"
+)
+
+func TestOptions_Level(t *testing.T) {
+	assert.Equal(t, LogLevelWarn, Options{}.Level())
+	assert.Equal(t, LogLevelInfo, Options{Verbosity: 1}.Level())
+	assert.Equal(t, LogLevelDebug, Options{Verbosity: 4}.Level())
+	assert.Error(t, Options{Format: "xml"}.Validate())
+}
+
+func TestLogger_Configure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devctl-logging")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "devctl.log")
+
+	stderr := &bytes.Buffer{}
+	l := NewLogger(WithOutputs(stderr), WithLevel(LogLevelWarn))
+	require.NoError(t, l.Configure(Options{Verbosity: 1, Format: FormatJSON, File: file}))
+
+	l.WithFields(Fields{"plugin": "sdkman", "index": "default", "version": "v1.0.0"}).Info("Installing plugin")
+	l.Debugf("hidden below -v 2")
+	klog.Warningf("failed to update index %q", "default")
+	kitlog.Errorf("failed to link %s", "sdkman")
+	klog.Flush()
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	var entries []map[string]interface{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		entry := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(s.Bytes(), &entry), s.Text())
+		entries = append(entries, entry)
+	}
+
+	require.Len(t, entries, 3)
+	assert.Equal(t, "info", entries[0]["level"])
+	assert.Equal(t, "Installing plugin", entries[0]["msg"])
+	assert.Equal(t, "sdkman", entries[0]["plugin"])
+	assert.Equal(t, "default", entries[0]["index"])
+	assert.Equal(t, "v1.0.0", entries[0]["version"])
+
+	assert.Equal(t, "warning", entries[1]["level"])
+	assert.Equal(t, `failed to update index "default"`, entries[1]["msg"])
+	assert.Contains(t, entries[1]["caller"], "logging_test.go:")
+
+	assert.Equal(t, "error", entries[2]["level"])
+	assert.Equal(t, "failed to link sdkman", entries[2]["msg"])
+
+	assert.Empty(t, stderr.String(), "logs go to the log file only")
+}
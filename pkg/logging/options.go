@@ -2,9 +2,13 @@ package logging
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"os"
 
+	"github.com/mattn/go-isatty"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
 )
 
 type Option func(*Logger) *Logger
@@ -41,10 +45,19 @@ func WithExitFn(exitFn func(int)) Option {
 func WithOutputs(w ...io.Writer) Option {
 	return func(l *Logger) *Logger {
 		l.OutWriter = io.MultiWriter(w...)
+		l.terminal = len(w) > 0
+		for _, out := range w {
+			l.terminal = l.terminal && isTerminal(out)
+		}
 		return l
 	}
 }
 
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
+
 func WithLevel(level LogLevel) Option {
 	return func(l *Logger) *Logger {
 		l.level = level
@@ -68,3 +81,90 @@ func WithVerbose(verbose bool) Option {
 		return l
 	}
 }
+
+// Format is the format of the log output
+type Format string
+
+const (
+	// FormatText logs human readable lines
+	FormatText Format = "text"
+	// FormatJSON logs a JSON object per line, e.g. for CI
+	FormatJSON Format = "json"
+)
+
+// Options configure the logging of devctl. They are set by the -v, --log-format and --log-file flags.
+type Options struct {
+	// Verbosity is the klog verbosity set by -v. Warnings and errors are logged at 0, info at 1 and debug messages from 2 on.
+	Verbosity int
+	Format    Format
+	// File is appended to instead of logging to stderr, if it is set
+	File string
+}
+
+// NewOptions returns the default Options
+func NewOptions() *Options {
+	return &Options{Format: FormatText}
+}
+
+// AddFlags binds the --log-format and --log-file flags to o. The -v flag is registered by klog.
+func (o *Options) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar((*string)(&o.Format), "log-format", string(o.Format), "Log format, one of text|json")
+	flags.StringVar(&o.File, "log-file", o.File, "If non-empty, append the logs to this file instead of stderr")
+}
+
+// Validate checks the log format
+func (o Options) Validate() error {
+	switch o.Format {
+	case FormatText, FormatJSON, "":
+		return nil
+	default:
+		return fmt.Errorf("unknown log format %q, use text or json", o.Format)
+	}
+}
+
+// Level returns the LogLevel enabled by the verbosity
+func (o Options) Level() LogLevel {
+	switch {
+	case o.Verbosity <= 0:
+		return LogLevelWarn
+	case o.Verbosity == 1:
+		return LogLevelInfo
+	default:
+		return LogLevelDebug
+	}
+}
+
+// Configure applies o and routes the logs of klog and of the devctl-kit log package through l
+func (l *Logger) Configure(o Options) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	out := l.OutWriter
+	if o.File != "" {
+		f, err := os.OpenFile(o.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open the log file: %w", err)
+		}
+		if l.file != nil {
+			_ = l.file.Close()
+		}
+		l.file = f
+		out = f
+	}
+	l.logger.Out = io.MultiWriter(l.Output, out)
+
+	switch {
+	case o.Format == FormatJSON:
+		l.logger.SetFormatter(&logrus.JSONFormatter{})
+	case o.File != "" || !l.terminal:
+		l.logger.SetFormatter(&logrus.TextFormatter{DisableColors: true, FullTimestamp: true})
+	default:
+		l.logger.SetFormatter(&DevCtlFormatter{})
+	}
+	WithLevel(o.Level())(l)
+
+	bridgeKlog(l)
+	bridgeKit(l)
+	return nil
+}
@@ -0,0 +1,234 @@
+// Package pluginrpc launches out-of-process plugins speaking the gRPC plugin protocol defined in pluginv1.
+//
+// devctl serves the Host service on a unix socket, which allows the plugin to resolve devctl paths,
+// read and write its config section and log through the logger of devctl.
+// The plugin serves the Plugin service on a unix socket, which devctl uses to describe, complete and run it.
+// Plugin authors use the Go SDK in the sdk package.
+package pluginrpc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+
+	"github.com/alex-held/devctl/pkg/pluginrpc/handshake"
+	"github.com/alex-held/devctl/pkg/pluginrpc/pluginv1"
+)
+
+// SupportedVersions are the protocol versions supported by devctl
+var SupportedVersions = []int{handshake.ProtocolVersion}
+
+const (
+	// DefaultStartTimeout is how long to wait for the handshake of a plugin
+	DefaultStartTimeout = 10 * time.Second
+	// DefaultStopTimeout is how long to wait for a plugin to exit after it has been interrupted, before it gets killed
+	DefaultStopTimeout = 5 * time.Second
+)
+
+// Options configure how a plugin gets launched
+type Options struct {
+	// Name is the name the plugin has been invoked as
+	Name string
+	// Env is the environment of the plugin process, the handshake configuration is added to it
+	Env []string
+	// Host serves the callbacks of the plugin
+	Host pluginv1.HostServer
+	// Stderr receives the diagnostics the plugin writes to its own stderr
+	Stderr io.Writer
+
+	StartTimeout time.Duration
+	StopTimeout  time.Duration
+}
+
+// Client is a running plugin process
+type Client struct {
+	pluginv1.PluginClient
+
+	// Version is the negotiated protocol version
+	Version int
+
+	opts   Options
+	cmd    *exec.Cmd
+	dir    string
+	server *grpc.Server
+	conn   *grpc.ClientConn
+	exited chan struct{}
+}
+
+// Start launches the plugin executable at path and performs the handshake
+func Start(ctx context.Context, path string, opts Options) (c *Client, err error) {
+	if opts.StartTimeout == 0 {
+		opts.StartTimeout = DefaultStartTimeout
+	}
+	if opts.StopTimeout == 0 {
+		opts.StopTimeout = DefaultStopTimeout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+
+	c = &Client{opts: opts, exited: make(chan struct{})}
+	defer func() {
+		if err != nil {
+			_ = c.Close()
+		}
+	}()
+
+	// unix socket paths are limited to about 100 bytes, so the temp dir is kept short
+	if c.dir, err = ioutil.TempDir("", "devctl-plugin"); err != nil {
+		return nil, errors.Wrap(err, "failed to create the socket directory")
+	}
+	cfg := &handshake.Config{
+		Versions:   SupportedVersions,
+		HostAddr:   filepath.Join(c.dir, "host.sock"),
+		PluginAddr: filepath.Join(c.dir, "plugin.sock"),
+		Name:       opts.Name,
+	}
+
+	if err = c.serveHost(cfg.HostAddr); err != nil {
+		return nil, err
+	}
+
+	c.cmd = exec.Command(path)
+	c.cmd.Env = append(append([]string{}, opts.Env...), cfg.Env()...)
+	c.cmd.Stderr = opts.Stderr
+	stdout, err := c.cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the stdout of the plugin")
+	}
+	if err = c.cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "failed to start plugin %s", path)
+	}
+	go func() {
+		_ = c.cmd.Wait()
+		close(c.exited)
+	}()
+
+	line, err := c.readHandshake(ctx, stdout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "plugin %s failed to start", path)
+	}
+	if _, err = handshake.Negotiate(SupportedVersions, []int{line.Version}); err != nil {
+		return nil, err
+	}
+	c.Version = line.Version
+
+	dialCtx, cancel := context.WithTimeout(ctx, opts.StartTimeout)
+	defer cancel()
+	c.conn, err = grpc.DialContext(dialCtx, line.Addr,
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithContextDialer(dialUnix),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to plugin %s", path)
+	}
+	c.PluginClient = pluginv1.NewPluginClient(c.conn)
+	return c, nil
+}
+
+func (c *Client) serveHost(addr string) error {
+	lis, err := net.Listen("unix", addr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen for plugin callbacks")
+	}
+	c.server = grpc.NewServer()
+	if c.opts.Host != nil {
+		pluginv1.RegisterHostServer(c.server, c.opts.Host)
+	}
+	go func() { _ = c.server.Serve(lis) }()
+	return nil
+}
+
+// readHandshake reads the handshake line from the stdout of the plugin.
+// The remaining output is discarded, so that the plugin never blocks writing to stdout.
+func (c *Client) readHandshake(ctx context.Context, stdout io.Reader) (handshake.Line, error) {
+	lines := make(chan string, 1)
+	go func() {
+		r := bufio.NewReader(stdout)
+		line, _ := r.ReadString('\n')
+		lines <- line
+		_, _ = io.Copy(ioutil.Discard, r)
+	}()
+
+	timer := time.NewTimer(c.opts.StartTimeout)
+	defer timer.Stop()
+	select {
+	case line := <-lines:
+		if line == "" {
+			<-c.exited
+			return handshake.Line{}, fmt.Errorf("the plugin exited before the handshake: %v", c.cmd.ProcessState)
+		}
+		return handshake.ParseLine(line)
+	case <-timer.C:
+		return handshake.Line{}, fmt.Errorf("timed out after %s waiting for the handshake", c.opts.StartTimeout)
+	case <-ctx.Done():
+		return handshake.Line{}, ctx.Err()
+	}
+}
+
+// Run runs the plugin with args, writes its output to stdout and stderr and returns its exit code
+func (c *Client) Run(ctx context.Context, args, env []string, stdout, stderr io.Writer) (int, error) {
+	stream, err := c.PluginClient.Run(ctx, &pluginv1.RunRequest{Args: args, Env: env})
+	if err != nil {
+		return 0, err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch out := resp.Output.(type) {
+		case *pluginv1.RunResponse_Stdout:
+			_, err = stdout.Write(out.Stdout)
+		case *pluginv1.RunResponse_Stderr:
+			_, err = stderr.Write(out.Stderr)
+		case *pluginv1.RunResponse_ExitCode:
+			return int(out.ExitCode), nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Close interrupts the plugin process, kills it if it doesn't exit in time and releases all resources
+func (c *Client) Close() error {
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+	if c.cmd != nil && c.cmd.Process != nil {
+		_ = c.cmd.Process.Signal(os.Interrupt)
+		select {
+		case <-c.exited:
+		case <-time.After(c.opts.StopTimeout):
+			_ = c.cmd.Process.Kill()
+			<-c.exited
+		}
+	}
+	if c.server != nil {
+		c.server.Stop()
+	}
+	if c.dir != "" {
+		return os.RemoveAll(c.dir)
+	}
+	return nil
+}
+
+func dialUnix(ctx context.Context, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "unix", addr)
+}
@@ -0,0 +1,146 @@
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/pluginrpc/handshake"
+	"github.com/alex-held/devctl/pkg/pluginrpc/pluginv1"
+	"github.com/alex-held/devctl/pkg/pluginrpc/sdk"
+)
+
+// TestMain turns the test binary into a plugin, when it gets launched by Start or described
+func TestMain(m *testing.M) {
+	if os.Getenv(handshake.MagicCookieKey) == handshake.MagicCookieValue || len(os.Args) > 1 && os.Args[1] == sdk.DescribeArg {
+		if err := sdk.Serve(testPlugin{}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+type testPlugin struct{}
+
+func (testPlugin) Describe() sdk.Info {
+	return sdk.Info{Name: "test", Version: "v1.0.0", Short: "a test plugin", Commands: []sdk.Command{{Name: "greet"}}}
+}
+
+func (testPlugin) Complete(args []string, toComplete string) ([]string, sdk.ShellCompDirective) {
+	return []string{toComplete + "1", toComplete + "2"}, sdk.ShellCompDirectiveNoFileComp
+}
+
+func (testPlugin) Run(_ context.Context, host *sdk.Host, args []string) error {
+	switch args[0] {
+	case "greet":
+		root, err := host.Path(sdk.PathSDK, "go")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(host.Stdout(), "hello from %s\n", root)
+		fmt.Fprintln(host.Stderr(), "to stderr")
+		return host.Log(sdk.LevelInfo, "greeted", map[string]string{"sdk": "go"})
+	case "config":
+		cfg := map[string]int{}
+		if err := host.Config(&cfg); err != nil {
+			return err
+		}
+		cfg["runs"]++
+		return host.SetConfig(cfg)
+	case "exit":
+		return &sdk.ExitError{Code: 3}
+	}
+	return errors.New("unknown command")
+}
+
+func startTestPlugin(t *testing.T) (*Client, env.Factory) {
+	base, err := ioutil.TempDir("", "devctl-pluginrpc")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(base) })
+
+	f := env.NewFactory(env.WithPaths(base))
+	c, err := Start(context.Background(), os.Args[0], Options{
+		Name: "test",
+		Env:  os.Environ(),
+		Host: NewHostServer(f, "test"),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c, f
+}
+
+func TestPluginHandler_IsPlugin(t *testing.T) {
+	base, err := ioutil.TempDir("", "devctl-pluginrpc")
+	require.NoError(t, err)
+	defer os.RemoveAll(base)
+
+	f := env.NewFactory(env.WithPaths(base))
+	sut := NewPluginHandler(f, nil).(*PluginHandler)
+	assert.True(t, sut.IsPlugin(os.Args[0]))
+	assert.False(t, sut.IsPlugin("/bin/true"))
+	assert.False(t, sut.IsPlugin("/does/not/exist"))
+
+	// descriptions are cached, so the executables are not described on every dispatch
+	_, err = os.Stat(f.Pather().Cache("plugins", "describe.json"))
+	assert.NoError(t, err)
+}
+
+func TestClient_Describe(t *testing.T) {
+	c, _ := startTestPlugin(t)
+	assert.Equal(t, handshake.ProtocolVersion, c.Version)
+
+	info, err := c.Describe(context.Background(), &pluginv1.DescribeRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "test", info.Name)
+	assert.Equal(t, "a test plugin", info.ShortDescription)
+	assert.Equal(t, "greet", info.Commands[0].Name)
+
+	completions, err := c.Complete(context.Background(), &pluginv1.CompleteRequest{ToComplete: "v"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1", "v2"}, completions.Completions)
+	assert.Equal(t, int32(sdk.ShellCompDirectiveNoFileComp), completions.Directive)
+}
+
+func TestClient_Run(t *testing.T) {
+	c, f := startTestPlugin(t)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	code, err := c.Run(context.Background(), []string{"greet"}, nil, stdout, stderr)
+	require.NoError(t, err)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, fmt.Sprintf("hello from %s\n", f.Pather().SDK("go")), stdout.String())
+	assert.Equal(t, "to stderr\n", stderr.String())
+
+	code, err = c.Run(context.Background(), []string{"exit"}, nil, stdout, stderr)
+	require.NoError(t, err)
+	assert.Equal(t, 3, code)
+
+	code, err = c.Run(context.Background(), []string{"unknown"}, nil, stdout, stderr)
+	require.NoError(t, err)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "Error: unknown command")
+}
+
+func TestClient_Config(t *testing.T) {
+	c, f := startTestPlugin(t)
+
+	for i := 0; i < 2; i++ {
+		code, err := c.Run(context.Background(), []string{"config"}, nil, ioutil.Discard, ioutil.Discard)
+		require.NoError(t, err)
+		require.Equal(t, 0, code)
+	}
+
+	b, err := ioutil.ReadFile(ConfigPath(f, "test"))
+	require.NoError(t, err)
+	assert.Equal(t, "runs: 2\n", string(b))
+}
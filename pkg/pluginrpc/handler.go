@@ -0,0 +1,74 @@
+package pluginrpc
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/alex-held/devctl/pkg/cli/util"
+	"github.com/alex-held/devctl/pkg/env"
+	devctlerrors "github.com/alex-held/devctl/pkg/errors"
+	"github.com/alex-held/devctl/pkg/registry"
+)
+
+// PluginHandler runs the plugins built with the plugin SDK using the plugin protocol,
+// all other plugins are executed by the wrapped util.PluginHandler.
+type PluginHandler struct {
+	util.PluginHandler
+	f         env.Factory
+	describer *registry.Describer
+}
+
+// NewPluginHandler returns a PluginHandler, which serves the callbacks of plugins using f
+func NewPluginHandler(f env.Factory, fallback util.PluginHandler) util.PluginHandler {
+	return &PluginHandler{PluginHandler: fallback, f: f, describer: registry.NewDescriber(f)}
+}
+
+// IsPlugin returns true, if the executable at path has been built with the plugin SDK,
+// i.e. its description lists the versions of the plugin protocol.
+// Descriptions are cached per path and modification time, so the executable is only described once.
+func (h *PluginHandler) IsPlugin(path string) bool {
+	desc, err := h.describer.Describe(path)
+	return err == nil && desc != nil && len(desc.Protocol) > 0
+}
+
+// Execute runs the plugin at executablePath, returning a *devctlerrors.ExitError if it exits with a non-zero code
+func (h *PluginHandler) Execute(executablePath string, cmdArgs, environment []string) error {
+	if !h.IsPlugin(executablePath) {
+		return h.PluginHandler.Execute(executablePath, cmdArgs, environment)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	name := PluginName(executablePath)
+	c, err := Start(ctx, executablePath, Options{
+		Name:   name,
+		Env:    environment,
+		Host:   NewHostServer(h.f, name),
+		Stderr: h.f.Streams().ErrOut,
+	})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	code, err := c.Run(ctx, cmdArgs, environment, h.f.Streams().Out, h.f.Streams().ErrOut)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return &devctlerrors.ExitError{Code: code}
+	}
+	return nil
+}
+
+// PluginName returns the name of the plugin executable at path, e.g. 'foo-bar' for '/bin/devctl-foo-bar'
+func PluginName(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return strings.TrimPrefix(name, "devctl-")
+}
@@ -0,0 +1,150 @@
+// Package handshake implements the handshake between devctl and the out-of-process plugins it launches.
+//
+// devctl launches a plugin with the magic cookie, the protocol versions it supports and the address of its
+// host service in the environment. The plugin picks the highest protocol version it supports, starts serving
+// the plugin service and announces it by writing a single line to stdout:
+//
+//	VERSION|NETWORK|ADDRESS
+//
+// e.g. "1|unix|/tmp/devctl-plugin-123/plugin.sock". Anything the plugin writes to stdout afterwards is ignored.
+package handshake
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MagicCookieKey is the environment variable holding MagicCookieValue
+	MagicCookieKey = "DEVCTL_PLUGIN_MAGIC_COOKIE"
+	// MagicCookieValue tells a plugin that it has been launched by devctl.
+	// devctl also looks for it inside of an executable to detect plugins speaking the protocol.
+	MagicCookieValue = "d3f1c7a8-devctl-plugin-rpc-5b2e9f04"
+
+	// ProtocolVersionsKey is the environment variable holding the comma separated protocol versions supported by devctl
+	ProtocolVersionsKey = "DEVCTL_PLUGIN_PROTOCOL_VERSIONS"
+	// HostAddrKey is the environment variable holding the unix socket of the host service
+	HostAddrKey = "DEVCTL_PLUGIN_HOST_ADDR"
+	// PluginAddrKey is the environment variable holding the unix socket the plugin should listen on
+	PluginAddrKey = "DEVCTL_PLUGIN_ADDR"
+	// PluginNameKey is the environment variable holding the name the plugin has been invoked as
+	PluginNameKey = "DEVCTL_PLUGIN_NAME"
+)
+
+// ProtocolVersion is the current version of the plugin protocol
+const ProtocolVersion = 1
+
+var (
+	// ErrNotLaunchedByDevctl is returned, if the magic cookie is missing from the environment
+	ErrNotLaunchedByDevctl = errors.New("this binary is a devctl plugin and must be launched by devctl")
+	// ErrIncompatibleVersion is returned, if devctl and the plugin have no protocol version in common
+	ErrIncompatibleVersion = errors.New("incompatible plugin protocol version")
+	// ErrMalformed is returned, if the handshake line of the plugin can't be parsed
+	ErrMalformed = errors.New("malformed plugin handshake")
+)
+
+// Config is the handshake configuration passed from devctl to the plugin using its environment
+type Config struct {
+	// Versions are the protocol versions supported by devctl
+	Versions []int
+	// HostAddr is the unix socket of the host service
+	HostAddr string
+	// PluginAddr is the unix socket the plugin should listen on
+	PluginAddr string
+	// Name is the name the plugin has been invoked as
+	Name string
+}
+
+// Env returns the environment variables passing c to the plugin
+func (c *Config) Env() []string {
+	versions := make([]string, 0, len(c.Versions))
+	for _, v := range c.Versions {
+		versions = append(versions, strconv.Itoa(v))
+	}
+	return []string{
+		MagicCookieKey + "=" + MagicCookieValue,
+		ProtocolVersionsKey + "=" + strings.Join(versions, ","),
+		HostAddrKey + "=" + c.HostAddr,
+		PluginAddrKey + "=" + c.PluginAddr,
+		PluginNameKey + "=" + c.Name,
+	}
+}
+
+// FromEnv reads the handshake configuration passed by devctl from the environment of the plugin
+func FromEnv() (*Config, error) {
+	if os.Getenv(MagicCookieKey) != MagicCookieValue {
+		return nil, ErrNotLaunchedByDevctl
+	}
+	versions, err := ParseVersions(os.Getenv(ProtocolVersionsKey))
+	if err != nil {
+		return nil, err
+	}
+	return &Config{
+		Versions:   versions,
+		HostAddr:   os.Getenv(HostAddrKey),
+		PluginAddr: os.Getenv(PluginAddrKey),
+		Name:       os.Getenv(PluginNameKey),
+	}, nil
+}
+
+// ParseVersions parses a comma separated list of protocol versions
+func ParseVersions(s string) (versions []int, err error) {
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		v, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid protocol version %q", ErrMalformed, field)
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// Negotiate returns the highest protocol version offered by devctl, which is supported by the plugin
+func Negotiate(offered, supported []int) (int, error) {
+	sorted := append([]int(nil), offered...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	for _, v := range sorted {
+		for _, s := range supported {
+			if v == s {
+				return v, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: devctl supports %v, the plugin supports %v", ErrIncompatibleVersion, offered, supported)
+}
+
+// Line is the handshake line written by the plugin, once it is serving
+type Line struct {
+	Version int
+	Network string
+	Addr    string
+}
+
+// String formats l as VERSION|NETWORK|ADDRESS
+func (l Line) String() string {
+	return fmt.Sprintf("%d|%s|%s", l.Version, l.Network, l.Addr)
+}
+
+// ParseLine parses the handshake line written by the plugin
+func ParseLine(s string) (Line, error) {
+	parts := strings.Split(strings.TrimSpace(s), "|")
+	if len(parts) != 3 {
+		return Line{}, fmt.Errorf("%w: %q", ErrMalformed, s)
+	}
+	v, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Line{}, fmt.Errorf("%w: invalid protocol version in %q", ErrMalformed, s)
+	}
+	if parts[1] != "unix" || parts[2] == "" {
+		return Line{}, fmt.Errorf("%w: unsupported address %q", ErrMalformed, parts[1]+"|"+parts[2])
+	}
+	return Line{Version: v, Network: parts[1], Addr: parts[2]}, nil
+}
@@ -0,0 +1,38 @@
+package handshake
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	v, err := Negotiate([]int{1, 2, 3}, []int{2, 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, v)
+
+	_, err = Negotiate([]int{1}, []int{2})
+	assert.ErrorIs(t, err, ErrIncompatibleVersion)
+}
+
+func TestParseLine(t *testing.T) {
+	line, err := ParseLine("1|unix|/tmp/plugin.sock\n")
+	require.NoError(t, err)
+	assert.Equal(t, Line{Version: 1, Network: "unix", Addr: "/tmp/plugin.sock"}, line)
+	assert.Equal(t, "1|unix|/tmp/plugin.sock", line.String())
+
+	for _, s := range []string{"", "hello world", "x|unix|/tmp/plugin.sock", "1|tcp|127.0.0.1:80", "1|unix|"} {
+		_, err := ParseLine(s)
+		assert.ErrorIs(t, err, ErrMalformed, s)
+	}
+}
+
+func TestParseVersions(t *testing.T) {
+	versions, err := ParseVersions("1, 2,3")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, versions)
+
+	_, err = ParseVersions("1,two")
+	assert.ErrorIs(t, err, ErrMalformed)
+}
@@ -0,0 +1,99 @@
+package pluginrpc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/logging"
+	"github.com/alex-held/devctl/pkg/pluginrpc/pluginv1"
+)
+
+// hostServer implements the Host service called back by the plugin name
+type hostServer struct {
+	pluginv1.UnimplementedHostServer
+	f    env.Factory
+	name string
+}
+
+// NewHostServer returns the Host service for the plugin name, which is backed by the factory
+func NewHostServer(f env.Factory, name string) pluginv1.HostServer {
+	return &hostServer{f: f, name: name}
+}
+
+// ConfigPath returns the path of the config section of the plugin name
+func ConfigPath(f env.Factory, name string) string {
+	return f.Pather().Config("plugins", name+".yaml")
+}
+
+func (h *hostServer) Path(_ context.Context, req *pluginv1.PathRequest) (*pluginv1.PathResponse, error) {
+	pather := h.f.Pather()
+	var path string
+	switch req.Kind {
+	case pluginv1.PathKind_PATH_KIND_ROOT:
+		path = pather.ConfigRoot(req.Elem...)
+	case pluginv1.PathKind_PATH_KIND_CONFIG:
+		path = pather.Config(req.Elem...)
+	case pluginv1.PathKind_PATH_KIND_BIN:
+		path = pather.Bin(req.Elem...)
+	case pluginv1.PathKind_PATH_KIND_SDK:
+		path = pather.SDK(req.Elem...)
+	case pluginv1.PathKind_PATH_KIND_PLUGIN:
+		path = pather.Plugin(req.Elem...)
+	case pluginv1.PathKind_PATH_KIND_CACHE:
+		path = pather.Cache(req.Elem...)
+	case pluginv1.PathKind_PATH_KIND_DOWNLOAD:
+		path = pather.Download(req.Elem...)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown path kind %v", req.Kind)
+	}
+	return &pluginv1.PathResponse{Path: path}, nil
+}
+
+func (h *hostServer) GetConfig(context.Context, *pluginv1.GetConfigRequest) (*pluginv1.GetConfigResponse, error) {
+	b, err := afero.ReadFile(h.f.Fs(), ConfigPath(h.f, h.name))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, status.Errorf(codes.Internal, "failed to read the config of plugin %s: %v", h.name, err)
+	}
+	return &pluginv1.GetConfigResponse{Config: b}, nil
+}
+
+func (h *hostServer) SetConfig(_ context.Context, req *pluginv1.SetConfigRequest) (*pluginv1.SetConfigResponse, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(req.Config, &v); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "the config of plugin %s is not valid yaml: %v", h.name, err)
+	}
+	path := ConfigPath(h.f, h.name)
+	if err := h.f.Fs().MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create the config directory: %v", err)
+	}
+	if err := afero.WriteFile(h.f.Fs(), path, req.Config, 0644); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to write the config of plugin %s: %v", h.name, err)
+	}
+	return &pluginv1.SetConfigResponse{}, nil
+}
+
+func (h *hostServer) Log(_ context.Context, req *pluginv1.LogRequest) (*pluginv1.LogResponse, error) {
+	fields := logging.Fields{"plugin": h.name}
+	for k, v := range req.Fields {
+		fields[k] = v
+	}
+	logger := h.f.Logger().WithFields(fields)
+	switch req.Level {
+	case pluginv1.LogLevel_LOG_LEVEL_DEBUG:
+		logger.Debug(req.Message)
+	case pluginv1.LogLevel_LOG_LEVEL_WARN:
+		logger.Warn(req.Message)
+	case pluginv1.LogLevel_LOG_LEVEL_ERROR:
+		logger.Error(req.Message)
+	default:
+		logger.Info(req.Message)
+	}
+	return &pluginv1.LogResponse{}, nil
+}
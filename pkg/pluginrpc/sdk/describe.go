@@ -0,0 +1,40 @@
+package sdk
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// DescribeArg is the argument devctl invokes external plugins with to describe them, see registry.Description
+const DescribeArg = "__devctl_describe"
+
+type describeCommand struct {
+	Name     string            `json:"name"`
+	Short    string            `json:"short,omitempty"`
+	Long     string            `json:"long,omitempty"`
+	Commands []describeCommand `json:"commands,omitempty"`
+}
+
+type description struct {
+	describeCommand
+	Version    string `json:"version,omitempty"`
+	Completion bool   `json:"completion,omitempty"`
+	Protocol   []int  `json:"protocol,omitempty"`
+}
+
+// writeDescription writes the description of p in the format expected by devctl for DescribeArg
+func writeDescription(w io.Writer, p Plugin) error {
+	info := p.Describe()
+	return json.NewEncoder(w).Encode(description{
+		describeCommand: describeCommand{Name: info.Name, Short: info.Short, Long: info.Long, Commands: toDescribeCommands(info.Commands)},
+		Version:         info.Version,
+		Protocol:        SupportedVersions,
+	})
+}
+
+func toDescribeCommands(cmds []Command) (out []describeCommand) {
+	for _, c := range cmds {
+		out = append(out, describeCommand{Name: c.Name, Short: c.Short, Commands: toDescribeCommands(c.Commands)})
+	}
+	return out
+}
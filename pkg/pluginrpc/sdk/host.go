@@ -0,0 +1,113 @@
+package sdk
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alex-held/devctl/pkg/pluginrpc/pluginv1"
+)
+
+// PathKind selects a devctl directory
+type PathKind = pluginv1.PathKind
+
+const (
+	PathRoot     = pluginv1.PathKind_PATH_KIND_ROOT
+	PathConfig   = pluginv1.PathKind_PATH_KIND_CONFIG
+	PathBin      = pluginv1.PathKind_PATH_KIND_BIN
+	PathSDK      = pluginv1.PathKind_PATH_KIND_SDK
+	PathPlugin   = pluginv1.PathKind_PATH_KIND_PLUGIN
+	PathCache    = pluginv1.PathKind_PATH_KIND_CACHE
+	PathDownload = pluginv1.PathKind_PATH_KIND_DOWNLOAD
+)
+
+// Level is the level of a log message
+type Level = pluginv1.LogLevel
+
+const (
+	LevelDebug = pluginv1.LogLevel_LOG_LEVEL_DEBUG
+	LevelInfo  = pluginv1.LogLevel_LOG_LEVEL_INFO
+	LevelWarn  = pluginv1.LogLevel_LOG_LEVEL_WARN
+	LevelError = pluginv1.LogLevel_LOG_LEVEL_ERROR
+)
+
+// Host calls back into devctl while the plugin is running
+type Host struct {
+	client pluginv1.HostClient
+	ctx    context.Context
+	env    []string
+
+	mu     sync.Mutex
+	stream pluginv1.Plugin_RunServer
+}
+
+// Env returns the environment devctl has been invoked with
+func (h *Host) Env() []string {
+	return h.env
+}
+
+// Path returns the devctl directory of kind joined with elem
+func (h *Host) Path(kind PathKind, elem ...string) (string, error) {
+	resp, err := h.client.Path(h.ctx, &pluginv1.PathRequest{Kind: kind, Elem: elem})
+	if err != nil {
+		return "", err
+	}
+	return resp.Path, nil
+}
+
+// Config decodes the yaml config section of the plugin into v
+func (h *Host) Config(v interface{}) error {
+	resp, err := h.client.GetConfig(h.ctx, &pluginv1.GetConfigRequest{})
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(resp.Config, v)
+}
+
+// SetConfig encodes v as yaml and replaces the config section of the plugin with it
+func (h *Host) SetConfig(v interface{}) error {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = h.client.SetConfig(h.ctx, &pluginv1.SetConfigRequest{Config: b})
+	return err
+}
+
+// Log writes msg with fields to the logger of devctl
+func (h *Host) Log(level Level, msg string, fields map[string]string) error {
+	_, err := h.client.Log(h.ctx, &pluginv1.LogRequest{Level: level, Message: msg, Fields: fields})
+	return err
+}
+
+// Stdout returns a writer streaming to the stdout of devctl
+func (h *Host) Stdout() io.Writer {
+	return writerFunc(func(p []byte) error {
+		return h.send(&pluginv1.RunResponse{Output: &pluginv1.RunResponse_Stdout{Stdout: append([]byte(nil), p...)}})
+	})
+}
+
+// Stderr returns a writer streaming to the stderr of devctl
+func (h *Host) Stderr() io.Writer {
+	return writerFunc(func(p []byte) error {
+		return h.send(&pluginv1.RunResponse{Output: &pluginv1.RunResponse_Stderr{Stderr: append([]byte(nil), p...)}})
+	})
+}
+
+// send serializes writes to the stream, which must not be used concurrently
+func (h *Host) send(resp *pluginv1.RunResponse) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stream.Send(resp)
+}
+
+type writerFunc func(p []byte) error
+
+func (w writerFunc) Write(p []byte) (int, error) {
+	if err := w(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
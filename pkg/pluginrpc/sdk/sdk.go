@@ -0,0 +1,202 @@
+// Package sdk is the Go SDK for out-of-process devctl plugins.
+//
+// A plugin implements the Plugin interface and calls Serve from its main function.
+// The resulting executable is installed as devctl-NAME, devctl detects that it has been built
+// with the SDK and runs it using the plugin protocol:
+//
+//	type hello struct{}
+//
+//	func (hello) Describe() sdk.Info { return sdk.Info{Name: "hello", Short: "says hello"} }
+//
+//	func (hello) Complete(args []string, toComplete string) ([]string, sdk.ShellCompDirective) {
+//		return nil, sdk.ShellCompDirectiveNoFileComp
+//	}
+//
+//	func (hello) Run(ctx context.Context, host *sdk.Host, args []string) error {
+//		root, err := host.Path(sdk.PathRoot)
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Fprintf(host.Stdout(), "hello from %s\n", root)
+//		return nil
+//	}
+//
+//	func main() {
+//		if err := sdk.Serve(hello{}); err != nil {
+//			fmt.Fprintln(os.Stderr, err)
+//			os.Exit(1)
+//		}
+//	}
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"google.golang.org/grpc"
+
+	"github.com/alex-held/devctl/pkg/pluginrpc/handshake"
+	"github.com/alex-held/devctl/pkg/pluginrpc/pluginv1"
+)
+
+// SupportedVersions are the protocol versions supported by the SDK
+var SupportedVersions = []int{handshake.ProtocolVersion}
+
+// ShellCompDirective mirrors cobra.ShellCompDirective
+type ShellCompDirective int32
+
+const (
+	ShellCompDirectiveError ShellCompDirective = 1 << iota
+	ShellCompDirectiveNoSpace
+	ShellCompDirectiveNoFileComp
+	ShellCompDirectiveFilterFileExt
+	ShellCompDirectiveFilterDirs
+	ShellCompDirectiveDefault ShellCompDirective = 0
+)
+
+// Command describes a subcommand of the plugin
+type Command struct {
+	Name     string
+	Short    string
+	Commands []Command
+}
+
+// Info describes the plugin
+type Info struct {
+	Name     string
+	Version  string
+	Short    string
+	Long     string
+	Commands []Command
+}
+
+// Plugin is implemented by plugin authors
+type Plugin interface {
+	// Describe returns the metadata and commands of the plugin
+	Describe() Info
+	// Complete returns the shell completions for args, where toComplete is the argument being completed
+	Complete(args []string, toComplete string) ([]string, ShellCompDirective)
+	// Run executes the plugin with args. Output has to be written to host.Stdout() and host.Stderr().
+	// ctx is canceled, if devctl gets interrupted. Return an *ExitError to exit with a specific code.
+	Run(ctx context.Context, host *Host, args []string) error
+}
+
+// ExitError makes the plugin, and therefore devctl, exit with Code
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Serve performs the handshake with devctl and serves p until devctl interrupts the plugin.
+// It returns handshake.ErrNotLaunchedByDevctl, if the executable hasn't been launched by devctl.
+// When invoked with DescribeArg, it writes the description of p to stdout instead.
+func Serve(p Plugin) error {
+	if len(os.Args) > 1 && os.Args[1] == DescribeArg {
+		return writeDescription(os.Stdout, p)
+	}
+
+	cfg, err := handshake.FromEnv()
+	if err != nil {
+		return err
+	}
+	version, err := handshake.Negotiate(cfg.Versions, SupportedVersions)
+	if err != nil {
+		return err
+	}
+
+	addr := cfg.PluginAddr
+	if addr == "" {
+		dir, err := ioutil.TempDir("", "devctl-plugin")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		addr = filepath.Join(dir, "plugin.sock")
+	}
+	lis, err := net.Listen("unix", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	conn, err := grpc.Dial(cfg.HostAddr, grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", addr)
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to connect to devctl: %w", err)
+	}
+	defer conn.Close()
+
+	server := grpc.NewServer()
+	pluginv1.RegisterPluginServer(server, &pluginServer{plugin: p, host: pluginv1.NewHostClient(conn)})
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		server.Stop()
+	}()
+
+	fmt.Println(handshake.Line{Version: version, Network: "unix", Addr: addr})
+
+	if err := server.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+type pluginServer struct {
+	pluginv1.UnimplementedPluginServer
+	plugin Plugin
+	host   pluginv1.HostClient
+}
+
+func (s *pluginServer) Describe(context.Context, *pluginv1.DescribeRequest) (*pluginv1.DescribeResponse, error) {
+	info := s.plugin.Describe()
+	return &pluginv1.DescribeResponse{
+		Name:             info.Name,
+		Version:          info.Version,
+		ShortDescription: info.Short,
+		Description:      info.Long,
+		Commands:         toCommands(info.Commands),
+	}, nil
+}
+
+func toCommands(cmds []Command) (out []*pluginv1.Command) {
+	for _, c := range cmds {
+		out = append(out, &pluginv1.Command{Name: c.Name, ShortDescription: c.Short, Commands: toCommands(c.Commands)})
+	}
+	return out
+}
+
+func (s *pluginServer) Complete(_ context.Context, req *pluginv1.CompleteRequest) (*pluginv1.CompleteResponse, error) {
+	completions, directive := s.plugin.Complete(req.Args, req.ToComplete)
+	return &pluginv1.CompleteResponse{Completions: completions, Directive: int32(directive)}, nil
+}
+
+func (s *pluginServer) Run(req *pluginv1.RunRequest, stream pluginv1.Plugin_RunServer) error {
+	host := &Host{client: s.host, ctx: stream.Context(), stream: stream, env: req.Env}
+
+	code := 0
+	if err := s.plugin.Run(stream.Context(), host, req.Args); err != nil {
+		var exitErr *ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.Code
+		} else {
+			fmt.Fprintf(host.Stderr(), "Error: %v\n", err)
+			code = 1
+		}
+	}
+	return stream.Send(&pluginv1.RunResponse{Output: &pluginv1.RunResponse_ExitCode{ExitCode: int32(code)}})
+}
@@ -0,0 +1,118 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/traefik/yaegi/interp"
+	"gopkg.in/yaml.v3"
+)
+
+// preparedPlugin is an interpreter, which already evaluated the source of a plugin.
+// yaegi can't persist evaluated code, so prepared plugins are only cached within the process.
+type preparedPlugin struct {
+	fingerprint string
+	i           *interp.Interpreter
+}
+
+// checksVersion is increased, when the static checks change, to check the cached plugins again
+const checksVersion = 1
+
+// cacheEntry is persisted across runs to skip the static checks of unchanged plugins
+type cacheEntry struct {
+	SourceHash string `yaml:"source_hash"`
+	GopathHash string `yaml:"gopath_hash"`
+	Checks     int    `yaml:"checks"`
+}
+
+func hashBytes(b ...[]byte) string {
+	h := sha256.New()
+	for _, p := range b {
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashSources hashes the source files of a plugin together with its permissions
+func hashSources(files map[string]string, permissions []string) string {
+	h := sha256.New()
+	for _, name := range sortedNames(files) {
+		h.Write([]byte(name + "\n" + files[name] + "\n"))
+	}
+	h.Write([]byte(strings.Join(permissions, "\n")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func sortedNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gopathHash hashes the names, sizes and modification times of all files of the _gopath of the plugin
+func (e *Engine) gopathHash(rootPath string) string {
+	gopath := path.Join(rootPath, "_gopath")
+
+	var entries []string
+	_ = afero.Walk(e.cfg.Fs, gopath, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(gopath, p)
+		entries = append(entries, fmt.Sprintf("%s:%d:%d", rel, fi.Size(), fi.ModTime().UnixNano()))
+		return nil
+	})
+	sort.Strings(entries)
+
+	h := sha256.New()
+	for _, entry := range entries {
+		h.Write([]byte(entry + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (e *Engine) cacheEntryPath(name string) string {
+	return e.cfg.Pather.Cache("plugins", name+".yaml")
+}
+
+// cached returns true if the plugin has been checked in a previous run and didn't change since
+func (e *Engine) cached(name string, entry cacheEntry) bool {
+	if e.cfg.Pather == nil {
+		return false
+	}
+	b, err := afero.ReadFile(e.cfg.Fs, e.cacheEntryPath(name))
+	if err != nil {
+		return false
+	}
+	persisted := cacheEntry{}
+	if err = yaml.Unmarshal(b, &persisted); err != nil {
+		return false
+	}
+	return persisted == entry
+}
+
+// storeCacheEntry persists the entry of a checked plugin; failures only cost a check in the next run
+func (e *Engine) storeCacheEntry(name string, entry cacheEntry) {
+	if e.cfg.Pather == nil {
+		return
+	}
+	b, err := yaml.Marshal(entry)
+	if err != nil {
+		return
+	}
+	p := e.cacheEntryPath(name)
+	if err = e.cfg.Fs.MkdirAll(path.Dir(p), os.ModePerm); err != nil {
+		return
+	}
+	_ = afero.WriteFile(e.cfg.Fs, p, b, 0644)
+}
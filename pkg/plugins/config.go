@@ -2,12 +2,17 @@ package plugins
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"path"
 	template2 "text/template"
+	"time"
 
 	"github.com/spf13/afero"
 	"gopkg.in/yaml.v3"
+
+	"github.com/alex-held/devctl/pkg/env"
 )
 
 type Manifest struct {
@@ -29,6 +34,7 @@ type ConfigSpec struct {
 
 func ResolveDynamic(c ConfigSpec, e *Engine) (cfg ConfigSpec, err error) {
 	cfg = c
+	cfg.Dynamic = make(map[string]string, len(c.Dynamic))
 
 	templates := map[string]*template2.Template{}
 
@@ -40,14 +46,11 @@ func ResolveDynamic(c ConfigSpec, e *Engine) (cfg ConfigSpec, err error) {
 		return cfg, err
 	}
 
-	home, _ := os.UserHomeDir()
 	values := map[string]interface{}{
-		"DEVCTL_PATH_USERHOME": home,
-		"DEVCTL_PATH_ROOT":     e.cfg.Pather.ConfigRoot(),
-		"DEVCTL_PATH_CONFIG":   e.cfg.Pather.Config(),
-		"DEVCTL_PATH_SDK":      e.cfg.Pather.SDK(),
-		"DEVCTL_PATH_PLUGIN":   e.cfg.Pather.Plugin(),
-		"values":               c.Values,
+		"values": c.Values,
+	}
+	for k, v := range e.pathVars() {
+		values[k] = v
 	}
 
 	for key, tmpl := range templates {
@@ -61,29 +64,86 @@ func ResolveDynamic(c ConfigSpec, e *Engine) (cfg ConfigSpec, err error) {
 	return cfg, nil
 }
 
+// Map returns the config passed to the plugin, consisting of the Static values
+// overwritten by the (resolved) Dynamic values
+func (c ConfigSpec) Map() map[string]interface{} {
+	cfg := map[string]interface{}{}
+	for k, v := range c.Static {
+		cfg[k] = v
+	}
+	for k, v := range c.Dynamic {
+		cfg[k] = v
+	}
+	return cfg
+}
+
+// pathVars returns the devctl paths available to dynamic config values and fs permissions
+func (e *Engine) pathVars() map[string]string {
+	home, _ := os.UserHomeDir()
+	return env.PathVars(e.cfg.Pather, home)
+}
+
 type PluginSpec struct {
 	*CommandSpec `yaml:"cmd,inline"`
 	Name         string     `yaml:"name"`
 	Pkg          string     `yaml:"pkg"`
 	Config       ConfigSpec `yaml:"config,omitempty"`
+	// Permissions declare the capabilities of the plugin, e.g. 'fs:read:$DEVCTL_ROOT', 'net' or 'exec:git'
+	Permissions []string `yaml:"permissions,omitempty"`
+	// Timeout is the default execution timeout of the plugin, e.g. '30s'
+	Timeout string `yaml:"timeout,omitempty"`
 }
 
 func (e *Engine) LoadPlugin(manifestPath string) (p *Plugin, err error) {
+	start := time.Now()
+
 	m, err := e.loadManifest(manifestPath)
 	if err != nil {
 		return nil, err
 	}
 
 	rootPath := path.Dir(manifestPath)
-	b, err := afero.ReadFile(e.cfg.Fs, path.Join(rootPath, "main.go"))
+	files, err := e.loadSources(rootPath)
 	if err != nil {
 		return nil, err
 	}
 
+	perms, err := ParsePermissions(m.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := cacheEntry{
+		SourceHash: hashSources(files, m.Permissions),
+		GopathHash: e.gopathHash(rootPath),
+		Checks:     checksVersion,
+	}
+	cached := e.cached(m.Name, entry)
+	if !cached {
+		for _, name := range sortedNames(files) {
+			if err = perms.Check(files[name]); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		e.storeCacheEntry(m.Name, entry)
+	}
+
+	var timeout time.Duration
+	if m.Timeout != "" {
+		if timeout, err = time.ParseDuration(m.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+	}
+
 	p = &Plugin{
-		Manifest: m,
-		Source:   string(b),
-		RootPath: rootPath,
+		Manifest:    m,
+		Files:       files,
+		RootPath:    rootPath,
+		Permissions: perms,
+		Timeout:     timeout,
+		LoadTime:    time.Since(start),
+		Cached:      cached,
+		fingerprint: entry.SourceHash + entry.GopathHash,
 	}
 
 	e.pluginCache[p.Cmd] = p
@@ -101,29 +161,66 @@ func (e *Engine) loadManifest(manifestPath string) (m *Manifest, err error) {
 	if err != nil {
 		return nil, err
 	}
+	if m.CommandSpec == nil || m.Cmd == "" {
+		return nil, ErrNoCommand
+	}
 
 	return m, nil
 }
 
+var ErrNoCommand = errors.New("the plugin manifest does not declare a cmd")
+
 type Plugins []*Plugin
 
+// LoadError describes why the plugin at Path could not be loaded
+type LoadError struct {
+	Path string
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("failed to load plugin '%s': %v", e.Path, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// LoadPlugins loads all plugins found in the plugin directory.
+// Plugins which fail to load are skipped and reported by LoadErrors.
 func (e *Engine) LoadPlugins() (plugins Plugins) {
+	e.loadErrors = nil
+
 	pluginsRoot := e.cfg.Pather.Plugin()
 	fis, err := afero.ReadDir(e.cfg.Fs, pluginsRoot)
 	if err != nil {
+		if !os.IsNotExist(err) {
+			e.loadErrors = append(e.loadErrors, &LoadError{Path: pluginsRoot, Err: err})
+		}
 		return plugins
 	}
 	for _, fi := range fis {
+		// plugins installed from an index are linked into the plugin directory
+		if fi.Mode()&os.ModeSymlink != 0 {
+			target, err := e.cfg.Fs.Stat(path.Join(pluginsRoot, fi.Name()))
+			if err != nil {
+				e.loadErrors = append(e.loadErrors, &LoadError{Path: path.Join(pluginsRoot, fi.Name()), Err: err})
+				continue
+			}
+			fi = target
+		}
 		if !fi.IsDir() {
 			continue
 		}
 
 		manifestPath := path.Join(pluginsRoot, fi.Name(), "plugin.yaml")
 		if _, err = e.cfg.Fs.Stat(manifestPath); err != nil {
+			e.loadErrors = append(e.loadErrors, &LoadError{Path: path.Join(pluginsRoot, fi.Name()), Err: err})
 			continue
 		}
 		p, err := e.LoadPlugin(manifestPath)
 		if err != nil {
+			e.loadErrors = append(e.loadErrors, &LoadError{Path: manifestPath, Err: err})
 			continue
 		}
 		plugins = append(plugins, p)
@@ -131,3 +228,8 @@ func (e *Engine) LoadPlugins() (plugins Plugins) {
 
 	return plugins
 }
+
+// LoadErrors returns the errors of the last call to LoadPlugins
+func (e *Engine) LoadErrors() []*LoadError {
+	return e.loadErrors
+}
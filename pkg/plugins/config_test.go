@@ -13,7 +13,7 @@ import (
 )
 
 func TestLoadManifests(t *testing.T) {
-	testFs := afero.NewBasePathFs(afero.NewOsFs(), path.Join("testdata", "test-load-manifests-fs"))
+	testFs := afero.NewCopyOnWriteFs(afero.NewBasePathFs(afero.NewOsFs(), path.Join("testdata", "test-load-manifests-fs")), afero.NewMemMapFs())
 	_, err := testFs.Stat("/home/user/.devctl/plugins/plugin1/plugin.yaml")
 	if err != nil {
 		t.Fatalf("/home/user/.devctl/plugins/plugin1/plugin.yaml does not exists!")
@@ -102,7 +102,7 @@ func TestMarshalManifest(t *testing.T) {
 }
 
 func TestUnmarshalManifest(t *testing.T) {
-	testFs := afero.NewBasePathFs(afero.NewOsFs(), path.Join("testdata", "test-load-manifests-fs"))
+	testFs := afero.NewCopyOnWriteFs(afero.NewBasePathFs(afero.NewOsFs(), path.Join("testdata", "test-load-manifests-fs")), afero.NewMemMapFs())
 	_, err := testFs.Stat("/home/user/.devctl/plugins/plugin1/plugin.yaml")
 	if err != nil {
 		t.Fatalf("/home/user/.devctl/plugins/plugin1/plugin.yaml does not exists!")
@@ -121,3 +121,36 @@ func TestUnmarshalManifest(t *testing.T) {
 	assert.Equal(t, manifests[0].Name, "plugin1")
 	assert.Equal(t, manifests[1].Name, "plugin2")
 }
+
+func TestLoadErrors(t *testing.T) {
+	testFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(testFs, "/home/user/.devctl/plugins/valid/plugin.yaml", []byte("plugin:\n  name: valid\n  cmd: valid\n"), 0644)
+	_ = afero.WriteFile(testFs, "/home/user/.devctl/plugins/valid/main.go", []byte("package valid\n"), 0644)
+	_ = afero.WriteFile(testFs, "/home/user/.devctl/plugins/no-cmd/plugin.yaml", []byte("plugin:\n  name: no-cmd\n"), 0644)
+	_ = testFs.MkdirAll("/home/user/.devctl/plugins/no-manifest", 0755)
+
+	sut := NewEngine(func(c *Config) *Config {
+		c.Pather = devctlpath.NewPather(devctlpath.WithConfigRootFn(func() string {
+			return "/home/user/.devctl"
+		}))
+		c.Fs = testFs
+		return c
+	})
+
+	plugins := sut.LoadPlugins()
+	assert.Len(t, plugins, 1)
+	assert.Equal(t, "valid", plugins[0].Name)
+
+	loadErrs := sut.LoadErrors()
+	assert.Len(t, loadErrs, 2)
+	assert.ErrorIs(t, loadErrs[0], ErrNoCommand)
+	assert.Equal(t, "/home/user/.devctl/plugins/no-manifest", loadErrs[1].Path)
+}
+
+func TestConfigSpec_Map(t *testing.T) {
+	c := ConfigSpec{
+		Static:  map[string]interface{}{"a": 1, "b": "static"},
+		Dynamic: map[string]string{"b": "dynamic"},
+	}
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": "dynamic"}, c.Map())
+}
@@ -2,19 +2,18 @@ package plugins
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"reflect"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/afero"
 	"github.com/traefik/yaegi/interp"
-	"github.com/traefik/yaegi/stdlib"
-	"github.com/traefik/yaegi/stdlib/syscall"
-	"github.com/traefik/yaegi/stdlib/unrestricted"
-	"github.com/traefik/yaegi/stdlib/unsafe"
+	"k8s.io/klog/v2"
 
 	"github.com/alex-held/devctl-kit/pkg/devctlpath"
 	"github.com/alex-held/devctl-kit/pkg/plugins"
@@ -23,6 +22,8 @@ import (
 type Engine struct {
 	cfg         *Config
 	pluginCache map[string]*Plugin
+	loadErrors  []*LoadError
+	prepared    map[string]*preparedPlugin
 }
 
 type Config struct {
@@ -41,6 +42,7 @@ func NewEngine(opts ...Option) *Engine {
 			Pather: devctlpath.DefaultPather(),
 		},
 		pluginCache: map[string]*Plugin{},
+		prepared:    map[string]*preparedPlugin{},
 	}
 	for _, opt := range opts {
 		opt(e.cfg)
@@ -50,8 +52,20 @@ func NewEngine(opts ...Option) *Engine {
 
 type Plugin struct {
 	*Manifest
-	Source   string
-	RootPath string
+	// Files contains the go files of the plugin package by name, with resolved //go:embed directives
+	Files       map[string]string
+	RootPath    string
+	Permissions Permissions
+	Timeout     time.Duration
+
+	// LoadTime is the time it took to load the plugin
+	LoadTime time.Duration
+	// Cached is set, if the static checks of the plugin were skipped, because it did not change since
+	// it has been checked in a previous run. The source is still evaluated once per process.
+	Cached bool
+
+	// fingerprint identifies the source, permissions and dependencies of the plugin
+	fingerprint string
 }
 
 var ErrNoPluginWithNameFound = fmt.Errorf("no plugin with that name could be found in the pluginCache")
@@ -65,21 +79,7 @@ func (e *Engine) Execute(pluginName string, args []string) (err error) {
 }
 
 func (e *Engine) execute(p *Plugin, args []string) (err error) {
-	i := interp.New(interp.Options{
-		GoPath: path.Join(p.RootPath, "_gopath"),
-		Stdout: e.cfg.Out,
-		Stderr: e.cfg.Out,
-	})
-
-	// imports
-	i.Use(interp.Symbols)
-	i.Use(syscall.Symbols)
-	i.Use(unsafe.Symbols)
-	i.Use(unrestricted.Symbols)
-	i.Use(stdlib.Symbols)
-
-	// load plugin code
-	_, err = i.Eval(p.Source)
+	i, err := e.take(context.Background(), p)
 	if err != nil {
 		return err
 	}
@@ -105,63 +105,176 @@ func (e *Engine) execute(p *Plugin, args []string) (err error) {
 	return newFn(cfg, args)
 }
 
-func (execP *ExecutablePlugin) Exec(args []string) (err error) {
-	execArgs := []reflect.Value{execP.Config, reflect.ValueOf(args)}
-	result := execP.ExecFn.Call(execArgs)
+// prepare returns an interpreter, which evaluated the source of p.
+// Interpreters are reused by subsequent calls for the unchanged plugin, until take hands them to an execution.
+// The evaluation is stopped, when ctx is done.
+func (e *Engine) prepare(ctx context.Context, p *Plugin) (i *interp.Interpreter, err error) {
+	if cached, ok := e.prepared[p.RootPath]; ok && p.fingerprint != "" && cached.fingerprint == p.fingerprint {
+		klog.V(2).Infof("reusing interpreter of plugin %s", p.Name)
+		return cached.i, nil
+	}
+
+	start := time.Now()
+
+	// load plugin package, resolving its imports from _gopath/src
+	src := e.newSourceFS(p)
+	i = e.newInterpreter(p, src)
+	if _, err = i.EvalWithContext(ctx, fmt.Sprintf("import %q", src.importPath())); err != nil {
+		return nil, p.Permissions.explainEvalError(err)
+	}
+	klog.V(2).Infof("evaluated plugin %s in %s", p.Name, time.Since(start))
 
-	return result[0].Interface().(error)
+	e.prepared[p.RootPath] = &preparedPlugin{fingerprint: p.fingerprint, i: i}
+	return i, nil
 }
 
-//goland:noinspection GoUnhandledErrorResult
-func (e *Engine) NewExecutablePlugin(p *Plugin, config map[string]interface{}) (execP *ExecutablePlugin, err error) {
+// take returns the prepared interpreter of p and removes it from the engine.
+// Executions change the package level state of the plugin, so an interpreter is never executed twice.
+func (e *Engine) take(ctx context.Context, p *Plugin) (i *interp.Interpreter, err error) {
+	if i, err = e.prepare(ctx, p); err != nil {
+		return nil, err
+	}
+	delete(e.prepared, p.RootPath)
+	return i, nil
+}
+
+// newInterpreter creates the interpreter for p, which only exports the symbols granted by the permissions of p
+func (e *Engine) newInterpreter(p *Plugin, src *sourceFS) *interp.Interpreter {
 	i := interp.New(interp.Options{
-		GoPath: path.Join(p.RootPath, "_gopath"),
-		Stdout: e.cfg.Out,
-		Stderr: e.cfg.Out,
+		GoPath:               path.Join(src.pkgDir, "_gopath"),
+		Stdout:               e.cfg.Out,
+		Stderr:               e.cfg.Out,
+		SourcecodeFilesystem: src,
 	})
 
 	// imports
 	i.Use(interp.Symbols)
-	i.Use(syscall.Symbols)
-	i.Use(unsafe.Symbols)
-	i.Use(unrestricted.Symbols)
-	i.Use(stdlib.Symbols)
+	i.Use(p.Permissions.Symbols())
+	return i
+}
+
+// TimeoutError is returned by ExecutablePlugin.Exec and NewExecutablePluginContext,
+// if the plugin did not return or load within its timeout
+type TimeoutError struct {
+	Plugin  string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("plugin %s timed out after %s", e.Plugin, e.Timeout)
+}
 
-	// load plugin code
-	_, err = i.Eval(p.Source)
+// Exec executes the plugin with args. An ExecutablePlugin is executed once, its context is canceled when Exec returns.
+// The plugin gets abandoned, if it does not return before its Timeout elapses or the context gets canceled.
+func (execP *ExecutablePlugin) Exec(args []string) (err error) {
+	defer execP.cancel()
+	if err = execP.ctx.Err(); err != nil {
+		return fmt.Errorf("plugin %s was canceled: %w", execP.Name, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("plugin %s panicked: %v", execP.Name, r)
+			}
+		}()
+
+		execArgs := []reflect.Value{execP.Config, reflect.ValueOf(args)}
+		result := execP.ExecFn.Call(execArgs)
+
+		if err, ok := result[0].Interface().(error); ok {
+			done <- err
+			return
+		}
+		done <- nil
+	}()
+
+	var deadline <-chan time.Time
+	if execP.Timeout > 0 {
+		timer := time.NewTimer(execP.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	select {
+	case err = <-done:
+		return err
+	case <-deadline:
+		// let the abandoned plugin know it should stop
+		execP.cancel()
+		return &TimeoutError{Plugin: execP.Name, Timeout: execP.Timeout}
+	case <-execP.ctx.Done():
+		return fmt.Errorf("plugin %s was canceled: %w", execP.Name, execP.ctx.Err())
+	}
+}
+
+// NewExecutablePlugin loads the plugin p and decodes config into the config of the plugin
+func (e *Engine) NewExecutablePlugin(p *Plugin, config map[string]interface{}) (execP *ExecutablePlugin, err error) {
+	return e.NewExecutablePluginContext(context.Background(), p, config)
+}
+
+// NewExecutablePluginContext loads the plugin p and decodes config into the config of the plugin.
+// ctx is passed to the plugin using plugins.Context and cancels the execution of the plugin.
+// Loading the plugin is limited to the Timeout of p as well.
+//
+//goland:noinspection GoUnhandledErrorResult
+func (e *Engine) NewExecutablePluginContext(ctx context.Context, p *Plugin, config map[string]interface{}) (execP *ExecutablePlugin, err error) {
+	loadCtx, cancelLoad := ctx, context.CancelFunc(func() {})
+	if p.Timeout > 0 {
+		loadCtx, cancelLoad = context.WithTimeout(ctx, p.Timeout)
+	}
+	defer cancelLoad()
+	loadErr := func(err error) error {
+		if errors.Is(loadCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return &TimeoutError{Plugin: p.Name, Timeout: p.Timeout}
+		}
+		return err
+	}
+
+	i, err := e.take(loadCtx, p)
 	if err != nil {
-		return nil, err
+		return nil, loadErr(err)
 	}
 
-	vConfig, err := i.Eval(p.Pkg + `.CreateConfig()`)
+	vConfig, err := i.EvalWithContext(loadCtx, p.Pkg+`.CreateConfig()`)
 	if err != nil {
-		return nil, fmt.Errorf("failed to eval CreateConfig: %w", err)
+		return nil, loadErr(fmt.Errorf("failed to eval CreateConfig: %w", err))
 	}
 
-	fnExec, err := i.Eval(p.Pkg + `.Exec`)
+	fnExec, err := i.EvalWithContext(loadCtx, p.Pkg+`.Exec`)
 	if err != nil {
-		return nil, fmt.Errorf("failed to eval Exec: %w", err)
+		return nil, loadErr(fmt.Errorf("failed to eval Exec: %w", err))
 	}
 
-	if err = e.decodeConfig(vConfig, config); err != nil {
+	ctx, cancel := context.WithCancel(ctx)
+	if err = e.decodeConfig(ctx, p, vConfig, config); err != nil {
+		cancel()
 		return nil, err
 	}
 
 	execP = &ExecutablePlugin{
-		Plugin: p,
-		Config: vConfig,
-		ExecFn: fnExec,
+		Plugin:  p,
+		Config:  vConfig,
+		ExecFn:  fnExec,
+		Timeout: p.Timeout,
+		ctx:     ctx,
+		cancel:  cancel,
 	}
 
 	return execP, nil
 }
 
-func (e *Engine) decodeConfig(vConfig reflect.Value, cfg map[string]interface{}) (err error) {
+func (e *Engine) decodeConfig(ctx context.Context, p *Plugin, vConfig reflect.Value, cfg map[string]interface{}) (err error) {
 	cfg["Context"] = &plugins.Context{
 		Out:     e.cfg.Out,
 		Pather:  e.cfg.Pather,
-		Context: context.Background(),
+		Context: ctx,
 	}
+	// file system access is restricted to the paths declared in the manifest
+	cfg["Fs"] = p.Permissions.Fs(e.cfg.Fs, e.pathVars())
+	// the files of the plugin directory, e.g. templates, are readable without permissions
+	cfg["Files"] = afero.NewIOFS(afero.NewReadOnlyFs(afero.NewBasePathFs(e.cfg.Fs, p.RootPath)))
 	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
 		DecodeHook:       mapstructure.StringToSliceHookFunc(","),
 		WeaklyTypedInput: true,
@@ -178,4 +291,10 @@ type ExecutablePlugin struct {
 	*Plugin
 	Config reflect.Value
 	ExecFn reflect.Value
+
+	// Timeout limits the execution time of the plugin; it defaults to the timeout declared in the manifest
+	Timeout time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
 }
@@ -2,11 +2,14 @@ package plugins
 
 import (
 	"bytes"
+	"context"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/alex-held/devctl-kit/pkg/devctlpath"
 )
@@ -48,7 +51,7 @@ func TestExecPlugin(t *testing.T) {
 
 	args := []string{"use", "1.16.8"}
 	execP, err := sut.NewExecutablePlugin(p, cfg)
-	assert.NoError(t, err)
+	require.NoError(t, err)
 
 	result := execP.Exec(args)
 
@@ -57,3 +60,176 @@ func TestExecPlugin(t *testing.T) {
 
 	t.Log(out.String())
 }
+
+func TestExecutablePlugin_Timeout(t *testing.T) {
+	testFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(testFs, "/plugins/sleep/plugin.yaml", []byte("plugin:\n  name: sleep\n  pkg: sleep\n  cmd: sleep\n  timeout: 50ms\n"), 0644)
+	_ = afero.WriteFile(testFs, "/plugins/sleep/main.go", []byte(`package sleep
+
+import "time"
+
+func CreateConfig() *struct{} { return &struct{}{} }
+
+func Exec(cfg *struct{}, args []string) error {
+	time.Sleep(time.Hour)
+	return nil
+}
+`), 0644)
+
+	sut := NewEngine(func(c *Config) *Config {
+		c.Fs = testFs
+		c.Pather = devctlpath.NewPather(devctlpath.WithConfigRootFn(func() string {
+			return "/home/user/.devctl"
+		}))
+		return c
+	})
+
+	p, err := sut.LoadPlugin("/plugins/sleep/plugin.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, 50*time.Millisecond, p.Timeout)
+
+	execP, err := sut.NewExecutablePlugin(p, map[string]interface{}{})
+	assert.NoError(t, err)
+
+	err = execP.Exec(nil)
+	var timeoutErr *TimeoutError
+	assert.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, "plugin sleep timed out after 50ms", err.Error())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	execP, err = sut.NewExecutablePluginContext(ctx, p, map[string]interface{}{})
+	assert.NoError(t, err)
+	execP.Timeout = 0
+
+	cancel()
+	err = execP.Exec(nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestExecutablePlugin_Cancel(t *testing.T) {
+	testFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(testFs, "/plugins/p/plugin.yaml", []byte("plugin:\n  name: p\n  pkg: p\n  cmd: p\n  timeout: 50ms\n"), 0644)
+	_ = afero.WriteFile(testFs, "/plugins/p/main.go", []byte(`package p
+
+import "time"
+
+var _ = slow()
+
+func slow() int {
+	time.Sleep(time.Hour)
+	return 0
+}
+
+func CreateConfig() *struct{} { return &struct{}{} }
+
+func Exec(cfg *struct{}, args []string) error { return nil }
+`), 0644)
+	_ = afero.WriteFile(testFs, "/plugins/q/plugin.yaml", []byte("plugin:\n  name: q\n  pkg: q\n  cmd: q\n"), 0644)
+	_ = afero.WriteFile(testFs, "/plugins/q/main.go", []byte(`package q
+
+func CreateConfig() *struct{} { return &struct{}{} }
+
+func Exec(cfg *struct{}, args []string) error { return nil }
+`), 0644)
+
+	sut := NewEngine(func(c *Config) *Config {
+		c.Fs = testFs
+		c.Pather = devctlpath.NewPather(devctlpath.WithConfigRootFn(func() string {
+			return "/home/user/.devctl"
+		}))
+		return c
+	})
+
+	// the timeout covers evaluating the source of the plugin
+	p, err := sut.LoadPlugin("/plugins/p/plugin.yaml")
+	assert.NoError(t, err)
+	start := time.Now()
+	_, err = sut.NewExecutablePlugin(p, map[string]interface{}{})
+	var timeoutErr *TimeoutError
+	assert.ErrorAs(t, err, &timeoutErr)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	// the context of the plugin is canceled when it returns
+	q, err := sut.LoadPlugin("/plugins/q/plugin.yaml")
+	assert.NoError(t, err)
+	execP, err := sut.NewExecutablePlugin(q, map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.NoError(t, execP.Exec(nil))
+	assert.ErrorIs(t, execP.ctx.Err(), context.Canceled)
+	assert.Error(t, execP.Exec(nil), "a plugin is executed once")
+}
+
+func TestEngine_Prepare(t *testing.T) {
+	testFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(testFs, "/plugins/p/plugin.yaml", []byte("plugin:\n  name: p\n  pkg: p\n  cmd: p\n"), 0644)
+	_ = afero.WriteFile(testFs, "/plugins/p/main.go", []byte("package p\n\nvar Version = \"v1\"\n"), 0644)
+
+	sut := NewEngine(func(c *Config) *Config {
+		c.Fs = testFs
+		c.Pather = devctlpath.NewPather(devctlpath.WithConfigRootFn(func() string {
+			return "/home/user/.devctl"
+		}))
+		return c
+	})
+
+	p, err := sut.LoadPlugin("/plugins/p/plugin.yaml")
+	assert.NoError(t, err)
+	first, err := sut.prepare(context.Background(), p)
+	assert.NoError(t, err)
+
+	// unchanged plugins reuse the interpreter and are not checked again
+	p, err = sut.LoadPlugin("/plugins/p/plugin.yaml")
+	assert.NoError(t, err)
+	second, err := sut.prepare(context.Background(), p)
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.True(t, sut.cached("p", cacheEntry{SourceHash: p.fingerprint[:64], GopathHash: p.fingerprint[64:], Checks: checksVersion}))
+
+	_ = afero.WriteFile(testFs, "/plugins/p/main.go", []byte("package p\n\nvar Version = \"v2\"\n"), 0644)
+	p, err = sut.LoadPlugin("/plugins/p/plugin.yaml")
+	assert.NoError(t, err)
+	third, err := sut.prepare(context.Background(), p)
+	assert.NoError(t, err)
+	assert.NotSame(t, first, third)
+
+	v, err := third.Eval("p.Version")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", v.String())
+}
+
+func TestEngine_PackageStateIsNotShared(t *testing.T) {
+	testFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(testFs, "/plugins/p/plugin.yaml", []byte("plugin:\n  name: p\n  pkg: p\n  cmd: p\n"), 0644)
+	_ = afero.WriteFile(testFs, "/plugins/p/main.go", []byte(`package p
+
+import "fmt"
+
+var calls int
+
+func CreateConfig() *struct{} { return &struct{}{} }
+
+func Exec(cfg *struct{}, args []string) error {
+	calls++
+	if calls > 1 {
+		return fmt.Errorf("executed %d times", calls)
+	}
+	return nil
+}
+`), 0644)
+
+	sut := NewEngine(func(c *Config) *Config {
+		c.Fs = testFs
+		c.Pather = devctlpath.NewPather(devctlpath.WithConfigRootFn(func() string {
+			return "/home/user/.devctl"
+		}))
+		return c
+	})
+	p, err := sut.LoadPlugin("/plugins/p/plugin.yaml")
+	require.NoError(t, err)
+
+	for n := 0; n < 2; n++ {
+		execP, err := sut.NewExecutablePlugin(p, map[string]interface{}{})
+		require.NoError(t, err)
+		assert.NoError(t, execP.Exec(nil), "execution %d", n+1)
+	}
+}
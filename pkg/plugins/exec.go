@@ -0,0 +1,216 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+)
+
+// Cmd replaces exec.Cmd in plugins, which only declare the commands they execute using 'exec:<command>'.
+// The path of the command is resolved and checked when it is created by Command or CommandContext,
+// and can't be changed afterwards.
+type Cmd struct {
+	Args   []string
+	Env    []string
+	Dir    string
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	Process      *os.Process
+	ProcessState *os.ProcessState
+
+	cmd *exec.Cmd
+	err error
+}
+
+func (c *Cmd) prepare() error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.cmd == nil {
+		return errors.New("exec: Cmd has to be created using exec.Command or exec.CommandContext")
+	}
+	c.cmd.Args, c.cmd.Env, c.cmd.Dir = c.Args, c.Env, c.Dir
+	if c.Stdin != nil {
+		c.cmd.Stdin = c.Stdin
+	}
+	if c.Stdout != nil {
+		c.cmd.Stdout = c.Stdout
+	}
+	if c.Stderr != nil {
+		c.cmd.Stderr = c.Stderr
+	}
+	return nil
+}
+
+func (c *Cmd) done(err error) error {
+	c.Process, c.ProcessState = c.cmd.Process, c.cmd.ProcessState
+	return err
+}
+
+func (c *Cmd) Start() error {
+	if err := c.prepare(); err != nil {
+		return err
+	}
+	return c.done(c.cmd.Start())
+}
+
+func (c *Cmd) Wait() error {
+	if c.cmd == nil {
+		return errors.New("exec: not started")
+	}
+	return c.done(c.cmd.Wait())
+}
+
+func (c *Cmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+func (c *Cmd) Output() ([]byte, error) {
+	if err := c.prepare(); err != nil {
+		return nil, err
+	}
+	out, err := c.cmd.Output()
+	return out, c.done(err)
+}
+
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	if err := c.prepare(); err != nil {
+		return nil, err
+	}
+	out, err := c.cmd.CombinedOutput()
+	return out, c.done(err)
+}
+
+func (c *Cmd) StdinPipe() (io.WriteCloser, error) {
+	if err := c.prepare(); err != nil {
+		return nil, err
+	}
+	return c.cmd.StdinPipe()
+}
+
+func (c *Cmd) StdoutPipe() (io.ReadCloser, error) {
+	if err := c.prepare(); err != nil {
+		return nil, err
+	}
+	return c.cmd.StdoutPipe()
+}
+
+func (c *Cmd) StderrPipe() (io.ReadCloser, error) {
+	if err := c.prepare(); err != nil {
+		return nil, err
+	}
+	return c.cmd.StderrPipe()
+}
+
+func (c *Cmd) String() string {
+	if c.cmd == nil {
+		return fmt.Sprint(c.Args)
+	}
+	return c.cmd.String()
+}
+
+// allowsAnyCommand returns true if executing any command has been declared using 'exec'
+func (perms Permissions) allowsAnyCommand() bool {
+	for _, p := range perms {
+		if p.Capability == CapabilityExec && p.Arg == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// lookPath resolves the command name like exec.LookPath and returns an error, unless the resolved path
+// is the path a declared 'exec:<command>' resolves to. Comparing the paths instead of the names
+// rejects binaries with the same name in other directories.
+func (perms Permissions) lookPath(name string) (string, error) {
+	notPermitted := &exec.Error{Name: name, Err: fmt.Errorf("%w: exec:%s", ErrUndeclaredPermission, filepath.Base(name))}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		for _, p := range perms {
+			if p.Capability == CapabilityExec && (p.Arg == "" || filepath.Base(p.Arg) == filepath.Base(name)) {
+				return "", err
+			}
+		}
+		return "", notPermitted
+	}
+	if perms.allowsAnyCommand() {
+		return path, nil
+	}
+
+	resolved := evalSymlinks(path)
+	for _, p := range perms {
+		if p.Capability != CapabilityExec {
+			continue
+		}
+		if declared, err := exec.LookPath(p.Arg); err == nil && evalSymlinks(declared) == resolved {
+			return path, nil
+		}
+	}
+	return "", notPermitted
+}
+
+func evalSymlinks(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
+
+// execSymbols returns the os/exec symbols, restricted to the declared commands.
+// Plugins declaring 'exec' get the unrestricted exec.Cmd.
+func (perms Permissions) execSymbols() map[string]reflect.Value {
+	symbols := map[string]reflect.Value{
+		"Command":        reflect.ValueOf(exec.Command),
+		"CommandContext": reflect.ValueOf(exec.CommandContext),
+		"LookPath":       reflect.ValueOf(exec.LookPath),
+		"ErrNotFound":    reflect.ValueOf(&exec.ErrNotFound).Elem(),
+		"Cmd":            reflect.ValueOf((*exec.Cmd)(nil)),
+		"Error":          reflect.ValueOf((*exec.Error)(nil)),
+		"ExitError":      reflect.ValueOf((*exec.ExitError)(nil)),
+	}
+	if perms.allowsAnyCommand() {
+		return symbols
+	}
+
+	command := func(ctx context.Context, name string, args ...string) *Cmd {
+		c := &Cmd{Args: append([]string{name}, args...)}
+		path, err := perms.lookPath(name)
+		if err != nil {
+			c.err = err
+			return c
+		}
+		if ctx != nil {
+			c.cmd = exec.CommandContext(ctx, path)
+		} else {
+			c.cmd = exec.Command(path)
+		}
+		return c
+	}
+
+	symbols["Command"] = reflect.ValueOf(func(name string, args ...string) *Cmd {
+		return command(nil, name, args...)
+	})
+	symbols["CommandContext"] = reflect.ValueOf(func(ctx context.Context, name string, args ...string) *Cmd {
+		if ctx == nil {
+			panic("nil Context")
+		}
+		return command(ctx, name, args...)
+	})
+	symbols["LookPath"] = reflect.ValueOf(perms.lookPath)
+	symbols["Cmd"] = reflect.ValueOf((*Cmd)(nil))
+	return symbols
+}
@@ -0,0 +1,237 @@
+package plugins
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// sandboxFs restricts the access to the wrapped afero.Fs to the paths declared using fs permissions
+type sandboxFs struct {
+	fs    afero.Fs
+	read  []string
+	write []string
+}
+
+// Fs returns the afero.Fs passed to the plugin, which restricts base to the declared fs permissions.
+// vars are used to expand variables like $DEVCTL_ROOT in the declared paths.
+func (perms Permissions) Fs(base afero.Fs, vars map[string]string) afero.Fs {
+	s := &sandboxFs{fs: base}
+	for _, p := range perms {
+		if p.Capability != CapabilityFs {
+			continue
+		}
+		path := expandPath(p.Arg, vars)
+		if resolved, err := s.resolve(path); err == nil {
+			path = resolved
+		}
+		s.read = append(s.read, path)
+		if p.Write {
+			s.write = append(s.write, path)
+		}
+	}
+	return s
+}
+
+// permissionError is returned for file system access outside of the declared paths
+type permissionError struct {
+	permission string
+}
+
+func (e *permissionError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrUndeclaredPermission, e.permission)
+}
+
+func (e *permissionError) Is(target error) bool {
+	return target == ErrUndeclaredPermission || target == fs.ErrPermission
+}
+
+func within(path string, roots []string) bool {
+	for _, root := range roots {
+		if path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxSymlinks limits the symlinks followed by resolve, like the limit of the kernel
+const maxSymlinks = 40
+
+// resolve returns the absolute path name refers to, with the symlinks of the wrapped fs resolved
+func (s *sandboxFs) resolve(name string) (string, error) {
+	return resolveSymlinks(s.fs, name)
+}
+
+// resolveSymlinks returns the absolute path name refers to, with the symlinks of fsys resolved.
+// Components are resolved one after the other, so that '..' refers to the parent of a symlink target
+// like it does for the kernel. The missing part of a path, which doesn't exist yet, is appended unresolved.
+func resolveSymlinks(fsys afero.Fs, name string) (string, error) {
+	if !filepath.IsAbs(name) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		name = wd + string(filepath.Separator) + name
+	}
+
+	lstater, ok := fsys.(afero.Lstater)
+	reader, canRead := fsys.(afero.LinkReader)
+	if !ok || !canRead {
+		return filepath.Clean(name), nil
+	}
+
+	vol := filepath.VolumeName(name)
+	resolved, rest := vol+string(filepath.Separator), strings.Split(name[len(vol):], string(filepath.Separator))
+	for links := 0; len(rest) > 0; {
+		part := rest[0]
+		rest = rest[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		fi, _, err := lstater.LstatIfPossible(next)
+		if os.IsNotExist(err) {
+			return filepath.Join(append([]string{next}, rest...)...), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links: %s", name)
+		}
+		target, err := reader.ReadlinkIfPossible(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			vol := filepath.VolumeName(target)
+			target, resolved = target[len(vol):], vol+string(filepath.Separator)
+		}
+		rest = append(strings.Split(target, string(filepath.Separator)), rest...)
+	}
+	return resolved, nil
+}
+
+func (s *sandboxFs) check(op, name string, write bool) error {
+	path, err := s.resolve(name)
+	if err != nil {
+		return &os.PathError{Op: op, Path: name, Err: err}
+	}
+
+	perm, roots := "fs:read", s.read
+	if write {
+		perm, roots = "fs:write", s.write
+	}
+	if !within(path, roots) {
+		return &os.PathError{Op: op, Path: name, Err: &permissionError{permission: perm + ":" + path}}
+	}
+	return nil
+}
+
+func (s *sandboxFs) Create(name string) (afero.File, error) {
+	if err := s.check("create", name, true); err != nil {
+		return nil, err
+	}
+	return s.fs.Create(name)
+}
+
+func (s *sandboxFs) Mkdir(name string, perm os.FileMode) error {
+	if err := s.check("mkdir", name, true); err != nil {
+		return err
+	}
+	return s.fs.Mkdir(name, perm)
+}
+
+func (s *sandboxFs) MkdirAll(path string, perm os.FileMode) error {
+	if err := s.check("mkdir", path, true); err != nil {
+		return err
+	}
+	return s.fs.MkdirAll(path, perm)
+}
+
+func (s *sandboxFs) Open(name string) (afero.File, error) {
+	if err := s.check("open", name, false); err != nil {
+		return nil, err
+	}
+	return s.fs.Open(name)
+}
+
+func (s *sandboxFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0
+	if err := s.check("open", name, write); err != nil {
+		return nil, err
+	}
+	return s.fs.OpenFile(name, flag, perm)
+}
+
+func (s *sandboxFs) Remove(name string) error {
+	if err := s.check("remove", name, true); err != nil {
+		return err
+	}
+	return s.fs.Remove(name)
+}
+
+func (s *sandboxFs) RemoveAll(path string) error {
+	if err := s.check("remove", path, true); err != nil {
+		return err
+	}
+	return s.fs.RemoveAll(path)
+}
+
+func (s *sandboxFs) Rename(oldname, newname string) error {
+	if err := s.check("rename", oldname, true); err != nil {
+		return err
+	}
+	if err := s.check("rename", newname, true); err != nil {
+		return err
+	}
+	return s.fs.Rename(oldname, newname)
+}
+
+func (s *sandboxFs) Stat(name string) (os.FileInfo, error) {
+	if err := s.check("stat", name, false); err != nil {
+		return nil, err
+	}
+	return s.fs.Stat(name)
+}
+
+func (s *sandboxFs) Name() string {
+	return "sandboxFs"
+}
+
+func (s *sandboxFs) Chmod(name string, mode os.FileMode) error {
+	if err := s.check("chmod", name, true); err != nil {
+		return err
+	}
+	return s.fs.Chmod(name, mode)
+}
+
+func (s *sandboxFs) Chown(name string, uid, gid int) error {
+	if err := s.check("chown", name, true); err != nil {
+		return err
+	}
+	return s.fs.Chown(name, uid, gid)
+}
+
+func (s *sandboxFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := s.check("chtimes", name, true); err != nil {
+		return err
+	}
+	return s.fs.Chtimes(name, atime, mtime)
+}
@@ -0,0 +1,404 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+	"github.com/traefik/yaegi/stdlib/syscall"
+	"github.com/traefik/yaegi/stdlib/unsafe"
+)
+
+// Capability is a class of operations a plugin has to declare in the permissions of its manifest
+type Capability string
+
+const (
+	// CapabilityFs grants access to the file system through the Fs passed to the plugin.
+	// Declared as 'fs:read:<path>' or 'fs:write:<path>'.
+	CapabilityFs Capability = "fs"
+	// CapabilityNet grants access to the net packages. Declared as 'net'.
+	CapabilityNet Capability = "net"
+	// CapabilityExec grants executing commands using os/exec. Declared as 'exec' or 'exec:<command>'.
+	CapabilityExec Capability = "exec"
+	// CapabilityEnv grants reading and modifying environment variables. Declared as 'env'.
+	CapabilityEnv Capability = "env"
+	// CapabilitySyscall grants access to the syscall package. Declared as 'syscall'.
+	CapabilitySyscall Capability = "syscall"
+	// CapabilityUnsafe grants access to the unsafe package. Declared as 'unsafe'.
+	CapabilityUnsafe Capability = "unsafe"
+)
+
+var (
+	ErrInvalidPermission    = errors.New("invalid permission")
+	ErrUndeclaredPermission = errors.New("undeclared permission")
+)
+
+// Permission is a single capability declared in the plugin manifest
+type Permission struct {
+	Capability Capability
+	// Write is set for 'fs:write:<path>'
+	Write bool
+	// Arg is the path of fs permissions and the command of exec permissions
+	Arg string
+}
+
+func (p Permission) String() string {
+	switch {
+	case p.Capability == CapabilityFs && p.Write:
+		return "fs:write:" + p.Arg
+	case p.Capability == CapabilityFs:
+		return "fs:read:" + p.Arg
+	case p.Arg != "":
+		return string(p.Capability) + ":" + p.Arg
+	default:
+		return string(p.Capability)
+	}
+}
+
+// ParsePermission parses a permission as declared in the plugin manifest
+func ParsePermission(s string) (p Permission, err error) {
+	parts := strings.SplitN(s, ":", 3)
+	p.Capability = Capability(parts[0])
+
+	switch p.Capability {
+	case CapabilityFs:
+		if len(parts) != 3 || (parts[1] != "read" && parts[1] != "write") || parts[2] == "" {
+			return p, fmt.Errorf("%w %q: expected fs:read:<path> or fs:write:<path>", ErrInvalidPermission, s)
+		}
+		p.Write = parts[1] == "write"
+		p.Arg = parts[2]
+	case CapabilityExec:
+		if len(parts) > 2 {
+			return p, fmt.Errorf("%w %q: expected exec or exec:<command>", ErrInvalidPermission, s)
+		}
+		if len(parts) == 2 {
+			p.Arg = parts[1]
+		}
+	case CapabilityNet, CapabilityEnv, CapabilitySyscall, CapabilityUnsafe:
+		if len(parts) != 1 {
+			return p, fmt.Errorf("%w %q: %s does not take arguments", ErrInvalidPermission, s, p.Capability)
+		}
+	default:
+		return p, fmt.Errorf("%w %q: unknown capability %q", ErrInvalidPermission, s, p.Capability)
+	}
+	return p, nil
+}
+
+type Permissions []Permission
+
+// ParsePermissions parses the permissions declared in the plugin manifest
+func ParsePermissions(declared []string) (perms Permissions, err error) {
+	for _, s := range declared {
+		p, err := ParsePermission(s)
+		if err != nil {
+			return nil, err
+		}
+		perms = append(perms, p)
+	}
+	return perms, nil
+}
+
+// Has returns true if the capability has been declared
+func (perms Permissions) Has(c Capability) bool {
+	for _, p := range perms {
+		if p.Capability == c {
+			return true
+		}
+	}
+	return false
+}
+
+// stdlibPackages are the stdlib packages exported to plugins, mapped to the capability they require.
+// Packages, which are not listed, are not available to plugins: e.g. debug/elf, go/build and os/user reach the
+// file system or run commands on their own.
+var stdlibPackages = map[string]Capability{
+	"archive/tar": "", "archive/zip": "", "bufio": "", "bytes": "",
+	"compress/bzip2": "", "compress/flate": "", "compress/gzip": "", "compress/lzw": "", "compress/zlib": "",
+	"container/heap": "", "container/list": "", "container/ring": "", "context": "",
+	"crypto": "", "crypto/aes": "", "crypto/cipher": "", "crypto/des": "", "crypto/dsa": "", "crypto/ecdsa": "",
+	"crypto/ed25519": "", "crypto/elliptic": "", "crypto/hmac": "", "crypto/md5": "", "crypto/rand": "",
+	"crypto/rc4": "", "crypto/rsa": "", "crypto/sha1": "", "crypto/sha256": "", "crypto/sha512": "",
+	"crypto/subtle": "", "crypto/x509": "", "crypto/x509/pkix": "", "embed": "",
+	"encoding": "", "encoding/ascii85": "", "encoding/asn1": "", "encoding/base32": "", "encoding/base64": "",
+	"encoding/binary": "", "encoding/csv": "", "encoding/gob": "", "encoding/hex": "", "encoding/json": "",
+	"encoding/pem": "", "encoding/xml": "", "errors": "", "flag": "", "fmt": "",
+	"go/ast": "", "go/constant": "", "go/doc": "", "go/format": "", "go/parser": "", "go/printer": "",
+	"go/scanner": "", "go/token": "", "go/types": "",
+	"hash": "", "hash/adler32": "", "hash/crc32": "", "hash/crc64": "", "hash/fnv": "", "hash/maphash": "",
+	"html": "", "html/template": "", "image": "", "image/color": "", "image/color/palette": "", "image/draw": "",
+	"image/gif": "", "image/jpeg": "", "image/png": "", "index/suffixarray": "", "io": "", "io/fs": "",
+	"io/ioutil": "", "log": "", "math": "", "math/big": "", "math/bits": "", "math/cmplx": "", "math/rand": "",
+	"mime": "", "mime/quotedprintable": "", "net/mail": "", "net/url": "", "os": "", "os/signal": "",
+	"path": "", "path/filepath": "", "reflect": "", "regexp": "", "regexp/syntax": "", "runtime": "",
+	"sort": "", "strconv": "", "strings": "", "sync": "", "sync/atomic": "",
+	"text/scanner": "", "text/tabwriter": "", "text/template": "", "text/template/parse": "", "time": "",
+	"unicode": "", "unicode/utf16": "", "unicode/utf8": "",
+
+	"crypto/tls": CapabilityNet, "log/syslog": CapabilityNet, "mime/multipart": CapabilityNet, "net": CapabilityNet,
+	"net/http": CapabilityNet, "net/http/cookiejar": CapabilityNet, "net/http/fcgi": CapabilityNet,
+	"net/http/httptest": CapabilityNet, "net/http/httptrace": CapabilityNet, "net/http/httputil": CapabilityNet,
+	"net/rpc": CapabilityNet, "net/rpc/jsonrpc": CapabilityNet, "net/smtp": CapabilityNet,
+	"net/textproto": CapabilityNet,
+
+	"os/exec": CapabilityExec,
+	"syscall": CapabilitySyscall,
+	"unsafe":  CapabilityUnsafe,
+}
+
+// stdlibSymbols restricts the packages, which reach the file system, the environment or other processes,
+// to the listed symbols, mapped to the capability they require. Symbols requiring CapabilityFs are never exported,
+// they are listed to explain the error.
+var stdlibSymbols = map[string]map[string]Capability{
+	"os": symbolsRequiring("", `Args DevNull ErrClosed ErrDeadlineExceeded ErrExist ErrInvalid ErrNoDeadline
+		ErrNotExist ErrPermission ErrProcessDone Executable Exit Expand Getegid Geteuid Getgid Getgroups Getpagesize
+		Getpid Getppid Getuid Getwd Hostname Interrupt IsExist IsNotExist IsPathSeparator IsPermission IsTimeout Kill
+		ModeAppend ModeCharDevice ModeDevice ModeDir ModeExclusive ModeIrregular ModeNamedPipe ModePerm ModeSetgid
+		ModeSetuid ModeSocket ModeSticky ModeSymlink ModeTemporary ModeType NewSyscallError O_APPEND O_CREATE O_EXCL
+		O_RDONLY O_RDWR O_SYNC O_TRUNC O_WRONLY PathListSeparator PathSeparator Pipe SEEK_CUR SEEK_END SEEK_SET
+		SameFile Stderr Stdin Stdout TempDir UserCacheDir UserConfigDir UserHomeDir
+		DirEntry File FileInfo FileMode LinkError PathError ProcAttr Process ProcessState Signal SyscallError
+		_DirEntry _FileInfo _Signal`,
+	).with(CapabilityEnv, `Clearenv Environ ExpandEnv Getenv LookupEnv Setenv Unsetenv`).with(CapabilityExec, `FindProcess StartProcess`).with(CapabilityFs, `Chdir Chmod Chown Chtimes Create CreateTemp DirFS Lchown Link Lstat Mkdir MkdirAll
+		MkdirTemp NewFile Open OpenFile ReadDir ReadFile Readlink Remove RemoveAll Rename Stat Symlink Truncate
+		WriteFile`),
+	"io/ioutil": symbolsRequiring("", `Discard NopCloser ReadAll`).
+		with(CapabilityFs, `ReadDir ReadFile TempDir TempFile WriteFile`),
+	"path/filepath": symbolsRequiring("", `Abs Base Clean Dir ErrBadPattern Ext FromSlash HasPrefix IsAbs Join
+		ListSeparator Match Rel Separator SkipDir Split SplitList ToSlash VolumeName WalkFunc`).
+		with(CapabilityFs, `EvalSymlinks Glob Walk WalkDir`),
+	"text/template": symbolsRequiring("", `HTMLEscape HTMLEscapeString HTMLEscaper IsTrue JSEscape JSEscapeString
+		JSEscaper Must New ParseFS URLQueryEscaper ExecError FuncMap Template`).
+		with(CapabilityFs, `ParseFiles ParseGlob`),
+	"html/template": symbolsRequiring("", `ErrAmbigContext ErrBadHTML ErrBranchEnd ErrEndContext ErrNoSuchTemplate
+		ErrOutputContext ErrPartialCharset ErrPartialEscape ErrPredefinedEscaper ErrRangeLoopReentry ErrSlashAmbig
+		HTMLEscape HTMLEscapeString HTMLEscaper IsTrue JSEscape JSEscapeString JSEscaper Must New OK ParseFS
+		URLQueryEscaper CSS Error ErrorCode FuncMap HTML HTMLAttr JS JSStr Srcset Template URL`).
+		with(CapabilityFs, `ParseFiles ParseGlob`),
+	"archive/zip": symbolsRequiring("", `Deflate ErrAlgorithm ErrChecksum ErrFormat FileInfoHeader NewReader
+		NewWriter RegisterCompressor RegisterDecompressor Store Compressor Decompressor File FileHeader ReadCloser
+		Reader Writer`).
+		with(CapabilityFs, `OpenReader`),
+	"go/parser": symbolsRequiring("", `AllErrors DeclarationErrors ImportsOnly PackageClauseOnly ParseComments
+		ParseExpr ParseExprFrom SkipObjectResolution SpuriousErrors Trace Mode`).
+		with(CapabilityFs, `ParseDir ParseFile`),
+	"net/http": symbolsRequiring("", `AllowQuerySemicolons CanonicalHeaderKey DefaultClient DefaultMaxHeaderBytes
+		DefaultMaxIdleConnsPerHost DefaultServeMux DefaultTransport DetectContentType ErrAbortHandler
+		ErrBodyNotAllowed ErrBodyReadAfterClose ErrContentLength ErrHandlerTimeout ErrHeaderTooLong ErrHijacked
+		ErrLineTooLong ErrMissingBoundary ErrMissingContentLength ErrMissingFile ErrNoCookie ErrNoLocation
+		ErrNotMultipart ErrNotSupported ErrServerClosed ErrShortBody ErrSkipAltProtocol ErrUnexpectedTrailer
+		ErrUseLastResponse ErrWriteAfterFlush Error FS Get Handle HandleFunc Head ListenAndServe ListenAndServeTLS
+		LocalAddrContextKey MaxBytesReader MethodConnect MethodDelete MethodGet MethodHead MethodOptions MethodPatch
+		MethodPost MethodPut MethodTrace NewRequest NewRequestWithContext NewServeMux NoBody NotFound
+		NotFoundHandler ParseHTTPVersion ParseTime Post PostForm ProxyFromEnvironment ProxyURL ReadRequest
+		ReadResponse Redirect RedirectHandler SameSiteDefaultMode SameSiteLaxMode SameSiteNoneMode
+		SameSiteStrictMode Serve ServeContent ServeTLS ServerContextKey SetCookie StateActive StateClosed
+		StateHijacked StateIdle StateNew StatusAccepted StatusAlreadyReported StatusBadGateway StatusBadRequest
+		StatusConflict StatusContinue StatusCreated StatusEarlyHints StatusExpectationFailed StatusFailedDependency
+		StatusForbidden StatusFound StatusGatewayTimeout StatusGone StatusHTTPVersionNotSupported StatusIMUsed
+		StatusInsufficientStorage StatusInternalServerError StatusLengthRequired StatusLocked StatusLoopDetected
+		StatusMethodNotAllowed StatusMisdirectedRequest StatusMovedPermanently StatusMultiStatus
+		StatusMultipleChoices StatusNetworkAuthenticationRequired StatusNoContent StatusNonAuthoritativeInfo
+		StatusNotAcceptable StatusNotExtended StatusNotFound StatusNotImplemented StatusNotModified StatusOK
+		StatusPartialContent StatusPaymentRequired StatusPermanentRedirect StatusPreconditionFailed
+		StatusPreconditionRequired StatusProcessing StatusProxyAuthRequired StatusRequestEntityTooLarge
+		StatusRequestHeaderFieldsTooLarge StatusRequestTimeout StatusRequestURITooLong
+		StatusRequestedRangeNotSatisfiable StatusResetContent StatusSeeOther StatusServiceUnavailable
+		StatusSwitchingProtocols StatusTeapot StatusTemporaryRedirect StatusText StatusTooEarly
+		StatusTooManyRequests StatusUnauthorized StatusUnavailableForLegalReasons StatusUnprocessableEntity
+		StatusUnsupportedMediaType StatusUpgradeRequired StatusUseProxy StatusVariantAlsoNegotiates StripPrefix
+		TimeFormat TimeoutHandler TrailerPrefix Client CloseNotifier ConnState Cookie CookieJar File FileSystem
+		Flusher Handler HandlerFunc Header Hijacker ProtocolError PushOptions Pusher Request Response
+		ResponseWriter RoundTripper SameSite ServeMux Server Transport _CloseNotifier _CookieJar _File
+		_FileSystem _Flusher _Handler _Hijacker _Pusher _ResponseWriter _RoundTripper`).
+		with(CapabilityFs, `Dir FileServer NewFileTransport ServeFile`),
+}
+
+// fsMethods are methods of exported types, which read files by their name. Methods can't be omitted from the
+// exported symbols, so their use is rejected by Check in sources importing the package.
+var fsMethods = map[string][]string{
+	"text/template": {"ParseFiles", "ParseGlob"},
+	"html/template": {"ParseFiles", "ParseGlob"},
+}
+
+type symbolSet map[string]Capability
+
+// symbolsRequiring returns the space separated symbols, which require c
+func symbolsRequiring(c Capability, names string) symbolSet {
+	return symbolSet{}.with(c, names)
+}
+
+// with adds the space separated symbols, which require c
+func (s symbolSet) with(c Capability, names string) symbolSet {
+	for _, name := range strings.Fields(names) {
+		s[name] = c
+	}
+	return s
+}
+
+// requiredCapability returns the capability required to import the stdlib package pkg.
+// Packages, which are not available to plugins, return false.
+func requiredCapability(pkg string) (Capability, bool) {
+	c, ok := stdlibPackages[pkg]
+	return c, ok
+}
+
+// isStdlib returns true for the packages provided by the interpreter, instead of the GOPATH of the plugin.
+// os/exec isn't part of the interpreter's stdlib, it is provided by execSymbols.
+func isStdlib(pkg string) bool {
+	for _, symbols := range []interp.Exports{stdlib.Symbols, syscall.Symbols, unsafe.Symbols} {
+		if _, ok := symbols[pkg+"/"+path.Base(pkg)]; ok {
+			return true
+		}
+	}
+	return pkg == "os/exec"
+}
+
+// Symbols returns the symbols exported to the interpreter of the plugin.
+// Only the stdlib packages and symbols listed in stdlibPackages and stdlibSymbols are exported, as far as the
+// capabilities they require have been declared.
+// File system functions are never exported, as file system access is routed through the Fs passed to the plugin.
+func (perms Permissions) Symbols() interp.Exports {
+	exports := interp.Exports{}
+	for key, symbols := range stdlib.Symbols {
+		pkg := path.Dir(key)
+		c, ok := requiredCapability(pkg)
+		if !ok || (c != "" && !perms.Has(c)) {
+			continue
+		}
+
+		allowed, ok := stdlibSymbols[pkg]
+		if !ok {
+			exports[key] = symbols
+			continue
+		}
+
+		filtered := map[string]reflect.Value{}
+		for name, sym := range symbols {
+			if c, ok := allowed[name]; ok && (c == "" || perms.allowsSymbol(c)) {
+				filtered[name] = sym
+			}
+		}
+		exports[key] = filtered
+	}
+
+	if perms.Has(CapabilitySyscall) {
+		for key, symbols := range syscall.Symbols {
+			exports[key] = symbols
+		}
+	}
+	if perms.Has(CapabilityUnsafe) {
+		for key, symbols := range unsafe.Symbols {
+			exports[key] = symbols
+		}
+	}
+	if perms.Has(CapabilityExec) {
+		exports["os/exec/exec"] = perms.execSymbols()
+	}
+	return exports
+}
+
+// allowsSymbol returns true if a stdlib symbol requiring c is exported to the plugin.
+// os.StartProcess requires 'exec', as it can't be restricted to the commands declared using 'exec:<command>'.
+func (perms Permissions) allowsSymbol(c Capability) bool {
+	switch c {
+	case CapabilityFs:
+		return false
+	case CapabilityExec:
+		return perms.allowsAnyCommand()
+	default:
+		return perms.Has(c)
+	}
+}
+
+// Check returns an error describing the first capability used by source, which has not been declared
+func (perms Permissions) Check(source string) error {
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", source, 0)
+	if err != nil {
+		return err
+	}
+	var methods []string
+	for _, imp := range f.Imports {
+		pkg, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return err
+		}
+		if !isStdlib(pkg) {
+			continue
+		}
+		c, ok := requiredCapability(pkg)
+		if !ok {
+			return fmt.Errorf("%w: package %q is not available to plugins", ErrUndeclaredPermission, pkg)
+		}
+		if c != "" && !perms.Has(c) {
+			return fmt.Errorf("%w: importing %q requires the %q permission", ErrUndeclaredPermission, pkg, c)
+		}
+		methods = append(methods, fsMethods[pkg]...)
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok || err != nil {
+			return err == nil
+		}
+		for _, method := range methods {
+			if sel.Sel.Name == method {
+				err = fmt.Errorf("%w: %s is not available, access the file system using the Fs passed in the config instead", ErrUndeclaredPermission, method)
+			}
+		}
+		return err == nil
+	})
+	return err
+}
+
+var (
+	undefinedSelectorRegex = regexp.MustCompile(`undefined selector:? (\w+)\.(\w+)`)
+	missingSymbolRegex     = regexp.MustCompile(`package (\w+) "[^"]*" has no symbol (\w+)`)
+)
+
+// explainEvalError replaces errors of the interpreter about symbols, which have been omitted due to missing permissions
+func (perms Permissions) explainEvalError(err error) error {
+	m := undefinedSelectorRegex.FindStringSubmatch(err.Error())
+	if m == nil {
+		m = missingSymbolRegex.FindStringSubmatch(err.Error())
+	}
+	if m == nil {
+		return err
+	}
+	for pkg, symbols := range stdlibSymbols {
+		if path.Base(pkg) != m[1] {
+			continue
+		}
+		switch c, ok := symbols[m[2]]; {
+		case !ok || c == "":
+		case ok && c == CapabilityFs:
+			return fmt.Errorf("%w: %s.%s is not available, access the file system using the Fs passed in the config instead: %v", ErrUndeclaredPermission, pkg, m[2], err)
+		case ok && !perms.allowsSymbol(c):
+			return fmt.Errorf("%w: using %s.%s requires the %q permission: %v", ErrUndeclaredPermission, pkg, m[2], c, err)
+		}
+	}
+	return err
+}
+
+// expandPath expands the variables in the path of a fs permission
+func expandPath(path string, vars map[string]string) string {
+	if strings.HasPrefix(path, "~/") {
+		path = "$HOME" + path[1:]
+	}
+	return filepath.Clean(os.Expand(path, func(key string) string {
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		return os.Getenv(key)
+	}))
+}
@@ -0,0 +1,241 @@
+package plugins
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/alex-held/devctl-kit/pkg/devctlpath"
+)
+
+func TestParsePermissions(t *testing.T) {
+	perms, err := ParsePermissions([]string{"fs:read:$DEVCTL_ROOT", "fs:write:/tmp", "net", "exec:git", "exec"})
+	assert.NoError(t, err)
+	assert.Equal(t, Permissions{
+		{Capability: CapabilityFs, Arg: "$DEVCTL_ROOT"},
+		{Capability: CapabilityFs, Write: true, Arg: "/tmp"},
+		{Capability: CapabilityNet},
+		{Capability: CapabilityExec, Arg: "git"},
+		{Capability: CapabilityExec},
+	}, perms)
+
+	for _, invalid := range []string{"fs", "fs:delete:/tmp", "net:github.com", "exec:a:b", "root"} {
+		_, err := ParsePermissions([]string{invalid})
+		assert.ErrorIs(t, err, ErrInvalidPermission, invalid)
+	}
+}
+
+func TestPermissions_Check(t *testing.T) {
+	source := "package p\n\nimport (\n\t\"fmt\"\n\t\"net/http\"\n)\n"
+
+	err := Permissions{}.Check(source)
+	assert.ErrorIs(t, err, ErrUndeclaredPermission)
+	assert.Contains(t, err.Error(), `importing "net/http" requires the "net" permission`)
+
+	assert.NoError(t, Permissions{{Capability: CapabilityNet}}.Check(source))
+
+	for _, pkg := range []string{"debug/elf", "go/build", "go/importer", "os/user"} {
+		err = Permissions{}.Check("package p\n\nimport _ \"" + pkg + "\"\n")
+		assert.ErrorIs(t, err, ErrUndeclaredPermission, pkg)
+	}
+	assert.NoError(t, Permissions{}.Check("package p\n\nimport _ \"github.com/spf13/afero\"\n"), "packages of the GOPATH are not restricted")
+
+	err = Permissions{}.Check("package p\n\nimport \"text/template\"\n\nvar _, _ = template.New(\"t\").ParseFiles(\"/etc/passwd\")\n")
+	assert.ErrorIs(t, err, ErrUndeclaredPermission)
+	assert.Contains(t, err.Error(), "ParseFiles is not available")
+}
+
+func TestPermissions_Symbols(t *testing.T) {
+	exports := Permissions{}.Symbols()
+	for _, symbol := range [][2]string{
+		{"text/template/template", "ParseFiles"},
+		{"html/template/template", "ParseGlob"},
+		{"archive/zip/zip", "OpenReader"},
+		{"go/parser/parser", "ParseFile"},
+		{"os/os", "ReadFile"},
+		{"os/os", "Getenv"},
+	} {
+		_, ok := exports[symbol[0]][symbol[1]]
+		assert.False(t, ok, "%s.%s", symbol[0], symbol[1])
+	}
+	_, ok := exports["text/template/template"]["New"]
+	assert.True(t, ok)
+	for _, key := range []string{"debug/elf/elf", "go/build/build", "go/importer/importer", "net/http/http", "os/exec/exec"} {
+		assert.NotContains(t, exports, key)
+	}
+
+	exports = Permissions{{Capability: CapabilityNet}, {Capability: CapabilityEnv}}.Symbols()
+	assert.Contains(t, exports["net/http/http"], "Get")
+	assert.NotContains(t, exports["net/http/http"], "ServeFile")
+	assert.Contains(t, exports["os/os"], "Getenv")
+
+	for pkg := range stdlibPackages {
+		assert.True(t, isStdlib(pkg), "%s is provided by the interpreter", pkg)
+	}
+}
+
+func TestPermissions_Fs(t *testing.T) {
+	base := afero.NewMemMapFs()
+	_ = afero.WriteFile(base, "/home/user/.devctl/config.yaml", []byte("config"), 0644)
+	_ = afero.WriteFile(base, "/home/user/.ssh/id_rsa", []byte("secret"), 0600)
+
+	perms, _ := ParsePermissions([]string{"fs:read:$DEVCTL_ROOT", "fs:write:$DEVCTL_ROOT/sdks"})
+	sut := perms.Fs(base, map[string]string{"DEVCTL_ROOT": "/home/user/.devctl"})
+
+	b, err := afero.ReadFile(sut, "/home/user/.devctl/config.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "config", string(b))
+
+	_, err = afero.ReadFile(sut, "/home/user/.ssh/id_rsa")
+	assert.ErrorIs(t, err, fs.ErrPermission)
+
+	err = afero.WriteFile(sut, "/home/user/.devctl/config.yaml", []byte("modified"), 0644)
+	assert.ErrorIs(t, err, ErrUndeclaredPermission)
+
+	assert.NoError(t, sut.MkdirAll("/home/user/.devctl/sdks/go", 0755))
+	assert.ErrorIs(t, sut.MkdirAll("/home/user/.devctl-sdks", 0755), ErrUndeclaredPermission)
+}
+
+func TestLoadPlugin_UndeclaredPermission(t *testing.T) {
+	testFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(testFs, "/plugins/p/plugin.yaml", []byte("plugin:\n  name: p\n  pkg: p\n  cmd: p\n  permissions: [\"exec:git\"]\n"), 0644)
+
+	sut := NewEngine(func(c *Config) *Config {
+		c.Pather = devctlpath.NewPather(devctlpath.WithConfigRootFn(func() string {
+			return "/home/user/.devctl"
+		}))
+		c.Fs = testFs
+		return c
+	})
+
+	load := func(source string) (*ExecutablePlugin, error) {
+		_ = afero.WriteFile(testFs, "/plugins/p/main.go", []byte(source), 0644)
+		p, err := sut.LoadPlugin("/plugins/p/plugin.yaml")
+		if err != nil {
+			return nil, err
+		}
+		return sut.NewExecutablePlugin(p, map[string]interface{}{})
+	}
+
+	_, err := load("package p\n\nimport \"net/http\"\n\nvar _ = http.Get\n")
+	assert.ErrorIs(t, err, ErrUndeclaredPermission)
+
+	_, err = load("package p\n\nimport \"os\"\n\nvar Home = os.Getenv(\"HOME\")\n\nfunc CreateConfig() *struct{} { return &struct{}{} }\n")
+	assert.ErrorIs(t, err, ErrUndeclaredPermission)
+	assert.Contains(t, err.Error(), `using os.Getenv requires the "env" permission`)
+
+	run := func(source string, args ...string) error {
+		execP, err := load(source)
+		if err != nil {
+			return err
+		}
+		return execP.Exec(args)
+	}
+
+	lookPath := `package p
+
+import "os/exec"
+
+func CreateConfig() *struct{} { return &struct{}{} }
+
+func Exec(cfg *struct{}, args []string) error {
+	_, err := exec.LookPath(args[0])
+	return err
+}
+`
+	assert.ErrorIs(t, run(lookPath, "curl"), ErrUndeclaredPermission)
+	assert.False(t, errors.Is(run(lookPath, "git"), ErrUndeclaredPermission))
+
+	// a binary named like a declared command in another directory
+	fake := filepath.Join(t.TempDir(), "git")
+	assert.NoError(t, os.WriteFile(fake, []byte("#!/bin/sh\n"), 0755))
+	assert.ErrorIs(t, run(lookPath, fake), ErrUndeclaredPermission)
+
+	command := `package p
+
+import "os/exec"
+
+func CreateConfig() *struct{} { return &struct{}{} }
+
+func Exec(cfg *struct{}, args []string) error {
+	return exec.Command(args[0], "version").Run()
+}
+`
+	assert.ErrorIs(t, run(command, fake), ErrUndeclaredPermission)
+	assert.ErrorIs(t, run(command, "sh"), ErrUndeclaredPermission)
+
+	_, err = load(`package p
+
+import "os/exec"
+
+func CreateConfig() *struct{} { return &struct{}{} }
+
+func Exec(cfg *struct{}, args []string) error {
+	cmd := exec.Command("git", "version")
+	cmd.Path = "/bin/sh"
+	return cmd.Run()
+}
+`)
+	assert.Error(t, err, "the path of a command can't be changed")
+
+	assert.Error(t, run(`package p
+
+import "os/exec"
+
+func CreateConfig() *struct{} { return &struct{}{} }
+
+func Exec(cfg *struct{}, args []string) error {
+	return (&exec.Cmd{Args: []string{"/bin/sh", "-c", "true"}}).Run()
+}
+`), "commands can only be created using exec.Command")
+
+	_, err = load(`package p
+
+import "os"
+
+func CreateConfig() *struct{} { return &struct{}{} }
+
+func Exec(cfg *struct{}, args []string) error {
+	_, err := os.StartProcess("/bin/sh", nil, &os.ProcAttr{})
+	return err
+}
+`)
+	assert.ErrorIs(t, err, ErrUndeclaredPermission)
+	assert.Contains(t, err.Error(), `using os.StartProcess requires the "exec" permission`)
+}
+
+func TestPermissions_FsSymlinks(t *testing.T) {
+	tmp := t.TempDir()
+	allowed, secret := filepath.Join(tmp, "allowed"), filepath.Join(tmp, "secret")
+	assert.NoError(t, os.MkdirAll(filepath.Join(allowed, "dir"), 0755))
+	assert.NoError(t, os.MkdirAll(secret, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(secret, "id_rsa"), []byte("secret"), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(allowed, "config"), []byte("config"), 0600))
+	assert.NoError(t, os.Symlink(secret, filepath.Join(allowed, "link")))
+	assert.NoError(t, os.Symlink(filepath.Join(secret, "id_rsa"), filepath.Join(allowed, "dir", "key")))
+	assert.NoError(t, os.Symlink("config", filepath.Join(allowed, "relative")))
+	assert.NoError(t, os.Symlink(allowed, filepath.Join(tmp, "root")))
+
+	perms, _ := ParsePermissions([]string{"fs:write:" + filepath.Join(tmp, "root")})
+	sut := perms.Fs(afero.NewOsFs(), nil)
+
+	b, err := afero.ReadFile(sut, filepath.Join(allowed, "relative"))
+	assert.NoError(t, err, "the declared path is resolved as well")
+	assert.Equal(t, "config", string(b))
+
+	for _, path := range []string{
+		filepath.Join(allowed, "link", "id_rsa"),
+		filepath.Join(allowed, "dir", "key"),
+		filepath.Join(allowed, "link") + "/../secret/id_rsa",
+	} {
+		_, err = afero.ReadFile(sut, path)
+		assert.ErrorIs(t, err, ErrUndeclaredPermission, path)
+	}
+	assert.ErrorIs(t, afero.WriteFile(sut, filepath.Join(allowed, "link", "new"), []byte("x"), 0644), ErrUndeclaredPermission)
+	assert.NoError(t, afero.WriteFile(sut, filepath.Join(allowed, "dir", "new"), []byte("x"), 0644))
+}
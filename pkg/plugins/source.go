@@ -0,0 +1,241 @@
+package plugins
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	// ErrEmbedPattern is returned if a //go:embed directive of a plugin does not match exactly one file
+	ErrEmbedPattern = errors.New("invalid //go:embed directive")
+	// ErrEmbedFS is returned if a plugin embeds files into an embed.FS, which the interpreter can't populate
+	ErrEmbedFS = errors.New("embedding into an embed.FS is not supported, read the files using the Files fs passed in the config instead")
+)
+
+// loadSources reads all go files of the plugin package in rootPath.
+// Variables annotated with //go:embed get initialized with the content of the embedded file,
+// because the interpreter does not support embedding on its own.
+func (e *Engine) loadSources(rootPath string) (files map[string]string, err error) {
+	fis, err := afero.ReadDir(e.cfg.Fs, rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files = map[string]string{}
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() || path.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		b, err := afero.ReadFile(e.cfg.Fs, path.Join(rootPath, name))
+		if err != nil {
+			return nil, err
+		}
+		if b, err = e.resolveEmbeds(rootPath, name, b); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		files[name] = string(b)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no go files found in %s", rootPath)
+	}
+	return files, nil
+}
+
+// resolveEmbeds initializes the string and []byte variables of src, which are annotated with //go:embed.
+// The initializers are inserted on the line of the declaration, so positions reported by the interpreter still match the file.
+func (e *Engine) resolveEmbeds(rootPath, name string, src []byte) ([]byte, error) {
+	if !bytes.Contains(src, []byte("//go:embed")) {
+		return src, nil
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	type insertion struct {
+		offset int
+		text   string
+	}
+	var insertions []insertion
+
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, s := range gen.Specs {
+			spec := s.(*ast.ValueSpec)
+			doc := spec.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			patterns, err := embedPatterns(doc)
+			if err != nil {
+				return nil, err
+			}
+			if len(patterns) == 0 {
+				continue
+			}
+			if len(spec.Names) != 1 || len(spec.Values) != 0 {
+				return nil, fmt.Errorf("%w: %s must declare a single variable without a value", ErrEmbedPattern, fset.Position(spec.Pos()))
+			}
+
+			var format string
+			switch t := spec.Type.(type) {
+			case *ast.Ident:
+				if t.Name == "string" {
+					format = " = %s"
+				}
+			case *ast.ArrayType:
+				if elt, ok := t.Elt.(*ast.Ident); ok && t.Len == nil && elt.Name == "byte" {
+					format = " = []byte(%s)"
+				}
+			case *ast.SelectorExpr:
+				if x, ok := t.X.(*ast.Ident); ok && x.Name == "embed" && t.Sel.Name == "FS" {
+					return nil, fmt.Errorf("%s: %w", spec.Names[0].Name, ErrEmbedFS)
+				}
+			}
+			if format == "" {
+				return nil, fmt.Errorf("%w: %s must be of type string or []byte", ErrEmbedPattern, spec.Names[0].Name)
+			}
+
+			b, err := e.readEmbed(rootPath, patterns)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", spec.Names[0].Name, err)
+			}
+			insertions = append(insertions, insertion{
+				offset: fset.Position(spec.Type.End()).Offset,
+				text:   fmt.Sprintf(format, strconv.Quote(string(b))),
+			})
+		}
+	}
+
+	// insert from the back, so the offsets of the remaining insertions stay valid
+	sort.Slice(insertions, func(i, j int) bool { return insertions[i].offset > insertions[j].offset })
+	out := append([]byte{}, src...)
+	for _, ins := range insertions {
+		out = append(out[:ins.offset], append([]byte(ins.text), out[ins.offset:]...)...)
+	}
+	return out, nil
+}
+
+// embedPatterns returns the patterns of all //go:embed directives of doc
+func embedPatterns(doc *ast.CommentGroup) (patterns []string, err error) {
+	if doc == nil {
+		return nil, nil
+	}
+	for _, c := range doc.List {
+		if !strings.HasPrefix(c.Text, "//go:embed ") {
+			continue
+		}
+		for _, field := range strings.Fields(strings.TrimPrefix(c.Text, "//go:embed ")) {
+			if strings.HasPrefix(field, `"`) || strings.HasPrefix(field, "`") {
+				if field, err = strconv.Unquote(field); err != nil {
+					return nil, fmt.Errorf("%w: %s", ErrEmbedPattern, c.Text)
+				}
+			}
+			patterns = append(patterns, field)
+		}
+	}
+	return patterns, nil
+}
+
+// readEmbed reads the single file matched by patterns relative to rootPath.
+// Like the go command, it rejects patterns and files outside of the plugin directory.
+func (e *Engine) readEmbed(rootPath string, patterns []string) ([]byte, error) {
+	root, err := resolveSymlinks(e.cfg.Fs, rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, pattern := range patterns {
+		if pattern == "." || !fs.ValidPath(pattern) {
+			return nil, fmt.Errorf("%w: pattern %q must be a relative path within the plugin directory", ErrEmbedPattern, pattern)
+		}
+		m, err := afero.Glob(e.cfg.Fs, path.Join(rootPath, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrEmbedPattern, err)
+		}
+		if len(m) == 0 {
+			return nil, fmt.Errorf("%w: pattern %q matches no files", ErrEmbedPattern, pattern)
+		}
+		matches = append(matches, m...)
+	}
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("%w: patterns %q match %d files, but string and []byte variables embed a single file", ErrEmbedPattern, patterns, len(matches))
+	}
+
+	resolved, err := resolveSymlinks(e.cfg.Fs, matches[0])
+	if err != nil {
+		return nil, err
+	}
+	if !within(resolved, []string{root}) {
+		return nil, fmt.Errorf("%w: %s refers to %s outside of the plugin directory", ErrEmbedPattern, matches[0], resolved)
+	}
+	return afero.ReadFile(e.cfg.Fs, matches[0])
+}
+
+// sourceFS serves the sources of a plugin to the interpreter.
+// The go files of the plugin package are replaced by their resolved Files, everything else,
+// e.g. the packages vendored into _gopath, is read from the underlying fs.
+type sourceFS struct {
+	fs.FS
+	pkgDir string
+	files  map[string]string
+}
+
+// newSourceFS returns the fs, from which the interpreter imports the plugin package p.
+// It is rooted at the parent of the plugin directory, so the package can be imported by the relative import path "./<dir>".
+func (e *Engine) newSourceFS(p *Plugin) *sourceFS {
+	return &sourceFS{
+		FS:     afero.NewIOFS(afero.NewReadOnlyFs(afero.NewBasePathFs(e.cfg.Fs, path.Dir(p.RootPath)))),
+		pkgDir: path.Base(p.RootPath),
+		files:  p.Files,
+	}
+}
+
+// importPath is the relative import path of the plugin package within the sourceFS
+func (s *sourceFS) importPath() string {
+	return "./" + s.pkgDir
+}
+
+func (s *sourceFS) Open(name string) (fs.File, error) {
+	if path.Dir(name) == s.pkgDir {
+		if src, ok := s.files[path.Base(name)]; ok {
+			return &sourceFile{Reader: strings.NewReader(src), name: path.Base(name)}, nil
+		}
+	}
+	return s.FS.Open(name)
+}
+
+// sourceFile is a resolved go file of a plugin package
+type sourceFile struct {
+	*strings.Reader
+	name string
+}
+
+func (f *sourceFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f *sourceFile) Close() error               { return nil }
+
+func (f *sourceFile) Name() string       { return f.name }
+func (f *sourceFile) Mode() os.FileMode  { return 0444 }
+func (f *sourceFile) ModTime() time.Time { return time.Time{} }
+func (f *sourceFile) IsDir() bool        { return false }
+func (f *sourceFile) Sys() interface{}   { return nil }
@@ -0,0 +1,147 @@
+package plugins
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/devctl-kit/pkg/devctlpath"
+)
+
+func newSourceTestEngine(testFs afero.Fs, out *bytes.Buffer) *Engine {
+	return NewEngine(func(c *Config) *Config {
+		c.Fs = testFs
+		c.Out = out
+		c.Pather = devctlpath.NewPather(devctlpath.WithConfigRootFn(func() string {
+			return "/home/user/.devctl"
+		}))
+		return c
+	})
+}
+
+func TestLoadPlugin_Package(t *testing.T) {
+	testFs := afero.NewMemMapFs()
+	files := map[string]string{
+		"/plugins/multi/plugin.yaml": "plugin:\n  name: multi\n  pkg: multi\n  cmd: multi\n",
+		"/plugins/multi/config.go": `package multi
+
+import "example.com/greeting"
+
+type Config struct {
+	Name string
+}
+
+func CreateConfig() *Config { return &Config{Name: greeting.Hello} }
+`,
+		"/plugins/multi/main.go": `package multi
+
+import (
+	_ "embed"
+	"fmt"
+)
+
+//go:embed "templates/greeting.tmpl"
+var tmpl string
+
+//go:embed templates/raw.txt
+var raw []byte
+
+func Exec(cfg *Config, args []string) error {
+	fmt.Printf(tmpl, cfg.Name, string(raw))
+	return nil
+}
+`,
+		"/plugins/multi/main_test.go":                           "package multi\n\nimport \"os\"\n",
+		"/plugins/multi/templates/greeting.tmpl":                "%s, %s!\n",
+		"/plugins/multi/templates/raw.txt":                      "world",
+		"/plugins/multi/_gopath/src/example.com/greeting/hi.go": "package greeting\n\nconst Hello = \"hello\"\n",
+	}
+	for name, content := range files {
+		_ = afero.WriteFile(testFs, name, []byte(content), 0644)
+	}
+
+	out := &bytes.Buffer{}
+	sut := newSourceTestEngine(testFs, out)
+
+	p, err := sut.LoadPlugin("/plugins/multi/plugin.yaml")
+	require.NoError(t, err)
+	assert.Len(t, p.Files, 2)
+	assert.Contains(t, p.Files["main.go"], `var tmpl string = "%s, %s!\n"`)
+	assert.Contains(t, p.Files["main.go"], `var raw []byte = []byte("world")`)
+
+	execP, err := sut.NewExecutablePlugin(p, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.NoError(t, execP.Exec(nil))
+	assert.Equal(t, "hello, world!\n", out.String())
+}
+
+func TestLoadPlugin_Embed(t *testing.T) {
+	tt := []struct {
+		name string
+		src  string
+		err  error
+	}{
+		{
+			name: "embed.FS",
+			src:  "package e\n\nimport \"embed\"\n\n//go:embed templates\nvar templates embed.FS\n",
+			err:  ErrEmbedFS,
+		},
+		{
+			name: "no match",
+			src:  "package e\n\n//go:embed missing.tmpl\nvar tmpl string\n",
+			err:  ErrEmbedPattern,
+		},
+		{
+			name: "multiple matches",
+			src:  "package e\n\n//go:embed templates/*\nvar tmpl string\n",
+			err:  ErrEmbedPattern,
+		},
+		{
+			name: "absolute pattern",
+			src:  "package e\n\n//go:embed /plugins/secret.txt\nvar tmpl string\n",
+			err:  ErrEmbedPattern,
+		},
+		{
+			name: "parent directory",
+			src:  "package e\n\n//go:embed ../secret.txt\nvar tmpl string\n",
+			err:  ErrEmbedPattern,
+		},
+		{
+			name: "parent directory within the plugin",
+			src:  "package e\n\n//go:embed templates/../templates/a.tmpl\nvar tmpl string\n",
+			err:  ErrEmbedPattern,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			testFs := afero.NewMemMapFs()
+			_ = afero.WriteFile(testFs, "/plugins/e/plugin.yaml", []byte("plugin:\n  name: e\n  pkg: e\n  cmd: e\n"), 0644)
+			_ = afero.WriteFile(testFs, "/plugins/e/main.go", []byte(tc.src), 0644)
+			_ = afero.WriteFile(testFs, "/plugins/e/templates/a.tmpl", []byte("a"), 0644)
+			_ = afero.WriteFile(testFs, "/plugins/e/templates/b.tmpl", []byte("b"), 0644)
+			_ = afero.WriteFile(testFs, "/plugins/secret.txt", []byte("secret"), 0644)
+
+			_, err := newSourceTestEngine(testFs, &bytes.Buffer{}).LoadPlugin("/plugins/e/plugin.yaml")
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestLoadPlugin_EmbedSymlink(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "e")
+	require.NoError(t, os.MkdirAll(root, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "plugin.yaml"), []byte("plugin:\n  name: e\n  pkg: e\n  cmd: e\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.go"), []byte("package e\n\n//go:embed secret.txt\nvar tmpl string\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "secret.txt"), []byte("secret"), 0600))
+	require.NoError(t, os.Symlink(filepath.Join(tmp, "secret.txt"), filepath.Join(root, "secret.txt")))
+
+	_, err := newSourceTestEngine(afero.NewOsFs(), &bytes.Buffer{}).LoadPlugin(filepath.Join(root, "plugin.yaml"))
+	assert.ErrorIs(t, err, ErrEmbedPattern)
+}
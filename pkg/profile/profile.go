@@ -0,0 +1,174 @@
+// Package profile activates the profiles of the devctl configuration.
+//
+// Activating a profile links the 'current' version of its sdks, links its zsh configuration
+// and writes a zsh file exporting DEVCTL_PROFILE together with the exports and aliases of the profile.
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alex-held/devctl-kit/pkg/devctlpath"
+	"github.com/pkg/errors"
+
+	"github.com/alex-held/devctl/pkg/config"
+)
+
+// EnvVar is exported to the shell with the name of the active profile, e.g. for prompt integration
+const EnvVar = "DEVCTL_PROFILE"
+
+// ShellFile returns the zsh file of the active profile. It is sourced by 'devctl zsh init' after the files
+// generated by the zsh plugin, so that the exports and aliases of the profile take precedence.
+func ShellFile(pather devctlpath.Pather) string {
+	return pather.Config("zsh", "init.d", "06-profile.zsh")
+}
+
+// ZshConfigPath returns the zsh configuration rendered by the zsh plugin
+func ZshConfigPath(pather devctlpath.Pather) string {
+	return pather.Config("zsh", "config.yaml")
+}
+
+// SDKCurrentPath returns the link to the current version of the sdk
+func SDKCurrentPath(pather devctlpath.Pather, sdk string) string {
+	return pather.SDK(sdk, "current")
+}
+
+// CurrentSDKVersion returns the version linked as current version of the sdk, or an empty string
+func CurrentSDKVersion(pather devctlpath.Pather, sdk string) string {
+	target, err := os.Readlink(SDKCurrentPath(pather, sdk))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// Activate links the sdks and the zsh configuration of the profile name and writes its ShellFile
+func Activate(pather devctlpath.Pather, name string, p config.Profile) error {
+	for _, sdk := range sortedKeys(p.SDKs) {
+		if err := LinkSDK(pather, sdk, p.SDKs[sdk]); err != nil {
+			return err
+		}
+	}
+
+	if p.Zsh != "" {
+		if err := linkZshConfig(pather, p.Zsh); err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(ShellFile(pather), renderShellFile(name, p))
+}
+
+// LinkSDK links the installed version of sdk as its current version
+func LinkSDK(pather devctlpath.Pather, sdk, version string) error {
+	dir := pather.SDK(sdk, version)
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return errors.Errorf("version %s of sdk %s is not installed in %s", version, sdk, dir)
+	}
+	return errors.Wrapf(link(dir, SDKCurrentPath(pather, sdk)), "failed to link the current version of sdk %s", sdk)
+}
+
+// Deactivate removes the ShellFile, the links of the last active profile are kept
+func Deactivate(pather devctlpath.Pather) error {
+	if err := os.Remove(ShellFile(pather)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove the profile shell file")
+	}
+	return nil
+}
+
+// linkZshConfig links the zsh configuration to path. A zsh configuration, which isn't a link yet, is kept as config.yaml.orig.
+func linkZshConfig(pather devctlpath.Pather, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return errors.Wrapf(err, "zsh configuration %s of the profile not found", path)
+	}
+	dst := ZshConfigPath(pather)
+	if fi, err := os.Lstat(dst); err == nil && fi.Mode()&os.ModeSymlink == 0 {
+		orig := dst + ".orig"
+		if _, err := os.Lstat(orig); err == nil {
+			return errors.Errorf("can't link the zsh configuration %s, it is not a link and %s exists already", dst, orig)
+		}
+		if err := os.Rename(dst, orig); err != nil {
+			return errors.Wrapf(err, "failed to keep the zsh configuration %s", dst)
+		}
+	}
+	return errors.Wrap(link(path, dst), "failed to link the zsh configuration")
+}
+
+// link replaces the symbolic link at path with a link to target
+func link(target, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	_ = os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func renderShellFile(name string, p config.Profile) []byte {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "# generated by 'devctl profile use %s', do not edit\n\n", name)
+	fmt.Fprintf(b, "export %s=%s\n", EnvVar, dquote(name))
+	for _, key := range sortedKeys(p.Exports) {
+		fmt.Fprintf(b, "export %s=%s\n", key, dquote(p.Exports[key]))
+	}
+	for _, alias := range sortedKeys(p.Aliases) {
+		fmt.Fprintf(b, "alias %s=%s\n", alias, squote(p.Aliases[alias]))
+	}
+	return b.Bytes()
+}
+
+// dquote quotes s for the shell, leaving variables to be expanded
+func dquote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`")
+	return `"` + r.Replace(s) + `"`
+}
+
+func squote(s string) string {
+	return `'` + strings.Replace(s, `'`, `'\''`, -1) + `'`
+}
+
+func writeFileAtomic(path string, b []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(b); err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return errors.Wrapf(err, "failed to write %s", path)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,65 @@
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/devctl/pkg/config"
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+func TestActivate(t *testing.T) {
+	root, err := ioutil.TempDir("", "devctl-profile")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	pather := env.NewPather(env.RootDirs(root))
+
+	require.NoError(t, os.MkdirAll(pather.SDK("go", "1.16.5"), 0755))
+	require.NoError(t, os.MkdirAll(pather.SDK("go", "1.17"), 0755))
+	require.NoError(t, os.MkdirAll(pather.Config("zsh"), 0755))
+	require.NoError(t, ioutil.WriteFile(ZshConfigPath(pather), []byte("exports: {}\n"), 0644))
+	acmeZsh := pather.Config("zsh", "acme.yaml")
+	require.NoError(t, ioutil.WriteFile(acmeZsh, []byte("aliases: {}\n"), 0644))
+
+	p := config.Profile{
+		SDKs:    map[string]string{"go": "1.16.5"},
+		Zsh:     acmeZsh,
+		Exports: map[string]string{"GOPRIVATE": "github.com/acme", "GREETING": `say "hi"`},
+		Aliases: map[string]string{"k": "kubectl --context 'acme'"},
+	}
+	require.NoError(t, Activate(pather, "acme", p))
+
+	target, err := os.Readlink(SDKCurrentPath(pather, "go"))
+	require.NoError(t, err)
+	assert.Equal(t, pather.SDK("go", "1.16.5"), target)
+	target, err = os.Readlink(ZshConfigPath(pather))
+	require.NoError(t, err)
+	assert.Equal(t, acmeZsh, target)
+	assert.FileExists(t, ZshConfigPath(pather)+".orig")
+
+	b, err := ioutil.ReadFile(ShellFile(pather))
+	require.NoError(t, err)
+	assert.Equal(t, `# generated by 'devctl profile use acme', do not edit
+
+export DEVCTL_PROFILE="acme"
+export GOPRIVATE="github.com/acme"
+export GREETING="say \"hi\""
+alias k='kubectl --context '\''acme'\'''
+`, string(b))
+
+	// switching the sdk version replaces the link
+	require.NoError(t, Activate(pather, "other", config.Profile{SDKs: map[string]string{"go": "1.17"}}))
+	target, err = os.Readlink(SDKCurrentPath(pather, "go"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "sdks", "go", "1.17"), target)
+
+	assert.Error(t, Activate(pather, "missing", config.Profile{SDKs: map[string]string{"go": "1.18"}}))
+
+	require.NoError(t, Deactivate(pather))
+	assert.NoFileExists(t, ShellFile(pather))
+}
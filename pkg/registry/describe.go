@@ -0,0 +1,155 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"k8s.io/klog/v2"
+
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// DescribeArg is the argument external plugins answer with their Description as JSON
+const DescribeArg = "__devctl_describe"
+
+// DefaultDescribeTimeout is how long an external plugin may take to describe itself
+const DefaultDescribeTimeout = 2 * time.Second
+
+// Flag describes a flag of an external plugin
+type Flag struct {
+	Name      string `json:"name"`
+	Shorthand string `json:"shorthand,omitempty"`
+	Usage     string `json:"usage,omitempty"`
+	// Type is the pflag type of the flag, e.g. bool or string
+	Type    string `json:"type,omitempty"`
+	Default string `json:"default,omitempty"`
+}
+
+// Command describes a command of an external plugin
+type Command struct {
+	Name     string    `json:"name"`
+	Short    string    `json:"short,omitempty"`
+	Long     string    `json:"long,omitempty"`
+	Aliases  []string  `json:"aliases,omitempty"`
+	Flags    []Flag    `json:"flags,omitempty"`
+	Commands []Command `json:"commands,omitempty"`
+}
+
+// Description is written as JSON to stdout by external plugins invoked with DescribeArg, e.g.
+//
+//	{"name": "foo", "version": "v1.0.0", "short": "manages foos", "completion": true,
+//	 "commands": [{"name": "list", "short": "lists foos", "flags": [{"name": "all", "type": "bool"}]}]}
+type Description struct {
+	Command
+	Version string `json:"version,omitempty"`
+	// Completion is set, if the plugin completes its arguments when invoked with cobra's __complete command
+	Completion bool `json:"completion,omitempty"`
+	// Protocol lists the versions of the plugin protocol, if the plugin has been built with the plugin SDK
+	Protocol []int `json:"protocol,omitempty"`
+}
+
+// describeCacheEntry is the cached Description of the executable with ModTime and Size.
+// Description is nil, if the executable doesn't support DescribeArg.
+type describeCacheEntry struct {
+	ModTime     time.Time    `json:"modTime"`
+	Size        int64        `json:"size"`
+	Description *Description `json:"description"`
+}
+
+// Describer describes external plugins and caches their descriptions per path and modification time
+type Describer struct {
+	Fs        afero.Fs
+	CachePath string
+	Timeout   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]describeCacheEntry
+}
+
+// NewDescriber returns a Describer caching descriptions in the cache directory of the factory
+func NewDescriber(f env.Factory) *Describer {
+	return &Describer{
+		Fs:        f.Fs(),
+		CachePath: f.Pather().Cache("plugins", "describe.json"),
+		Timeout:   DefaultDescribeTimeout,
+	}
+}
+
+// Describe returns the Description of the executable at path.
+// It returns nil, if the executable doesn't support DescribeArg.
+func (d *Describer) Describe(path string) (*Description, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.load()
+	if e, ok := d.cache[path]; ok && e.ModTime.Equal(fi.ModTime()) && e.Size == fi.Size() {
+		return e.Description, nil
+	}
+
+	desc := d.run(path)
+	d.cache[path] = describeCacheEntry{ModTime: fi.ModTime(), Size: fi.Size(), Description: desc}
+	if err := d.save(); err != nil {
+		klog.V(2).Infof("failed to cache the description of %s: %v", path, err)
+	}
+	return desc, nil
+}
+
+// run invokes the executable at path with DescribeArg.
+// Failures are not errors, as most executables simply don't know about DescribeArg.
+func (d *Describer) run(path string) *Description {
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
+	defer cancel()
+
+	stdout := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, path, DescribeArg)
+	cmd.Stdout = stdout
+	if err := cmd.Run(); err != nil {
+		klog.V(2).Infof("%s does not support %s: %v", path, DescribeArg, err)
+		return nil
+	}
+
+	desc := &Description{}
+	if err := json.Unmarshal(stdout.Bytes(), desc); err != nil {
+		klog.V(2).Infof("%s returned an invalid description: %v", path, err)
+		return nil
+	}
+	return desc
+}
+
+func (d *Describer) load() {
+	if d.cache != nil {
+		return
+	}
+	d.cache = map[string]describeCacheEntry{}
+	b, err := afero.ReadFile(d.Fs, d.CachePath)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(b, &d.cache); err != nil {
+		// the cache gets rebuilt
+		d.cache = map[string]describeCacheEntry{}
+	}
+}
+
+func (d *Describer) save() error {
+	b, err := json.Marshal(d.cache)
+	if err != nil {
+		return err
+	}
+	if err := d.Fs.MkdirAll(filepath.Dir(d.CachePath), 0755); err != nil {
+		return errors.Wrap(err, "failed to create the cache directory")
+	}
+	return afero.WriteFile(d.Fs, d.CachePath, b, 0644)
+}
@@ -0,0 +1,118 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// describeScript counts its invocations in the file 'calls' next to it
+const describeScript = `#!/bin/sh
+echo x >> "$(dirname "$0")/calls"
+if [ "$1" = "__devctl_describe" ]; then
+  echo '{"name": "foo", "version": "v1.2.3", "short": "manages foos", "completion": true, "commands": [{"name": "list", "flags": [{"name": "all", "type": "bool"}]}]}'
+  exit 0
+fi
+exit 1
+`
+
+func writeExecutable(t *testing.T, path, content string) {
+	t.Helper()
+	writeFile(t, path, content)
+	require.NoError(t, os.Chmod(path, 0755))
+}
+
+func calls(t *testing.T, dir string) int {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if os.IsNotExist(err) {
+		return 0
+	}
+	require.NoError(t, err)
+	return strings.Count(string(b), "x")
+}
+
+func TestDescriber_Describe(t *testing.T) {
+	base, err := ioutil.TempDir("", "devctl-describe")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(base) })
+
+	bin := filepath.Join(base, "path", "devctl-foo")
+	writeExecutable(t, bin, describeScript)
+	sut := NewDescriber(env.NewFactory(env.WithPaths(base)))
+
+	desc, err := sut.Describe(bin)
+	require.NoError(t, err)
+	require.NotNil(t, desc)
+	assert.Equal(t, "foo", desc.Name)
+	assert.Equal(t, "v1.2.3", desc.Version)
+	assert.True(t, desc.Completion)
+	assert.Equal(t, "all", desc.Commands[0].Flags[0].Name)
+
+	// the description is cached on disk
+	_, err = NewDescriber(env.NewFactory(env.WithPaths(base))).Describe(bin)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls(t, filepath.Dir(bin)))
+
+	// changing the executable invalidates the cache
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(bin, later, later))
+	_, err = sut.Describe(bin)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls(t, filepath.Dir(bin)))
+}
+
+func TestDescriber_Unsupported(t *testing.T) {
+	base, err := ioutil.TempDir("", "devctl-describe")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(base) })
+
+	bin := filepath.Join(base, "path", "devctl-bar")
+	writeExecutable(t, bin, "#!/bin/sh\necho x >> \"$(dirname \"$0\")/calls\"\necho not json\n")
+	sut := NewDescriber(env.NewFactory(env.WithPaths(base)))
+
+	for i := 0; i < 2; i++ {
+		desc, err := sut.Describe(bin)
+		require.NoError(t, err)
+		assert.Nil(t, desc)
+	}
+	_, err = NewDescriber(env.NewFactory(env.WithPaths(base))).Describe(bin)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls(t, filepath.Dir(bin)), "the lack of a description is cached on disk as well")
+}
+
+func TestRegistry_External(t *testing.T) {
+	sut, base := newTestRegistry(t)
+	dir := filepath.Join(base, "path")
+	writeExecutable(t, filepath.Join(dir, "devctl-foo"), describeScript)
+	// kubectx has been installed from an index
+	writeExecutable(t, filepath.Join(base, "bin", "devctl-kubectx"), "#!/bin/sh\n")
+
+	path := os.Getenv("PATH")
+	require.NoError(t, os.Setenv("PATH", dir+string(os.PathListSeparator)+filepath.Join(base, "bin")))
+	t.Cleanup(func() { _ = os.Setenv("PATH", path) })
+
+	external, err := sut.External()
+	require.NoError(t, err)
+	require.Len(t, external, 1)
+	assert.Equal(t, "foo", external[0].Name)
+	assert.Equal(t, KindExternal, external[0].Kind)
+	assert.Nil(t, external[0].Description)
+	assert.Equal(t, 0, calls(t, dir), "listing doesn't invoke the executables")
+
+	sut.Describe(external[0])
+	assert.Equal(t, "manages foos", external[0].ShortDescription)
+	assert.Equal(t, "v1.2.3", external[0].Version)
+
+	e, err := sut.Get("foo")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "devctl-foo"), e.Path)
+	assert.NotNil(t, e.Description)
+}
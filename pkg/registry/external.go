@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// PathPlugin is an executable named PREFIX-NAME found on the PATH
+type PathPlugin struct {
+	Name string
+	Path string
+}
+
+// PathPlugins returns the executables on PATH starting with 'prefix-', sorted by their names.
+// Executables shadowed by an earlier PATH entry are omitted.
+func PathPlugins(prefix string) (plugins []PathPlugin) {
+	for _, c := range ScanPath(prefix) {
+		if c.Executable && c.ShadowedBy == "" {
+			plugins = append(plugins, PathPlugin{Name: c.Name, Path: c.Path})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// PathCandidate is a file on the PATH named like a plugin
+type PathCandidate struct {
+	Prefix string
+	// Name is the name of the file without the prefix and the extension
+	Name       string
+	Path       string
+	Executable bool
+	// ShadowedBy is the path of the executable with the same name in an earlier PATH entry
+	ShadowedBy string
+}
+
+// ScanPath returns all files on the PATH starting with one of the prefixes followed by '-',
+// in the order of the PATH. Directories listed multiple times in the PATH are scanned once.
+func ScanPath(prefixes ...string) (candidates []PathCandidate) {
+	scanned := map[string]bool{}
+	found := map[string]string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" || scanned[filepath.Clean(dir)] {
+			continue
+		}
+		scanned[filepath.Clean(dir)] = true
+
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			for _, prefix := range prefixes {
+				if !strings.HasPrefix(e.Name(), prefix+"-") {
+					continue
+				}
+				name := strings.TrimSuffix(strings.TrimPrefix(e.Name(), prefix+"-"), filepath.Ext(e.Name()))
+				if name == "" {
+					continue
+				}
+				c := PathCandidate{
+					Prefix:     prefix,
+					Name:       name,
+					Path:       filepath.Join(dir, e.Name()),
+					Executable: isExecutable(filepath.Join(dir, e.Name())),
+				}
+				key := prefix + "-" + name
+				c.ShadowedBy = found[key]
+				if c.Executable && c.ShadowedBy == "" {
+					found[key] = c.Path
+				}
+				candidates = append(candidates, c)
+				break
+			}
+		}
+	}
+	return candidates
+}
+
+// isExecutable follows symlinks, as plugins are often linked into a PATH directory
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil || fi.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(path))
+		return ext == ".exe" || ext == ".bat" || ext == ".cmd"
+	}
+	return fi.Mode()&0111 != 0
+}
+
+// External returns the devctl-* executables on PATH, which have not been installed from an index.
+// The executables are not invoked, use Describe to attach the descriptions they provide.
+func (r *Registry) External() ([]*Entry, error) {
+	installed, err := r.Installed()
+	if err != nil {
+		return nil, err
+	}
+	paths := map[string]bool{}
+	for _, e := range installed {
+		paths[e.Path] = true
+	}
+
+	var entries []*Entry
+	for _, p := range PathPlugins("devctl") {
+		if paths[p.Path] {
+			continue
+		}
+		entries = append(entries, r.newExternalEntry(p))
+	}
+	return entries, nil
+}
+
+func (r *Registry) newExternalEntry(p PathPlugin) *Entry {
+	return &Entry{
+		Name:      p.Name,
+		Index:     PathIndex,
+		Kind:      KindExternal,
+		Installed: true,
+		Supported: true,
+		Path:      p.Path,
+	}
+}
+
+// Describe attaches the Description of the executable of e, if it provides one.
+// The executable is invoked with DescribeArg, unless its description, or the lack of one, has been cached.
+func (r *Registry) Describe(e *Entry) {
+	desc, err := r.describer.Describe(e.Path)
+	if err != nil || desc == nil {
+		return
+	}
+	e.Description = desc
+	if e.Version == "" {
+		e.Version = desc.Version
+	}
+	if e.ShortDescription == "" {
+		e.ShortDescription = desc.Short
+	}
+}
@@ -0,0 +1,43 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanPath(t *testing.T) {
+	base, err := ioutil.TempDir("", "devctl-scan")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(base) })
+
+	first, second := filepath.Join(base, "first"), filepath.Join(base, "second")
+	writeFile(t, filepath.Join(first, "devctl-noexec"), "")
+	writeExecutable(t, filepath.Join(first, "devctl-foo"), "#!/bin/sh\n")
+	writeExecutable(t, filepath.Join(second, "devctl-foo"), "#!/bin/sh\n")
+	writeExecutable(t, filepath.Join(second, "devctl-noexec"), "#!/bin/sh\n")
+	writeExecutable(t, filepath.Join(second, "other-tool"), "#!/bin/sh\n")
+
+	path := os.Getenv("PATH")
+	require.NoError(t, os.Setenv("PATH", strings.Join([]string{first, second, first}, string(os.PathListSeparator))))
+	t.Cleanup(func() { _ = os.Setenv("PATH", path) })
+
+	candidates := ScanPath("devctl")
+	assert.Equal(t, []PathCandidate{
+		{Prefix: "devctl", Name: "foo", Path: filepath.Join(first, "devctl-foo"), Executable: true},
+		{Prefix: "devctl", Name: "noexec", Path: filepath.Join(first, "devctl-noexec")},
+		{Prefix: "devctl", Name: "foo", Path: filepath.Join(second, "devctl-foo"), Executable: true, ShadowedBy: filepath.Join(first, "devctl-foo")},
+		// a non-executable file doesn't shadow an executable
+		{Prefix: "devctl", Name: "noexec", Path: filepath.Join(second, "devctl-noexec"), Executable: true},
+	}, candidates)
+
+	assert.Equal(t, []PathPlugin{
+		{Name: "foo", Path: filepath.Join(first, "devctl-foo")},
+		{Name: "noexec", Path: filepath.Join(second, "devctl-noexec")},
+	}, PathPlugins("devctl"))
+}
@@ -0,0 +1,33 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/alex-held/devctl/pkg/cli/util"
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+// PluginHandler dispatches to the binary plugins installed into the bin directory,
+// before falling back to the devctl-* executables found on the PATH.
+type PluginHandler struct {
+	util.PluginHandler
+	BinPath string
+}
+
+// NewPluginHandler returns a PluginHandler, which falls back to fallback for plugins not installed into the bin directory
+func NewPluginHandler(paths env.Paths, fallback util.PluginHandler) util.PluginHandler {
+	return &PluginHandler{
+		PluginHandler: fallback,
+		BinPath:       paths.BinPath(),
+	}
+}
+
+// Lookup returns the path of the executable of the plugin name
+func (h *PluginHandler) Lookup(name string) (executablePath string, ok bool) {
+	path := filepath.Join(h.BinPath, "devctl-"+name)
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() && fi.Mode()&0111 != 0 {
+		return path, true
+	}
+	return h.PluginHandler.Lookup(name)
+}
@@ -0,0 +1,338 @@
+// Package registry combines the plugins offered by the plugin indexes, the plugins installed from them
+// and the interpreted plugins of the plugin directory into a single view.
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/alex-held/devctl/pkg/constants"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/scanner"
+	"github.com/alex-held/devctl/pkg/index/spec"
+	"github.com/alex-held/devctl/pkg/plugins"
+)
+
+// Kind describes how a plugin gets executed
+type Kind string
+
+const (
+	// KindBinary plugins are executables, which are linked into the bin directory as devctl-NAME
+	KindBinary Kind = "binary"
+	// KindInterpreted plugins are go packages in the plugin directory, which are executed by the plugin engine
+	KindInterpreted Kind = "interpreted"
+	// KindExternal plugins are devctl-NAME executables on the PATH, which have not been installed from an index
+	KindExternal Kind = "external"
+)
+
+// LocalIndex is the index of interpreted plugins, which have been placed into the plugin directory by hand
+const LocalIndex = "local"
+
+// PathIndex is the index of external plugins found on the PATH
+const PathIndex = "path"
+
+// ErrNotFound is returned by Get, if no plugin with the name is known
+var ErrNotFound = errors.New("plugin not found")
+
+// Entry is a plugin known to the registry
+type Entry struct {
+	Name string
+	// Index is the name of the index the plugin is available from or has been installed from
+	Index            string
+	Kind             Kind
+	Version          string
+	ShortDescription string
+
+	// Installed is set, if the plugin is installed
+	Installed bool
+	// Supported is set, if the plugin can be installed on the current platform
+	Supported bool
+	// Path is where the installed plugin is linked, i.e. the executable or the package directory
+	Path string
+
+	// Spec is the manifest of the plugin in its index
+	Spec *spec.Plugin
+	// Receipt is set, if the plugin has been installed from an index
+	Receipt *spec.Receipt
+	// Plugin is set for installed interpreted plugins, which have been loaded successfully
+	Plugin *plugins.Plugin
+	// Description is set for executables, which describe themselves when invoked with DescribeArg
+	Description *Description
+}
+
+// CanonicalName returns INDEX/NAME, even if the plugin is part of the default index
+func (e *Entry) CanonicalName() string {
+	return e.Index + "/" + e.Name
+}
+
+// DisplayName returns the name of the plugin, which is qualified by its index unless it is the default index
+func (e *Entry) DisplayName() string {
+	if e.Index == constants.DefaultIndexName || e.Index == LocalIndex || e.Index == PathIndex {
+		return e.Name
+	}
+	return e.CanonicalName()
+}
+
+// Upgradable returns true, if the index offers a different version than the installed one
+func (e *Entry) Upgradable() bool {
+	return e.Installed && e.Receipt != nil && e.Spec != nil && e.Spec.Spec.Version != e.Receipt.Spec.Version
+}
+
+// Registry is the single source of truth about plugins for listing, searching, installing and dispatching them
+type Registry struct {
+	f         env.Factory
+	engine    *plugins.Engine
+	describer *Describer
+}
+
+// New returns a Registry, which loads interpreted plugins using engine
+func New(f env.Factory, engine *plugins.Engine) *Registry {
+	return &Registry{f: f, engine: engine, describer: NewDescriber(f)}
+}
+
+// NewEngine returns a plugins.Engine loading interpreted plugins using the factory
+func NewEngine(f env.Factory) *plugins.Engine {
+	return plugins.NewEngine(func(c *plugins.Config) *plugins.Config {
+		c.Out = f.Streams().Out
+		c.Fs = f.Fs()
+		c.Pather = f.Pather()
+		return c
+	})
+}
+
+// Installed returns all installed plugins sorted by their names
+func (r *Registry) Installed() ([]*Entry, error) {
+	receipts, err := installation.GetInstalledPluginReceipts(r.f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load installed plugins")
+	}
+
+	interpreted := map[string]*plugins.Plugin{}
+	for _, p := range r.engine.LoadPlugins() {
+		interpreted[filepath.Base(p.RootPath)] = p
+	}
+
+	var entries []*Entry
+	for i := range receipts {
+		receipt := receipts[i]
+		e := &Entry{
+			Name:             receipt.Name,
+			Index:            indexOf(receipt),
+			Kind:             KindBinary,
+			Version:          receipt.Spec.Version,
+			ShortDescription: receipt.Spec.ShortDescription,
+			Installed:        true,
+			Receipt:          &receipt,
+		}
+		platform, ok, err := installation.GetMatchingPlatform(receipt.Spec.Platforms)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the matching platform for plugin %s", receipt.Name)
+		}
+		e.Supported = ok
+		if platform.IsInterpreted() {
+			e.Kind = KindInterpreted
+			e.Plugin = interpreted[receipt.Name]
+			delete(interpreted, receipt.Name)
+		}
+		e.Path = installation.LinkPath(r.f, receipt.Name, platform)
+		entries = append(entries, e)
+	}
+
+	for dir, p := range interpreted {
+		entries = append(entries, &Entry{
+			Name:             dir,
+			Index:            LocalIndex,
+			Kind:             KindInterpreted,
+			Version:          p.Version,
+			ShortDescription: firstLine(p.Help),
+			Installed:        true,
+			Supported:        true,
+			Path:             p.RootPath,
+			Plugin:           p,
+		})
+	}
+
+	sortEntries(entries)
+	return entries, nil
+}
+
+// Index returns the plugins of all indexes together with their installation state, sorted by their names
+func (r *Registry) Index() ([]*Entry, error) {
+	installed, err := r.Installed()
+	if err != nil {
+		return nil, err
+	}
+	return r.index(installed)
+}
+
+func (r *Registry) index(installed []*Entry) ([]*Entry, error) {
+	indexes, err := scanner.IndexNames(r.f.Paths())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to list indexes")
+	}
+	indexes = r.profileIndexes(indexes)
+
+	byName := make(map[string]*Entry, len(installed))
+	for _, e := range installed {
+		byName[e.CanonicalName()] = e
+	}
+
+	var entries []*Entry
+	for _, index := range indexes {
+		ps, err := scanner.LoadPluginsFromFS(r.f, index)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load the list of plugins from the index %q", index)
+		}
+		for i := range ps {
+			e, err := newIndexEntry(index, ps[i])
+			if err != nil {
+				return nil, err
+			}
+			if inst, ok := byName[e.CanonicalName()]; ok {
+				e.Installed = true
+				e.Path = inst.Path
+				e.Receipt = inst.Receipt
+				e.Plugin = inst.Plugin
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	sortEntries(entries)
+	return entries, nil
+}
+
+// profileIndexes restricts indexes to the ones of the active profile, if it selects any
+func (r *Registry) profileIndexes(indexes []string) []string {
+	file, err := r.f.Config()
+	if err != nil {
+		// reported by the 'devctl config' commands
+		return indexes
+	}
+	_, p, ok := file.Config.ActiveProfile()
+	if !ok || len(p.Indexes) == 0 {
+		return indexes
+	}
+
+	selected := make(map[string]bool, len(p.Indexes))
+	for _, index := range p.Indexes {
+		selected[index] = true
+	}
+	var filtered []string
+	for _, index := range indexes {
+		if selected[index] {
+			filtered = append(filtered, index)
+		}
+	}
+	return filtered
+}
+
+// All returns the plugins of all indexes and the installed plugins, which are not available from any index
+func (r *Registry) All() ([]*Entry, error) {
+	installed, err := r.Installed()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := r.index(installed)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		known[e.CanonicalName()] = true
+	}
+	for _, e := range installed {
+		if !known[e.CanonicalName()] {
+			entries = append(entries, e)
+		}
+	}
+
+	sortEntries(entries)
+	return entries, nil
+}
+
+// Get returns the plugin with name, which may be qualified by its index as INDEX/NAME.
+// If the plugin is offered by multiple indexes, the installed one or the one of the default index is returned.
+// Plugins not known to any index are looked up on the PATH last.
+func (r *Registry) Get(name string) (*Entry, error) {
+	entries, err := r.All()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Entry
+	for _, e := range entries {
+		switch {
+		case e.CanonicalName() == name:
+			return e, nil
+		case e.Name != name:
+			continue
+		case found == nil, e.Installed && !found.Installed, e.Index == constants.DefaultIndexName && !found.Installed:
+			found = e
+		}
+	}
+	if found == nil {
+		for _, p := range PathPlugins("devctl") {
+			if p.Name == name || PathIndex+"/"+p.Name == name {
+				e := r.newExternalEntry(p)
+				r.Describe(e)
+				return e, nil
+			}
+		}
+		return nil, errors.Wrapf(ErrNotFound, "no plugin named %q", name)
+	}
+	if found.Installed && found.Kind == KindBinary && found.Description == nil {
+		r.Describe(found)
+	}
+	return found, nil
+}
+
+func newIndexEntry(index string, p spec.Plugin) (*Entry, error) {
+	e := &Entry{
+		Name:             p.Name,
+		Index:            index,
+		Kind:             KindBinary,
+		Version:          p.Spec.Version,
+		ShortDescription: p.Spec.ShortDescription,
+		Spec:             &p,
+	}
+	platform, ok, err := installation.GetMatchingPlatform(p.Spec.Platforms)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the matching platform for plugin %s", p.Name)
+	}
+	if !ok && len(p.Spec.Platforms) > 0 {
+		platform = p.Spec.Platforms[0]
+	}
+	e.Supported = ok
+	if platform.IsInterpreted() {
+		e.Kind = KindInterpreted
+	}
+	return e, nil
+}
+
+// indexOf returns the index name of a receipt.
+func indexOf(r spec.Receipt) string {
+	if r.Status.Source.Name == "" {
+		return constants.DefaultIndexName
+	}
+	return r.Status.Source.Name
+}
+
+func firstLine(s string) string {
+	return strings.TrimSpace(strings.SplitN(strings.TrimSpace(s), "\n", 2)[0])
+}
+
+func sortEntries(entries []*Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Index < entries[j].Index
+	})
+}
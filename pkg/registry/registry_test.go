@@ -0,0 +1,125 @@
+package registry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alex-held/devctl/pkg/env"
+)
+
+const binaryManifest = `apiVersion: alexheld.io/devctl/v1alpha1
+kind: Plugin
+metadata:
+  name: %s
+spec:
+  version: %s
+  shortDescription: a binary plugin
+  platforms:
+  - uri: https://example.com/bin.tar.gz
+    sha256: "0000000000000000000000000000000000000000000000000000000000000000"
+    bin: bin
+    selector: {}
+`
+
+const sourceManifest = `apiVersion: alexheld.io/devctl/v1alpha1
+kind: Plugin
+metadata:
+  name: zsh
+spec:
+  version: v1.0.0
+  shortDescription: an interpreted plugin
+  platforms:
+  - uri: https://example.com/zsh.tar.gz
+    sha256: "0000000000000000000000000000000000000000000000000000000000000000"
+    source: zsh
+    selector: {}
+`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func newTestRegistry(t *testing.T) (*Registry, string) {
+	base, err := ioutil.TempDir("", "devctl-registry")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(base) })
+
+	writeFile(t, filepath.Join(base, "index", "index", "plugins", "kubectx.yaml"), fmt.Sprintf(binaryManifest, "kubectx", "v0.2.0"))
+	writeFile(t, filepath.Join(base, "index", "index", "plugins", "zsh.yaml"), sourceManifest)
+	writeFile(t, filepath.Join(base, "index", "custom", "plugins", "kubectx.yaml"), fmt.Sprintf(binaryManifest, "kubectx", "v0.3.0"))
+
+	// kubectx v0.1.0 has been installed from the default index "index"
+	writeFile(t, filepath.Join(base, "receipts", "kubectx.yaml"), fmt.Sprintf(binaryManifest, "kubectx", "v0.1.0")+"status:\n  source:\n    name: index\n")
+
+	// golang has been placed into the plugin directory by hand
+	writeFile(t, filepath.Join(base, "plugins", "golang", "plugin.yaml"), "version: v0.0.1\nplugin:\n  name: golang\n  pkg: golang\n  cmd: go\n  help: manages go sdks\n")
+	writeFile(t, filepath.Join(base, "plugins", "golang", "main.go"), "package golang\n")
+
+	f := env.NewFactory(env.WithPaths(base))
+	return New(f, NewEngine(f)), base
+}
+
+func TestRegistry_Installed(t *testing.T) {
+	sut, base := newTestRegistry(t)
+
+	installed, err := sut.Installed()
+	require.NoError(t, err)
+	require.Len(t, installed, 2)
+
+	assert.Equal(t, "golang", installed[0].Name)
+	assert.Equal(t, LocalIndex, installed[0].Index)
+	assert.Equal(t, KindInterpreted, installed[0].Kind)
+	assert.Equal(t, "manages go sdks", installed[0].ShortDescription)
+	assert.NotNil(t, installed[0].Plugin)
+
+	assert.Equal(t, "kubectx", installed[1].Name)
+	assert.Equal(t, KindBinary, installed[1].Kind)
+	assert.Equal(t, filepath.Join(base, "bin", "devctl-kubectx"), installed[1].Path)
+}
+
+func TestRegistry_Index(t *testing.T) {
+	sut, _ := newTestRegistry(t)
+
+	entries, err := sut.Index()
+	require.NoError(t, err)
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.DisplayName())
+	}
+	assert.Equal(t, []string{"custom/kubectx", "kubectx", "zsh"}, names)
+
+	assert.False(t, entries[0].Installed)
+	assert.True(t, entries[1].Installed)
+	assert.True(t, entries[1].Upgradable())
+	assert.Equal(t, KindInterpreted, entries[2].Kind)
+	assert.True(t, entries[2].Supported)
+}
+
+func TestRegistry_Get(t *testing.T) {
+	sut, _ := newTestRegistry(t)
+
+	e, err := sut.Get("kubectx")
+	require.NoError(t, err)
+	assert.Equal(t, "index/kubectx", e.CanonicalName())
+	assert.True(t, e.Installed)
+
+	e, err = sut.Get("custom/kubectx")
+	require.NoError(t, err)
+	assert.Equal(t, "v0.3.0", e.Version)
+
+	e, err = sut.Get("golang")
+	require.NoError(t, err)
+	assert.Equal(t, LocalIndex, e.Index)
+
+	_, err = sut.Get("unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,165 @@
+// Package selfupdate installs and updates devctl from the "devctl" manifest of the plugin indexes.
+//
+// devctl is installed like any other binary plugin: its versions are kept in the store and have an install receipt.
+// Instead of bin/devctl-devctl, the current version is linked as bin/devctl, which is replaced atomically on updates.
+package selfupdate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/alex-held/devctl/pkg/constants"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/installation/semver"
+	"github.com/alex-held/devctl/pkg/index/scanner"
+	"github.com/alex-held/devctl/pkg/index/spec"
+	"github.com/alex-held/devctl/pkg/version"
+)
+
+// NoticeEnvVar disables the update notice, if it is set to a non-empty value
+const NoticeEnvVar = "DEVCTL_NO_UPDATE_NOTICE"
+
+// NoticeInterval is the minimum time between two update notices
+const NoticeInterval = 24 * time.Hour
+
+// ErrNotFound is returned by Latest, if no index offers devctl
+var ErrNotFound = errors.New("no plugin index offers devctl")
+
+// Latest returns the devctl manifest and the name of the index offering it.
+// The default index is consulted first, then the other indexes by their names.
+func Latest(f env.Factory) (spec.Plugin, string, error) {
+	names, err := scanner.IndexNames(f.Paths())
+	if err != nil && !os.IsNotExist(err) {
+		return spec.Plugin{}, "", errors.Wrap(err, "failed to list the indexes")
+	}
+	indexes := []string{constants.DefaultIndexName}
+	for _, name := range names {
+		if name != constants.DefaultIndexName {
+			indexes = append(indexes, name)
+		}
+	}
+
+	for _, name := range indexes {
+		p, err := scanner.LoadPluginByName(f, f.Paths().IndexPluginsPath(name), constants.DevctlPluginName)
+		if os.IsNotExist(errors.Cause(err)) {
+			continue
+		}
+		if err != nil {
+			return spec.Plugin{}, "", errors.Wrapf(err, "failed to load the devctl manifest of index %q", name)
+		}
+		return p, name, nil
+	}
+	return spec.Plugin{}, "", ErrNotFound
+}
+
+// Current returns the installed version of devctl. It is the version of the install receipt,
+// or the version of the running binary, if devctl hasn't been installed from an index.
+func Current(f env.Factory) string {
+	r, err := installation.Load(f.Fs(), f.Paths().PluginInstallReceiptPath(constants.DevctlPluginName))
+	if err != nil {
+		return version.Get().Version
+	}
+	return r.Spec.Version
+}
+
+// pseudoVersion matches the versions go assigns to untagged commits, e.g. v0.0.0-20210701120000-0123456789ab
+var pseudoVersion = regexp.MustCompile(`^v\d+\.\d+\.\d+-(.+\.)?(0\.)?\d{14}-[0-9a-f]{12}(\+.*)?$`)
+
+// isDevelopment returns true for versions of development builds, which are either not semantic or pseudo-versions
+func isDevelopment(version string) bool {
+	if _, err := semver.Parse(version); err != nil {
+		return true
+	}
+	return pseudoVersion.MatchString(version)
+}
+
+// IsNewer returns true, if the version of plugin is newer than current.
+// Development builds, whose versions are not semantic or pseudo-versions, are never updated.
+func IsNewer(plugin spec.Plugin, current string) bool {
+	if isDevelopment(current) {
+		return false
+	}
+	cur, err := semver.Parse(current)
+	if err != nil {
+		return false
+	}
+	latest, err := semver.Parse(plugin.Spec.Version)
+	if err != nil {
+		return false
+	}
+	return semver.Less(cur, latest)
+}
+
+// Update installs plugin as the current version of devctl. The previous version is kept in the store for
+// 'devctl history undo', older versions are removed.
+// A devctl, which hasn't been installed from an index yet, gets installed from scratch.
+func Update(f env.Factory, plugin spec.Plugin, indexName string, opts installation.InstallOpts) error {
+	if plugin.Name != constants.DevctlPluginName {
+		return errors.Errorf("manifest of plugin %q can't update devctl", plugin.Name)
+	}
+
+	r, err := installation.Load(f.Fs(), f.Paths().PluginInstallReceiptPath(plugin.Name))
+	switch {
+	case os.IsNotExist(err):
+		// development builds adopt any version
+		running := version.Get().Version
+		if !isDevelopment(running) && !IsNewer(plugin, running) {
+			return installation.ErrIsAlreadyUpgraded
+		}
+		if err := f.Fs().MkdirAll(f.Paths().InstallReceiptsPath(), 0755); err != nil {
+			return errors.Wrap(err, "failed to create the receipts directory")
+		}
+		return installation.Install(f, plugin, indexName, opts)
+	case err != nil:
+		return errors.Wrap(err, "failed to look up the devctl receipt")
+	}
+
+	// removes versions left behind by previous updates, e.g. on windows the running executable can't be removed.
+	// The upgrade keeps the current version, so that it can be undone using 'devctl history undo'.
+	if err = installation.CleanupStaleKrewInstallations(f.Fs(), f.Paths().PluginInstallPath(plugin.Name), r.Spec.Version); err != nil {
+		return err
+	}
+	return installation.Upgrade(f, plugin, indexName, opts)
+}
+
+// Notice returns a message announcing a newer version of devctl, or an empty string.
+// The indexes are consulted at most once per NoticeInterval, the time of the last check is kept in the state directory.
+// Only the local copy of the indexes is consulted, it isn't fetched to keep commands fast and offline.
+func Notice(f env.Factory, now time.Time) string {
+	if os.Getenv(NoticeEnvVar) != "" {
+		return ""
+	}
+	current := Current(f)
+	if isDevelopment(current) {
+		return ""
+	}
+
+	path := f.Paths().StatePath("update-check")
+	if b, err := ioutil.ReadFile(path); err == nil {
+		last, err := time.Parse(time.RFC3339, strings.TrimSpace(string(b)))
+		if err == nil && now.Sub(last) < NoticeInterval {
+			return ""
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return ""
+	}
+	if err := ioutil.WriteFile(path, []byte(now.UTC().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		return ""
+	}
+
+	latest, _, err := Latest(f)
+	if err != nil || !IsNewer(latest, current) {
+		return ""
+	}
+	return fmt.Sprintf("A new version of devctl is available: %s -> %s\nRun 'devctl self-update' to update, set %s=1 to disable this notice.",
+		current, latest.Spec.Version, NoticeEnvVar)
+}
@@ -0,0 +1,136 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alex-held/devctl/pkg/constants"
+	"github.com/alex-held/devctl/pkg/env"
+	"github.com/alex-held/devctl/pkg/index/installation"
+	"github.com/alex-held/devctl/pkg/index/spec"
+)
+
+// release writes an archive containing a devctl executable and returns the manifest of version
+func release(t *testing.T, dir, version string) (spec.Plugin, string) {
+	b := &bytes.Buffer{}
+	gz := gzip.NewWriter(b)
+	tw := tar.NewWriter(gz)
+	content := []byte("#!/bin/sh\necho " + version + "\n")
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "devctl", Mode: 0755, Size: int64(len(content))}))
+	_, err := tw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	archive := filepath.Join(dir, "devctl-"+version+".tar.gz")
+	require.NoError(t, ioutil.WriteFile(archive, b.Bytes(), 0644))
+	sum := sha256.Sum256(b.Bytes())
+
+	p := spec.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: constants.DevctlPluginName},
+		Spec: spec.PluginSpec{
+			Version: version,
+			Platforms: []spec.Platform{{
+				URI:      "https://example.com/devctl.tar.gz",
+				Sha256:   hex.EncodeToString(sum[:]),
+				Selector: &metav1.LabelSelector{},
+				Bin:      "devctl",
+			}},
+		},
+	}
+	return p, archive
+}
+
+func TestUpdate(t *testing.T) {
+	root, err := ioutil.TempDir("", "devctl-selfupdate")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	f := env.NewFactory(env.WithPaths(root))
+	link := filepath.Join(f.Paths().BinPath(), "devctl")
+
+	v1, archive := release(t, root, "v1.0.0")
+	require.NoError(t, Update(f, v1, constants.DefaultIndexName, installation.InstallOpts{ArchiveFileOverride: archive}))
+	target, err := os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, f.Paths().PluginVersionInstallPath("devctl", "v1.0.0"), filepath.Dir(target))
+	assert.Equal(t, "v1.0.0", Current(f))
+
+	v2, archive := release(t, root, "v1.1.0")
+	require.NoError(t, Update(f, v2, constants.DefaultIndexName, installation.InstallOpts{ArchiveFileOverride: archive}))
+	target, err = os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, f.Paths().PluginVersionInstallPath("devctl", "v1.1.0"), filepath.Dir(target))
+	assert.Equal(t, "v1.1.0", Current(f))
+	assert.DirExists(t, f.Paths().PluginVersionInstallPath("devctl", "v1.0.0"), "the previous version is kept for undo")
+
+	v3, archive := release(t, root, "v1.2.0")
+	require.NoError(t, Update(f, v3, constants.DefaultIndexName, installation.InstallOpts{ArchiveFileOverride: archive}))
+	assert.Equal(t, "v1.2.0", Current(f))
+	assert.DirExists(t, f.Paths().PluginVersionInstallPath("devctl", "v1.1.0"))
+	assert.NoDirExists(t, f.Paths().PluginVersionInstallPath("devctl", "v1.0.0"))
+
+	require.NoError(t, installation.Rollback(f, "devctl", "v1.1.0"))
+	target, err = os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, f.Paths().PluginVersionInstallPath("devctl", "v1.1.0"), filepath.Dir(target))
+	assert.Equal(t, "v1.1.0", Current(f))
+	assert.Error(t, installation.Rollback(f, "devctl", "v1.0.0"), "v1.0.0 is no longer in the store")
+
+	err = Update(f, v1, constants.DefaultIndexName, installation.InstallOpts{ArchiveFileOverride: archive})
+	assert.Equal(t, installation.ErrIsAlreadyUpgraded, err)
+
+	_, err = os.Lstat(filepath.Join(f.Paths().BinPath(), ".devctl.tmp"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestIsNewer(t *testing.T) {
+	latest := spec.Plugin{}
+	latest.Spec.Version = "v1.2.0"
+
+	assert.True(t, IsNewer(latest, "v1.0.0"))
+	assert.False(t, IsNewer(latest, "v1.2.0"))
+	for _, dev := range []string{"dev", "v0.0.0-20260101120000-0123456789ab", "v1.1.1-0.20260101120000-0123456789ab", "v1.1.0-rc.1.0.20260101120000-0123456789ab"} {
+		assert.False(t, IsNewer(latest, dev), dev)
+	}
+}
+
+func TestNotice(t *testing.T) {
+	root, err := ioutil.TempDir("", "devctl-selfupdate")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	if v, ok := os.LookupEnv(NoticeEnvVar); ok {
+		os.Unsetenv(NoticeEnvVar)
+		defer os.Setenv(NoticeEnvVar, v)
+	}
+	f := env.NewFactory(env.WithPaths(root))
+
+	v1, archive := release(t, root, "v1.0.0")
+	require.NoError(t, Update(f, v1, constants.DefaultIndexName, installation.InstallOpts{ArchiveFileOverride: archive}))
+
+	now := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	assert.Empty(t, Notice(f, now), "no index offers devctl")
+
+	v2, _ := release(t, root, "v1.2.0")
+	b, err := yaml.Marshal(v2)
+	require.NoError(t, err)
+	manifest := filepath.Join(f.Paths().IndexPluginsPath(constants.DefaultIndexName), "devctl.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(manifest), 0755))
+	require.NoError(t, ioutil.WriteFile(manifest, b, 0644))
+
+	assert.Empty(t, Notice(f, now.Add(time.Hour)), "checked within the interval")
+	assert.Contains(t, Notice(f, now.Add(NoticeInterval)), "v1.0.0 -> v1.2.0")
+	assert.Empty(t, Notice(f, now.Add(NoticeInterval+time.Hour)), "notified within the interval")
+}
@@ -0,0 +1,59 @@
+// Package version holds the build information of the devctl binary. It is set at build time using
+//
+//	go build -ldflags "-X github.com/alex-held/devctl/pkg/version.Version=v0.8.0 -X github.com/alex-held/devctl/pkg/version.GitCommit=$(git rev-parse --short HEAD)" ./cmd/devctl
+//
+// Binaries built without ldflags, e.g. using 'go install', fall back to the module version of their build info.
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set using ldflags
+var (
+	// Version is the released version of devctl, "dev" for builds without version information
+	Version = "dev"
+	// GitCommit is the commit devctl has been built from
+	GitCommit = ""
+	// BuildDate is the time of the build in RFC3339 format
+	BuildDate = ""
+)
+
+// Info is the build information of devctl
+type Info struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit,omitempty"`
+	BuildDate string `json:"buildDate,omitempty"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+// pseudoVersionCommit matches the commit of a pseudo-version like v0.0.0-20210701120000-abcdef123456
+var pseudoVersionCommit = regexp.MustCompile(`[-.]\d{14}-([0-9a-f]{12})(\+incompatible)?$`)
+
+// Get returns the build information set using ldflags, completed by the build info embedded by the go command
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+	}
+	if info.Version != "dev" {
+		return info
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok || bi.Main.Version == "" || bi.Main.Version == "(devel)" {
+		return info
+	}
+	info.Version = bi.Main.Version
+	if m := pseudoVersionCommit.FindStringSubmatch(bi.Main.Version); m != nil && info.GitCommit == "" {
+		info.GitCommit = m[1]
+	}
+	return info
+}
@@ -0,0 +1,37 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	defer func(v, c, d string) { Version, GitCommit, BuildDate = v, c, d }(Version, GitCommit, BuildDate)
+	Version, GitCommit, BuildDate = "v0.8.0", "1a2b3c4", "2021-07-01T12:00:00Z"
+
+	assert.Equal(t, Info{
+		Version:   "v0.8.0",
+		GitCommit: "1a2b3c4",
+		BuildDate: "2021-07-01T12:00:00Z",
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}, Get())
+}
+
+func TestPseudoVersionCommit(t *testing.T) {
+	tests := map[string]string{
+		"v0.0.0-20210701120000-abcdef123456":              "abcdef123456",
+		"v0.8.1-0.20210701120000-abcdef123456":            "abcdef123456",
+		"v2.0.0-20210701120000-abcdef123456+incompatible": "abcdef123456",
+		"v0.8.0": "",
+	}
+	for v, want := range tests {
+		got := ""
+		if m := pseudoVersionCommit.FindStringSubmatch(v); m != nil {
+			got = m[1]
+		}
+		assert.Equal(t, want, got, v)
+	}
+}
@@ -23,8 +23,8 @@ type Config struct {
 type PluginSpec struct {
 	Name     string `yaml:"name"`
 	Enabled  bool   `yaml:"enabled"`
-	Config   string `yaml:"config,omitempty`
-	Manifest string `yaml:"manifest`
+	Config   string `yaml:"config,omitempty"`
+	Manifest string `yaml:"manifest"`
 }
 
 func CreateConfig() *Config {
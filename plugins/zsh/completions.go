@@ -0,0 +1,243 @@
+package zsh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnknownCompletion = errors.New("no completion configured")
+
+const completionCacheFile = ".cache.yaml"
+
+// completionCacheEntry records which binary generated a cached completion script
+type completionCacheEntry struct {
+	Command string    `yaml:"command"`
+	Path    string    `yaml:"path"`
+	ModTime time.Time `yaml:"modtime"`
+}
+
+// CompletionCache generates the completion scripts of Completions.CLI into '_<name>' files
+// and regenerates them only when the binary of the tool or the configured command changed
+type CompletionCache struct {
+	Dir string
+
+	LookPath func(file string) (string, error)
+	Run      func(ctx context.Context, path string, args ...string) ([]byte, error)
+
+	entries map[string]completionCacheEntry
+}
+
+func NewCompletionCache(dir string) *CompletionCache {
+	return &CompletionCache{
+		Dir:      dir,
+		LookPath: exec.LookPath,
+		Run: func(ctx context.Context, path string, args ...string) ([]byte, error) {
+			stderr := &bytes.Buffer{}
+			cmd := exec.CommandContext(ctx, path, args...)
+			cmd.Stderr = stderr
+			out, err := cmd.Output()
+			if err != nil && stderr.Len() > 0 {
+				return out, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+			}
+			return out, err
+		},
+	}
+}
+
+// ScriptPath returns the path of the cached completion script of name
+func (c *CompletionCache) ScriptPath(name string) string {
+	return filepath.Join(c.Dir, "_"+name)
+}
+
+func (c *CompletionCache) load() (err error) {
+	if c.entries != nil {
+		return nil
+	}
+	c.entries = map[string]completionCacheEntry{}
+
+	b, err := os.ReadFile(filepath.Join(c.Dir, completionCacheFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(b, &c.entries)
+}
+
+func (c *CompletionCache) save() (err error) {
+	return writeFileAtomic(filepath.Join(c.Dir, completionCacheFile), func(w io.Writer) error {
+		return yaml.NewEncoder(w).Encode(c.entries)
+	})
+}
+
+// current returns the cache entry describing the currently installed binary of name
+func (c *CompletionCache) current(name, command string) (e completionCacheEntry, err error) {
+	path, err := c.LookPath(name)
+	if err != nil {
+		return e, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return e, err
+	}
+	return completionCacheEntry{Command: command, Path: path, ModTime: fi.ModTime().UTC()}, nil
+}
+
+func (c *CompletionCache) isFresh(name string, e completionCacheEntry) bool {
+	cached, ok := c.entries[name]
+	if !ok || cached.Command != e.Command || cached.Path != e.Path || !cached.ModTime.Equal(e.ModTime) {
+		return false
+	}
+	_, err := os.Stat(c.ScriptPath(name))
+	return err == nil
+}
+
+// Stale returns the names of all completions, which would be regenerated by Refresh
+func (c *CompletionCache) Stale(cli map[string]string) (names []string, err error) {
+	if err = c.load(); err != nil {
+		return nil, err
+	}
+	for _, name := range sortedKeys(cli) {
+		e, err := c.current(name, cli[name])
+		if err != nil {
+			continue
+		}
+		if !c.isFresh(name, e) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Refresh generates the completion scripts of the named tools, or of all tools if no names are provided.
+// Unless force is set, scripts are only regenerated if they are stale.
+// Tools which are not found keep their cached script, tools removed from cli get it removed.
+func (c *CompletionCache) Refresh(ctx context.Context, cli map[string]string, force bool, names ...string) (refreshed []string, err error) {
+	if err = c.load(); err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for name := range c.entries {
+		if _, ok := cli[name]; !ok {
+			delete(c.entries, name)
+			_ = os.Remove(c.ScriptPath(name))
+		}
+	}
+
+	if len(names) == 0 {
+		names = sortedKeys(cli)
+	}
+
+	var errs []string
+	for _, name := range names {
+		command, ok := cli[name]
+		if !ok {
+			return refreshed, fmt.Errorf("%w for '%s'", ErrUnknownCompletion, name)
+		}
+
+		e, err := c.current(name, command)
+		if err != nil {
+			continue
+		}
+
+		if !force && c.isFresh(name, e) {
+			continue
+		}
+
+		args, err := splitArgs(command)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s %s: %v", name, command, err))
+			continue
+		}
+		out, err := c.Run(ctx, e.Path, args...)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s %s: %v", name, command, err))
+			continue
+		}
+
+		err = writeFileAtomic(c.ScriptPath(name), func(w io.Writer) error {
+			_, err := w.Write(out)
+			return err
+		})
+		if err != nil {
+			return refreshed, err
+		}
+
+		c.entries[name] = e
+		refreshed = append(refreshed, name)
+	}
+
+	if err = c.save(); err != nil {
+		return refreshed, err
+	}
+	if len(errs) > 0 {
+		return refreshed, fmt.Errorf("failed to generate completions:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return refreshed, nil
+}
+
+// splitArgs splits command into arguments like a shell, honoring single and double quotes and backslash escapes
+func splitArgs(command string) (args []string, err error) {
+	var (
+		arg     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range command {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", command)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+func sortedKeys(m map[string]string) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,102 @@
+package zsh
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionCache_Refresh(t *testing.T) {
+	binDir := t.TempDir()
+	kubectl := filepath.Join(binDir, "kubectl")
+	assert.NoError(t, os.WriteFile(kubectl, []byte("#!/bin/sh"), 0755))
+
+	var runs []string
+	cache := NewCompletionCache(t.TempDir())
+	cache.LookPath = func(file string) (string, error) {
+		if file == "kubectl" {
+			return kubectl, nil
+		}
+		return "", errors.New("not found")
+	}
+	cache.Run = func(_ context.Context, path string, args ...string) ([]byte, error) {
+		runs = append(runs, path+" "+strings.Join(args, " "))
+		return []byte("#compdef kubectl\n"), nil
+	}
+
+	cli := map[string]string{"kubectl": "completion zsh", "gh": "completion -s zsh"}
+	ctx := context.Background()
+
+	refreshed, err := cache.Refresh(ctx, cli, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"kubectl"}, refreshed)
+	assert.Equal(t, []string{kubectl + " completion zsh"}, runs)
+	assert.FileExists(t, cache.ScriptPath("kubectl"))
+	assert.NoFileExists(t, cache.ScriptPath("gh"))
+
+	// fresh scripts are not regenerated, even by a new cache reading the persisted state
+	reloaded := NewCompletionCache(cache.Dir)
+	reloaded.LookPath, reloaded.Run = cache.LookPath, cache.Run
+	refreshed, err = reloaded.Refresh(ctx, cli, false)
+	assert.NoError(t, err)
+	assert.Empty(t, refreshed)
+	assert.Len(t, runs, 1)
+
+	// a changed binary marks the script stale
+	later := time.Now().Add(time.Hour)
+	assert.NoError(t, os.Chtimes(kubectl, later, later))
+	stale, err := reloaded.Stale(cli)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"kubectl"}, stale)
+
+	refreshed, err = reloaded.Refresh(ctx, cli, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"kubectl"}, refreshed)
+	assert.Len(t, runs, 2)
+
+	// force regenerates fresh scripts
+	refreshed, err = reloaded.Refresh(ctx, cli, true, "kubectl")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"kubectl"}, refreshed)
+	assert.Len(t, runs, 3)
+
+	_, err = reloaded.Refresh(ctx, cli, true, "unknown")
+	assert.ErrorIs(t, err, ErrUnknownCompletion)
+
+	// a tool missing from the PATH keeps its script, e.g. when refreshing with a smaller PATH
+	reloaded.LookPath = func(file string) (string, error) { return "", errors.New("not found") }
+	refreshed, err = reloaded.Refresh(ctx, cli, false)
+	assert.NoError(t, err)
+	assert.Empty(t, refreshed)
+	assert.FileExists(t, reloaded.ScriptPath("kubectl"))
+
+	// a tool removed from the config gets its script removed
+	refreshed, err = reloaded.Refresh(ctx, map[string]string{"gh": "completion -s zsh"}, false)
+	assert.NoError(t, err)
+	assert.Empty(t, refreshed)
+	assert.NoFileExists(t, reloaded.ScriptPath("kubectl"))
+}
+
+func TestSplitArgs(t *testing.T) {
+	tts := map[string][]string{
+		"completion zsh":                    {"completion", "zsh"},
+		"  completion   -s\tzsh ":           {"completion", "-s", "zsh"},
+		`completion --prefix 'my tool' zsh`: {"completion", "--prefix", "my tool", "zsh"},
+		`completion --name "say \"hi\"" ''`: {"completion", "--name", `say "hi"`, ""},
+		`completion my\ tool`:               {"completion", "my tool"},
+	}
+	for command, expected := range tts {
+		actual, err := splitArgs(command)
+		assert.NoError(t, err, command)
+		assert.Equal(t, expected, actual, command)
+	}
+
+	_, err := splitArgs(`completion 'zsh`)
+	assert.Error(t, err)
+}
@@ -23,6 +23,10 @@ type Config struct {
 	Exports          map[string]string `yaml:"exports,omitempty"`
 	Aliases          map[string]string `yaml:"aliases,omitempty"`
 	Completions      CompletionsSpec   `yaml:"completions,omitempty"`
+
+	// DeferExpansion leaves references to exports and environment variables
+	// for the shell to expand instead of resolving them during generation
+	DeferExpansion bool `yaml:"defer_expansion,omitempty"`
 }
 
 func ReadConfigFile(r io.Reader) (f *Config, err error) {
@@ -46,6 +50,8 @@ func (c *Config) DeepEqual(other *Config) bool {
 		Exports:     c.Exports,
 		Aliases:     c.Aliases,
 		Completions: c.Completions,
+
+		DeferExpansion: c.DeferExpansion,
 	}
 
 	otherC := Config{
@@ -54,6 +60,8 @@ func (c *Config) DeepEqual(other *Config) bool {
 		Exports:     other.Exports,
 		Aliases:     other.Aliases,
 		Completions: other.Completions,
+
+		DeferExpansion: other.DeferExpansion,
 	}
 
 	return reflect.DeepEqual(cC, otherC)
@@ -67,7 +75,24 @@ func (c *Config) TemplateConfigs() (cfgs map[string]interface{}) {
 			CONFIGFILE:      c.Pather.Config("zsh", "config.yaml"),
 			Completions:     c.Completions,
 		},
-		"exports": c.Exports,
-		"aliases": c.Aliases,
+		"exports": ExportsTmplData{
+			Header:         banner.GenerateBanner("Exports", banner.KIND_SHELL),
+			CONFIGFILE:     c.Pather.Config("zsh", "config.yaml"),
+			Vars:           c.Vars,
+			Exports:        c.Exports,
+			DeferExpansion: c.DeferExpansion,
+		},
+		"aliases": AliasesTmplData{
+			Header:     banner.GenerateBanner("Aliases", banner.KIND_SHELL),
+			CONFIGFILE: c.Pather.Config("zsh", "config.yaml"),
+			Aliases:    c.Aliases,
+		},
+		"init": InitTmplData{
+			CONFIGFILE:      c.Pather.Config("zsh", "config.yaml"),
+			INIT_DIR:        c.Pather.Config("zsh", "init.d"),
+			BIN_PATH:        c.Pather.Bin(),
+			COMPLETIONS_DIR: c.Pather.Config("zsh", "completions"),
+			Aliases:         c.Aliases,
+		},
 	}
 }
@@ -0,0 +1,209 @@
+package zsh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var ErrDrift = errors.New("generated files are out of date; run 'devctl zsh gen' to regenerate them")
+
+const contentHashPrefix = "# CONTENT HASH "
+
+// ContentHash returns the hash of generated content as embedded into the fileheader
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ParseContentHash extracts the hash embedded into the fileheader of a generated file
+// and returns the content following the fileheader
+func ParseContentHash(file []byte) (hash string, body []byte, ok bool) {
+	i := bytes.Index(file, []byte(contentHashPrefix))
+	if i < 0 {
+		return "", file, false
+	}
+
+	rest := file[i+len(contentHashPrefix):]
+	eol := bytes.IndexByte(rest, '\n')
+	if eol < 0 {
+		return "", file, false
+	}
+	hash = string(rest[:eol])
+	rest = rest[eol+1:]
+
+	// skip the closing line of the fileheader
+	if eol = bytes.IndexByte(rest, '\n'); eol < 0 {
+		return "", file, false
+	}
+	return hash, rest[eol+1:], true
+}
+
+type DriftState string
+
+const (
+	DriftNone     DriftState = "ok"
+	DriftMissing  DriftState = "missing"
+	DriftModified DriftState = "modified"
+	DriftStale    DriftState = "stale"
+)
+
+// Drift describes how a generated file on disk differs from what would be generated
+type Drift struct {
+	Name  string
+	Path  string
+	State DriftState
+	Diff  string
+}
+
+// HasDrift returns true if the file on disk is not what would be generated
+func (d Drift) HasDrift() bool {
+	return d.State != DriftNone
+}
+
+// checkFile compares the file at path with the output of render
+func checkFile(name, path string, render func(w io.Writer) error) (d Drift, err error) {
+	d = Drift{Name: name, Path: path, State: DriftNone}
+
+	expected := &bytes.Buffer{}
+	if err = render(expected); err != nil {
+		return d, err
+	}
+
+	actual, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		d.State = DriftMissing
+		d.Diff = unifiedDiff(os.DevNull, path, "", expected.String())
+		return d, nil
+	}
+	if err != nil {
+		return d, err
+	}
+
+	if bytes.Equal(actual, expected.Bytes()) {
+		return d, nil
+	}
+
+	d.State = DriftStale
+	if hash, body, ok := ParseContentHash(actual); !ok || hash != ContentHash(body) {
+		d.State = DriftModified
+	}
+	d.Diff = unifiedDiff(path, path+" (generated)", string(actual), expected.String())
+	return d, nil
+}
+
+const diffContextLines = 3
+
+type diffLine struct {
+	op   diffmatchpatch.Operation
+	text string
+}
+
+// unifiedDiff returns the line based difference between a and b in the unified diff format
+func unifiedDiff(fromFile, toFile, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	// encode every distinct line as a single rune, so that the diff is computed line by line
+	var lineArray []string
+	lineIndex := map[string]rune{}
+	toRunes := func(text string) (runes []rune) {
+		for _, l := range strings.SplitAfter(text, "\n") {
+			if l == "" {
+				continue
+			}
+			r, ok := lineIndex[l]
+			if !ok {
+				r = rune(len(lineArray))
+				lineIndex[l] = r
+				lineArray = append(lineArray, l)
+			}
+			runes = append(runes, r)
+		}
+		return runes
+	}
+	ra, rb := toRunes(a), toRunes(b)
+
+	var lines []diffLine
+	for _, d := range diffmatchpatch.New().DiffMainRunes(ra, rb, false) {
+		for _, r := range d.Text {
+			lines = append(lines, diffLine{op: d.Type, text: lineArray[r]})
+		}
+	}
+
+	// line numbers in a and b before each line
+	oldAt := make([]int, len(lines)+1)
+	newAt := make([]int, len(lines)+1)
+	var changes []int
+	for i, l := range lines {
+		oldAt[i+1], newAt[i+1] = oldAt[i], newAt[i]
+		if l.op != diffmatchpatch.DiffInsert {
+			oldAt[i+1]++
+		}
+		if l.op != diffmatchpatch.DiffDelete {
+			newAt[i+1]++
+		}
+		if l.op != diffmatchpatch.DiffEqual {
+			changes = append(changes, i)
+		}
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "--- %s\n+++ %s\n", fromFile, toFile)
+
+	for i := 0; i < len(changes); {
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*diffContextLines {
+			j++
+		}
+
+		start := changes[i] - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := changes[j] + diffContextLines + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		fmt.Fprintf(sb, "@@ -%s +%s @@\n",
+			hunkRange(oldAt[start], oldAt[end]-oldAt[start]),
+			hunkRange(newAt[start], newAt[end]-newAt[start]))
+
+		for _, l := range lines[start:end] {
+			prefix := " "
+			switch l.op {
+			case diffmatchpatch.DiffDelete:
+				prefix = "-"
+			case diffmatchpatch.DiffInsert:
+				prefix = "+"
+			}
+			sb.WriteString(prefix + l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = j + 1
+	}
+
+	return sb.String()
+}
+
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
@@ -0,0 +1,91 @@
+package zsh
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseContentHash(t *testing.T) {
+	in := AliasesTmplData{
+		CONFIGFILE: "/home/user/.devctl/config/zsh/config.yaml",
+		Aliases:    map[string]string{"ll": "ls -la"},
+	}
+
+	actual, err := in.Render()
+	assert.NoError(t, err)
+
+	hash, body, ok := ParseContentHash([]byte(actual))
+	assert.True(t, ok)
+	assert.Equal(t, ContentHash(body), hash)
+	assert.Contains(t, string(body), "alias ll='ls -la'")
+
+	_, _, ok = ParseContentHash([]byte("alias ll='ls -la'\n"))
+	assert.False(t, ok)
+}
+
+func TestCheckFile(t *testing.T) {
+	render := func(aliases map[string]string) func(w io.Writer) error {
+		return func(w io.Writer) error {
+			s, err := AliasesTmplData{Aliases: aliases}.Render()
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, s)
+			return err
+		}
+	}
+	current := render(map[string]string{"ll": "ls -la"})
+	path := filepath.Join(t.TempDir(), "04-aliases.zsh")
+
+	d, err := checkFile("aliases", path, current)
+	assert.NoError(t, err)
+	assert.Equal(t, DriftMissing, d.State)
+	assert.True(t, d.HasDrift())
+
+	assert.NoError(t, writeFileAtomic(path, current))
+	d, err = checkFile("aliases", path, current)
+	assert.NoError(t, err)
+	assert.Equal(t, DriftNone, d.State)
+	assert.Empty(t, d.Diff)
+
+	d, err = checkFile("aliases", path, render(map[string]string{"ll": "ls -lah"}))
+	assert.NoError(t, err)
+	assert.Equal(t, DriftStale, d.State)
+	assert.Contains(t, d.Diff, "-alias ll='ls -la'\n+alias ll='ls -lah'\n")
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, append(b, "alias gs='git status'\n"...), 0644))
+	d, err = checkFile("aliases", path, current)
+	assert.NoError(t, err)
+	assert.Equal(t, DriftModified, d.State)
+	assert.Contains(t, d.Diff, "-alias gs='git status'\n")
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+
+	expected := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+	assert.Equal(t, expected, unifiedDiff("a", "b", a, b))
+	assert.Empty(t, unifiedDiff("a", "b", a, a))
+}
@@ -2,17 +2,29 @@ package zsh
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 
 	"golang.org/x/sync/syncmap"
 )
 
+var (
+	ErrCyclicDependency   = errors.New("cyclic dependency between variables")
+	ErrUnresolvedVariable = errors.New("unable to resolve variable")
+	ErrDuplicateVariable  = errors.New("variable is defined as var and as export")
+)
+
 type resolvable struct {
 	ID    string
 	Value *string
+
+	// deferred contains the variables which are unknown to the ResolveContext
+	// and are left for the shell to expand
+	deferred map[string]bool
 }
 
 func (r *resolvable) Needs() (needs []string) {
@@ -20,16 +32,16 @@ func (r *resolvable) Needs() (needs []string) {
 		return needs
 	}
 	for _, u := range r.Unresolved() {
+		if r.deferred[u] {
+			continue
+		}
 		needs = append(needs, u)
 	}
 	return needs
 }
 
 func (r *resolvable) NeedsVar(key string) bool {
-	if r.IsResolved() {
-		return false
-	}
-	for _, u := range r.Unresolved() {
+	for _, u := range r.Needs() {
 		if u == key {
 			return true
 		}
@@ -40,90 +52,108 @@ func (r *resolvable) NeedsVar(key string) bool {
 func (c *ResolveContext) LoadEnvironmentVariables() {
 	for _, e := range os.Environ() {
 		i := strings.Index(e, "=")
-		key := e[:i]
-		val := e[i+1:]
-		fmt.Printf("load %s=%s\n", key, val)
-		c.env.Store(key, val)
+		if i < 0 {
+			continue
+		}
+		c.env.Store(e[:i], e[i+1:])
 	}
 }
 
-func NewContext(context context.Context) (ctx *ResolveContext) {
+type ResolveOption func(c *ResolveContext)
+
+// WithoutEnvironment does not load the environment variables of the current process,
+// so that references to them are left for the shell to expand
+func WithoutEnvironment() ResolveOption {
+	return func(c *ResolveContext) {
+		c.loadEnv = false
+	}
+}
+
+// WithStrict fails the resolution if a variable references a variable which is
+// neither defined nor part of the environment
+func WithStrict() ResolveOption {
+	return func(c *ResolveContext) {
+		c.strict = true
+	}
+}
+
+func NewContext(context context.Context, opts ...ResolveOption) (ctx *ResolveContext) {
 	ctx = &ResolveContext{
 		env:        syncmap.Map{},
-		resolvedC:  make(chan resolvable),
 		unresolved: map[string]resolvable{},
 		context:    context,
+		loadEnv:    true,
 	}
 
-	ctx.cancelC = context.Done()
+	for _, opt := range opts {
+		opt(ctx)
+	}
 
-	// init os envs
-	ctx.LoadEnvironmentVariables()
+	if ctx.loadEnv {
+		ctx.LoadEnvironmentVariables()
+	}
 
-	/*go func() {
-		select {
-		case r := <-ctx.resolvedC:
-			ctx.ResolveWithNeeds(r.ID)
-		}
-	}()*/
 	return ctx
 }
 
-// func (r *resolvable) resolve2(ctx *ResolveContext) (fullyResolved bool, resolved *resolvable, unresolved []string) {
-// 	if r.IsResolved() {
-// 		return true, r, unresolved
-// 	}
-//
-// 	unres := r.Unresolved()
-// 	resolvedCount := 0
-// 	unresolvedCount := len(unres)
-// 	for _, u := range unres {
-// 		resolved, ok := ctx.Get(u)
-// 		if !ok {
-// 			unresolved = append(unresolved, u)
-// 			// continue here
-// 			unr = append(unr,  ctx.)
-// 			continue
-// 		}
-// 		r.Replace(u, resolved)
-// 		resolvedCount++
-// 		delete(ctx.unresolved, u)
-// 	}
-//
-// 	for i, i2 := range un {
-//
-// 	}
-// 	ctx.env.Store(r.ID, *r)
-// 	return unresolvedCount == resolvedCount, r, unresolved
-// }
+// substitute replaces all variables known to ctx and returns the ones which are not known.
+// A reference of r to itself refers to the value the shell has at that point, e.g. in PATH=$GOBIN:$PATH,
+// so it is always left for the shell to expand.
+func (r *resolvable) substitute(ctx *ResolveContext) (missing []string) {
+	seen := map[string]bool{}
+	for _, u := range r.Unresolved() {
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
 
-func (r *resolvable) resolve(ctx *ResolveContext) (ok bool) {
-	for !r.IsResolved() {
-		unresolved := r.Unresolved()
-		maxRetry := len(unresolved)
-		for i := 0; i < maxRetry; i++ {
-			for _, u := range unresolved {
-				resolved, ok := ctx.Get(u)
-				if !ok {
-					continue
-				}
-				r.Replace(u, resolved)
-			}
+		if u == r.ID {
+			r.deferVar(u)
+			continue
+		}
+
+		resolved, ok := ctx.Get(u)
+		if !ok {
+			missing = append(missing, u)
+			continue
 		}
+		r.Replace(u, resolved)
+	}
+	return missing
+}
+
+func (r *resolvable) resolve(ctx *ResolveContext) (ok bool) {
+	missing := r.substitute(ctx)
+	if len(missing) > 0 && ctx.strict {
 		return false
 	}
 
+	for _, m := range missing {
+		r.deferVar(m)
+	}
+
 	ctx.env.Store(r.ID, *r.Value)
-	ctx.resolvedC <- *r
 	return true
 }
 
+func (r *resolvable) deferVar(key string) {
+	if r.deferred == nil {
+		r.deferred = map[string]bool{}
+	}
+	r.deferred[key] = true
+}
+
 func (r *resolvable) Name() string {
 	return r.ID
 }
 
 func (r *resolvable) IsResolved() bool {
-	return len(r.Unresolved()) == 0
+	for _, u := range r.Unresolved() {
+		if !r.deferred[u] {
+			return false
+		}
+	}
+	return true
 }
 
 const dollarEscapeToken = "{<{__DOLLAR__}>}"
@@ -144,13 +174,13 @@ func (r *resolvable) ReplaceAll(vars map[string]string) {
 
 func (r *resolvable) Replace(key, val string) {
 	reg := getUpdateRegex(key)
-	*r.Value = reg.ReplaceAllString(*r.Value, val)
+	*r.Value = unescapeDollar(reg.ReplaceAllLiteralString(escapeDollar(*r.Value), val))
 }
 
 const envPattern = `(?:\$)(?:[\{]{0,2})(?P<id>[\w_]*)(?:[\}]{0,2})`
 
 func getUpdateRegex(key string) *regexp.Regexp {
-	return regexp.MustCompile(strings.ReplaceAll(envPattern, `(?P<id>[\w_]*)`, fmt.Sprintf("(?P<id>%s)", key)))
+	return regexp.MustCompile(strings.ReplaceAll(envPattern, `(?P<id>[\w_]*)`, fmt.Sprintf(`(?P<id>%s)\b`, regexp.QuoteMeta(key))))
 }
 
 func (r *resolvable) Unresolved() (unresolved []string) {
@@ -173,3 +203,58 @@ func (r *resolvable) Unresolved() (unresolved []string) {
 func (r *resolvable) Resolve(ctx *ResolveContext) (ok bool) {
 	return r.resolve(ctx)
 }
+
+// dependencyOrder returns the ids of defs, ordered so that every definition comes after
+// the definitions it references. A definition referencing itself refers to the outer environment.
+func dependencyOrder(ctx context.Context, defs map[string]resolvable) (order []string, err error) {
+	ids := make([]string, 0, len(defs))
+	for id := range defs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	visited := map[string]bool{}
+
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		if ctx != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if visited[id] {
+			return nil
+		}
+		r, ok := defs[id]
+		if !ok {
+			return nil
+		}
+
+		for i, p := range path {
+			if p == id {
+				return fmt.Errorf("%w: %s", ErrCyclicDependency, strings.Join(append(path[i:], id), " -> "))
+			}
+		}
+		path = append(path[:len(path):len(path)], id)
+
+		needs := r.Needs()
+		sort.Strings(needs)
+		for _, need := range needs {
+			if need == id {
+				continue
+			}
+			if err := visit(need, path); err != nil {
+				return err
+			}
+		}
+
+		visited[id] = true
+		order = append(order, id)
+		return nil
+	}
+
+	for _, id := range ids {
+		if err := visit(id, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
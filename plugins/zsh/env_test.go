@@ -3,6 +3,7 @@ package zsh
 import (
 	"context"
 	"fmt"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -208,3 +209,94 @@ func TestReplace(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveExports(t *testing.T) {
+	vars := map[string]string{
+		"version": "1.17",
+		"sdk":     "$DEVCTL_ROOT/sdks",
+	}
+	exports := map[string]string{
+		"DEVCTL_ROOT": "/home/user/.devctl",
+		"GOROOT":      "${sdk}/go/$version",
+		"GOBIN":       "$GOROOT/bin",
+		"UNKNOWN":     "$DEVCTL_TEST_UNDEFINED/$$ESCAPED",
+	}
+
+	t.Run("expand", func(t *testing.T) {
+		actual, err := ResolveExports(context.Background(), vars, exports, false)
+		assert.NoError(t, err)
+		assert.Equal(t, []Export{
+			{Key: "DEVCTL_ROOT", Value: "/home/user/.devctl"},
+			{Key: "GOROOT", Value: "/home/user/.devctl/sdks/go/1.17"},
+			{Key: "GOBIN", Value: "/home/user/.devctl/sdks/go/1.17/bin"},
+			{Key: "UNKNOWN", Value: "$DEVCTL_TEST_UNDEFINED/$$ESCAPED"},
+		}, actual)
+	})
+
+	t.Run("defer expansion", func(t *testing.T) {
+		actual, err := ResolveExports(context.Background(), vars, exports, true)
+		assert.NoError(t, err)
+		assert.Equal(t, []Export{
+			{Key: "DEVCTL_ROOT", Value: "/home/user/.devctl"},
+			{Key: "GOROOT", Value: "$DEVCTL_ROOT/sdks/go/1.17"},
+			{Key: "GOBIN", Value: "$GOROOT/bin"},
+			{Key: "UNKNOWN", Value: "$DEVCTL_TEST_UNDEFINED/$$ESCAPED"},
+		}, actual)
+	})
+
+	t.Run("self reference", func(t *testing.T) {
+		actual, err := ResolveExports(context.Background(), nil, map[string]string{
+			"PATH":       "$DEVCTL_BIN:$PATH",
+			"DEVCTL_BIN": "/home/user/.devctl/bin",
+		}, true)
+		assert.NoError(t, err)
+		assert.Equal(t, []Export{
+			{Key: "DEVCTL_BIN", Value: "/home/user/.devctl/bin"},
+			{Key: "PATH", Value: "$DEVCTL_BIN:$PATH"},
+		}, actual)
+	})
+
+	t.Run("expand self reference", func(t *testing.T) {
+		defer os.Setenv("PATH", os.Getenv("PATH"))
+		assert.NoError(t, os.Setenv("PATH", "/usr/bin"))
+		actual, err := ResolveExports(context.Background(), map[string]string{"sdk": "/home/user/.devctl/sdks"}, map[string]string{
+			"GOROOT": "$sdk/go",
+			"PATH":   "$GOROOT/bin:${PATH}",
+		}, false)
+		assert.NoError(t, err)
+		assert.Equal(t, []Export{
+			{Key: "GOROOT", Value: "/home/user/.devctl/sdks/go"},
+			{Key: "PATH", Value: "/home/user/.devctl/sdks/go/bin:${PATH}"},
+		}, actual, "the PATH of the generating process is not baked into the exports")
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		_, err := ResolveExports(context.Background(), map[string]string{"a": "a"}, map[string]string{"a": "a"}, false)
+		assert.ErrorIs(t, err, ErrDuplicateVariable)
+	})
+}
+
+func TestResolveExports_Cycle(t *testing.T) {
+	exports := map[string]string{
+		"a": "$b",
+		"b": "${c}/b",
+		"c": "$a/c",
+		"d": "$a",
+	}
+
+	for _, deferExpansion := range []bool{false, true} {
+		_, err := ResolveExports(context.Background(), nil, exports, deferExpansion)
+		assert.ErrorIs(t, err, ErrCyclicDependency)
+		assert.EqualError(t, err, "cyclic dependency between variables: a -> b -> c -> a")
+	}
+}
+
+func TestResolverContext_Strict(t *testing.T) {
+	ctx := NewContext(context.Background(), WithoutEnvironment(), WithStrict())
+
+	v := "$DEVCTL_TEST_UNDEFINED"
+	ctx.Add(resolvable{ID: "a", Value: &v})
+
+	err := ctx.ResolveAll()
+	assert.ErrorIs(t, err, ErrUnresolvedVariable)
+}
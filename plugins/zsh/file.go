@@ -0,0 +1,38 @@
+package zsh
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic renders into a temporary file next to path and renames it onto path afterwards,
+// so that a shell sourcing path never observes a partially written file.
+func writeFileAtomic(path string, render func(w io.Writer) error) (err error) {
+	dir := filepath.Dir(path)
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = render(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
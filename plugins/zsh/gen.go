@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sort"
 	"text/template"
 
 	"github.com/a8m/envsubst/parse"
@@ -19,6 +20,8 @@ import (
 type Generator interface {
 	Completions(w io.Writer) (err error)
 	Exports(w io.Writer) (err error)
+	Aliases(w io.Writer) (err error)
+	Init(w io.Writer) (err error)
 }
 
 type generator struct {
@@ -39,23 +42,35 @@ type GeneratorConfig struct {
 type ResolveContext struct {
 	env syncmap.Map
 
-	resolvedC  chan resolvable
-	cancelC    <-chan struct{}
 	unresolved map[string]resolvable
+	resolved   []string
 	context    context.Context
+	loadEnv    bool
+	strict     bool
 }
 
-// func (c *ResolveContext) ResolveAll() {
-// 	for _, unresolved := range c.unresolved {
-// 		fullyResolved, resolved, unresolved := unresolved.resolve(c)
-// 		if fullyResolved {
-// 			c.env.Store(resolved.ID, resolved)
-// 		}
-// 		for _, unresolved := range unresolved {
-// 			c.resolveRecursive(unresolved)
-// 		}
-// 	}
-// }
+// ResolveAll resolves all added resolvables in the order of their dependencies
+func (c *ResolveContext) ResolveAll() (err error) {
+	order, err := dependencyOrder(c.context, c.unresolved)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range order {
+		r := c.unresolved[id]
+		if !r.resolve(c) {
+			return fmt.Errorf("%w '%s'=%s", ErrUnresolvedVariable, id, *r.Value)
+		}
+		delete(c.unresolved, id)
+		c.resolved = append(c.resolved, id)
+	}
+	return nil
+}
+
+// Resolved returns the ids of all resolved resolvables in the order they got resolved
+func (c *ResolveContext) Resolved() []string {
+	return c.resolved
+}
 
 func (c *ResolveContext) Get(key string) (val string, ok bool) {
 	value, ok := c.env.Load(key)
@@ -68,7 +83,7 @@ func (c *ResolveContext) Get(key string) (val string, ok bool) {
 	return "", false
 }
 
-func (c ResolveContext) ProjectEnv() (env parse.Env) {
+func (c *ResolveContext) ProjectEnv() (env parse.Env) {
 	c.env.Range(func(key, value interface{}) bool {
 		env = append(env, fmt.Sprintf("%v=%v", key, value))
 		return true
@@ -76,7 +91,7 @@ func (c ResolveContext) ProjectEnv() (env parse.Env) {
 	return env
 }
 
-func (c ResolveContext) Env() (env map[string]string) {
+func (c *ResolveContext) Env() (env map[string]string) {
 	env = map[string]string{}
 	c.env.Range(func(key, value interface{}) bool {
 		env[fmt.Sprintf("%v", key)] = fmt.Sprintf("%s", value)
@@ -88,37 +103,14 @@ func (c ResolveContext) Env() (env map[string]string) {
 func (c *ResolveContext) Add(resolvables ...resolvable) {
 	for _, r := range resolvables {
 		if r.IsResolved() {
-			c.env.Store(r.ID, r.Value)
+			c.env.Store(r.ID, *r.Value)
+			c.resolved = append(c.resolved, r.ID)
 			continue
 		}
 		c.unresolved[r.ID] = r
 	}
 }
 
-func (c *ResolveContext) getResolved(id string) (resolved resolvable, ok bool) {
-	c.env.Range(func(key, value interface{}) bool {
-		if r, ok := value.(*resolvable); ok {
-			resolved = *r
-			return false
-		}
-		return true
-	})
-
-	return resolved, ok
-}
-
-func (c *ResolveContext) resolveRecursive(unresolved string) {
-	_, ok := c.getResolved(unresolved)
-	if ok {
-		return
-	}
-	u := c.unresolved[unresolved]
-	needs := u.Needs()
-	for _, need := range needs {
-		c.resolveRecursive(need)
-	}
-}
-
 type Resolvable interface {
 	Name() string
 	IsResolved() bool
@@ -128,35 +120,99 @@ type Resolvable interface {
 
 type Export struct {
 	Key   string
-	Value Resolvable
+	Value string
 }
 
-func (g *generator) GenerateExports(w io.Writer, config *Config, ctx *plugins.Context) (err error) {
-	type tmpl struct {
-		Vars    map[string]string
-		Exports map[string]string
+func newResolvables(m map[string]string) (rs []resolvable) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	// context.WithTimeout(ctx.Context, time.Second)
-	resolverCtx := NewContext(ctx.Context)
-
-	for k, v := range config.Vars {
-		r := resolvable{
+	for _, k := range keys {
+		v := m[k]
+		rs = append(rs, resolvable{
 			ID:    k,
 			Value: &v,
+		})
+	}
+	return rs
+}
+
+// ResolveExports resolves vars and exports and returns the exports in the order of their dependencies.
+//
+// If deferExpansion is set, only vars get substituted; references to other exports and to the
+// environment are left for the shell to expand.
+func ResolveExports(ctx context.Context, vars, exports map[string]string, deferExpansion bool) (resolved []Export, err error) {
+	for k := range exports {
+		if _, ok := vars[k]; ok {
+			return nil, fmt.Errorf("%w: '%s'", ErrDuplicateVariable, k)
 		}
-		if !r.Resolve(resolverCtx) {
-			return errors.New(fmt.Sprintf("unable to resolve '%s'=%s\n", k, v))
+	}
+
+	if deferExpansion {
+		return deferExports(ctx, vars, exports)
+	}
+
+	resolverCtx := NewContext(ctx)
+	resolverCtx.Add(newResolvables(vars)...)
+	resolverCtx.Add(newResolvables(exports)...)
+	if err = resolverCtx.ResolveAll(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range resolverCtx.Resolved() {
+		if _, ok := exports[id]; !ok {
+			continue
 		}
+		v, _ := resolverCtx.Get(id)
+		resolved = append(resolved, Export{Key: id, Value: v})
+	}
+	return resolved, nil
+}
+
+func deferExports(ctx context.Context, vars, exports map[string]string) (resolved []Export, err error) {
+	varsCtx := NewContext(ctx, WithoutEnvironment())
+	varsCtx.Add(newResolvables(vars)...)
+	if err = varsCtx.ResolveAll(); err != nil {
+		return nil, err
 	}
 
-	data := tmpl{
-		Vars:    config.Vars,
-		Exports: config.Exports,
+	defs := map[string]resolvable{}
+	for _, r := range newResolvables(exports) {
+		r := r
+		r.substitute(varsCtx)
+		defs[r.ID] = r
 	}
 
-	err = g.Templates.ExecuteTemplate(w, "exports", data)
-	return err
+	order, err := dependencyOrder(ctx, defs)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range order {
+		resolved = append(resolved, Export{Key: id, Value: *defs[id].Value})
+	}
+	return resolved, nil
+}
+
+func (g *generator) GenerateExports(w io.Writer, config *Config, ctx *plugins.Context) (err error) {
+	data := ExportsTmplData{
+		Vars:           config.Vars,
+		Exports:        config.Exports,
+		DeferExpansion: config.DeferExpansion,
+	}
+	return g.renderExports(w, ctx.Context, data)
+}
+
+func (g *generator) renderExports(w io.Writer, ctx context.Context, data ExportsTmplData) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if data.Resolved, err = ResolveExports(ctx, data.Vars, data.Exports, data.DeferExpansion); err != nil {
+		return err
+	}
+	return renderFile(g.Templates, w, "exports", data.CONFIGFILE, data)
 }
 
 func NewGenerator(opts ...Option) Generator {
@@ -181,13 +237,21 @@ func NewGenerator(opts ...Option) Generator {
 }
 
 func (g *generator) Completions(w io.Writer) (err error) {
-	const name = "completions"
-	data := g.TemplateConfigs[name]
-	return g.Templates.ExecuteTemplate(w, name, data)
+	data, _ := g.TemplateConfigs["completions"].(CompletionsTmplData)
+	return renderFile(g.Templates, w, "completions", data.CONFIGFILE, data)
 }
 
 func (g *generator) Exports(w io.Writer) (err error) {
-	const name = "exports"
-	data := g.TemplateConfigs[name]
-	return g.Templates.ExecuteTemplate(w, name, data)
+	data, _ := g.TemplateConfigs["exports"].(ExportsTmplData)
+	return g.renderExports(w, g.Context.Context, data)
+}
+
+func (g *generator) Aliases(w io.Writer) (err error) {
+	data, _ := g.TemplateConfigs["aliases"].(AliasesTmplData)
+	return renderFile(g.Templates, w, "aliases", data.CONFIGFILE, data)
+}
+
+func (g *generator) Init(w io.Writer) (err error) {
+	data, _ := g.TemplateConfigs["init"].(InitTmplData)
+	return renderFile(g.Templates, w, "init", data.CONFIGFILE, data)
 }
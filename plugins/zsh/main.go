@@ -1,8 +1,10 @@
 package zsh
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 
@@ -59,19 +61,35 @@ func NewZSH(cfg *Config) *ZSH {
 func (z *ZSH) initHandlers() {
 	z.handlers = map[string]commandHandler{
 		"init": func(args []string) (err error) {
-			fmt.Printf("init called. args=%v\n", args)
-			return nil
+			out := z.Config.Out
+			if out == nil {
+				out = os.Stdout
+			}
+			return z.Generator.Init(out)
 		},
 		"gen": func(args []string) (err error) {
 			usage := fmt.Sprint(`
 			USAGE
 				devctl zsh gen <TYPE>
+				devctl zsh gen --check [TYPE]
 
 			EXAMPLES
 				devctl zsh gen completions
 				devctl zsh gen exports
+				devctl zsh gen aliases
+				devctl zsh gen --check
+				devctl zsh gen --check exports
 			`)
 
+			check := false
+			if len(args) > 0 && args[0] == "--check" {
+				check = true
+				args = args[1:]
+			}
+
+			if check && len(args) == 0 {
+				return z.reportDrift(z.generatedFiles()...)
+			}
 			if len(args) == 0 {
 				print(usage)
 				return errors.New("required argument not provided")
@@ -81,37 +99,147 @@ func (z *ZSH) initHandlers() {
 				return errors.New("too many arguments provided")
 			}
 
-			var filepath string
-			logGen := func() {
-				fmt.Printf("generation %s at '%s'\n", args[0], filepath)
+			f, ok := z.generatedFile(args[0])
+			if !ok {
+				print(usage)
+				return nil
 			}
 
-			g := z.Generator
-			var file *os.File
+			if check {
+				return z.reportDrift(f)
+			}
 
-			switch args[0] {
-			case "completions":
-				filepath = z.Config.Pather.Config("zsh", "init.d", "05-completions.zsh")
-				logGen()
-				if file, err = os.OpenFile(filepath, os.O_CREATE|os.O_RDWR, os.ModePerm); err != nil {
-					return err
-				}
+			fmt.Printf("generation %s at '%s'\n", f.name, f.path)
+			return z.generate(f)
+		},
+		"completions": func(args []string) (err error) {
+			usage := fmt.Sprint(`
+			USAGE
+				devctl zsh completions refresh [NAME]
 
-				defer file.Close()
-				return g.Completions(file)
-			case "exports":
-				filepath = z.Config.Pather.Config("zsh", "init.d", "03-exports.zsh")
-				logGen()
-				if file, err = os.Open(filepath); err != nil {
-					return err
-				}
+			EXAMPLES
+				devctl zsh completions refresh
+				devctl zsh completions refresh kubectl
+			`)
 
-				defer file.Close()
-				return g.Exports(file)
-			default:
+			if len(args) == 0 || args[0] != "refresh" {
 				print(usage)
-				return nil
+				return errors.New("required argument not provided")
 			}
+			return z.refreshCompletions(true, args[1:]...)
 		},
+		"diff": func(args []string) (err error) {
+			files := z.generatedFiles()
+			if len(args) > 0 {
+				f, ok := z.generatedFile(args[0])
+				if !ok {
+					return fmt.Errorf("unknown generated file %q", args[0])
+				}
+				files = []generatedFile{f}
+			}
+			return z.reportDrift(files...)
+		},
+	}
+}
+
+// CompletionCache returns the cache of the completion scripts configured in Completions.CLI
+func (z *ZSH) CompletionCache() *CompletionCache {
+	return NewCompletionCache(z.Config.Pather.Config("zsh", "completions"))
+}
+
+func (z *ZSH) refreshCompletions(force bool, names ...string) (err error) {
+	var ctx context.Context
+	if z.Config.Context != nil {
+		ctx = z.Config.Context.Context
+	}
+
+	refreshed, err := z.CompletionCache().Refresh(ctx, z.Config.Completions.CLI, force, names...)
+	for _, name := range refreshed {
+		fmt.Printf("generated completions for %s\n", name)
+	}
+	return err
+}
+
+type generatedFile struct {
+	name   string
+	path   string
+	render func(w io.Writer) error
+}
+
+// generatedFiles returns the files written by 'devctl zsh gen' in the order they get sourced
+func (z *ZSH) generatedFiles() []generatedFile {
+	g := z.Generator
+	path := func(filename string) string {
+		return z.Config.Pather.Config("zsh", "init.d", filename)
+	}
+	return []generatedFile{
+		{name: "exports", path: path("03-exports.zsh"), render: g.Exports},
+		{name: "aliases", path: path("04-aliases.zsh"), render: g.Aliases},
+		{name: "completions", path: path("05-completions.zsh"), render: g.Completions},
+	}
+}
+
+// Generate writes the generated file name, e.g. 'exports', like 'devctl zsh gen NAME'
+func (z *ZSH) Generate(name string) error {
+	f, ok := z.generatedFile(name)
+	if !ok {
+		return fmt.Errorf("unknown generated file %q", name)
+	}
+	return z.generate(f)
+}
+
+func (z *ZSH) generate(f generatedFile) error {
+	if f.name == "completions" {
+		if err := z.refreshCompletions(false); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(f.path, f.render)
+}
+
+func (z *ZSH) generatedFile(name string) (generatedFile, bool) {
+	for _, f := range z.generatedFiles() {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return generatedFile{}, false
+}
+
+// Check compares all generated files on disk with what would be generated from the current config
+func (z *ZSH) Check() (drifts []Drift, err error) {
+	for _, f := range z.generatedFiles() {
+		d, err := checkFile(f.name, f.path, f.render)
+		if err != nil {
+			return drifts, fmt.Errorf("failed to check %s at '%s': %w", f.name, f.path, err)
+		}
+		drifts = append(drifts, d)
+	}
+	return drifts, nil
+}
+
+// reportDrift writes a unified diff for each drifted file and returns ErrDrift if any file drifted
+func (z *ZSH) reportDrift(files ...generatedFile) (err error) {
+	out := z.Config.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	drifted := false
+	for _, f := range files {
+		d, err := checkFile(f.name, f.path, f.render)
+		if err != nil {
+			return fmt.Errorf("failed to check %s at '%s': %w", f.name, f.path, err)
+		}
+		if !d.HasDrift() {
+			continue
+		}
+		drifted = true
+		fmt.Fprintf(out, "%s: %s\n%s", d.Name, d.State, d.Diff)
+	}
+
+	if drifted {
+		return ErrDrift
 	}
+	return nil
 }
@@ -3,18 +3,22 @@ package zsh
 import (
 	"bytes"
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/alex-held/gold"
+	"github.com/stretchr/testify/assert"
+
 	"github.com/alex-held/devctl-kit/pkg/devctlpath"
 	"github.com/alex-held/devctl-kit/pkg/plugins"
 )
 
 func TestInit(t *testing.T) {
 	type vars struct {
-		cmd      string
-		args     []string
-		cfg      Config
-		expected string
+		cmd  string
+		args []string
+		cfg  Config
 	}
 
 	tts := []struct {
@@ -25,8 +29,13 @@ func TestInit(t *testing.T) {
 			name: "init",
 			vars: vars{
 				cmd:  "init",
-				args: []string{""},
+				args: []string{},
 				cfg: Config{
+					Aliases: map[string]string{
+						"k":   "kubectl",
+						"bq":  "bazel query '...' | fzf",
+						"cdg": "cd $GOPATH",
+					},
 					Completions: CompletionsSpec{
 						CLI: map[string]string{
 							"kubectl":       "source <(kubectl completion zsh)",
@@ -46,7 +55,6 @@ func TestInit(t *testing.T) {
 						Context: context.Background(),
 					},
 				},
-				expected: "typeset -A cmds=(\n    [\"golangci-lint\"]=\"completion zsh\"\n    [gh]=\"completion -s zsh\"\n    [npm]=\"completion\"\n    [netlify]=\"completion:generate --shell=zsh\"\n    [ionic]=\"completion\"\n    [kubectl]=\"completion zsh\"\n    [kompose]=\"completion zsh\"\n    [kubebuilder]=\"completion zsh\"\n    [kustomize]=\"completion zsh\"\n    [\"operator-sdk\"]=\"completion zsh\"\n)\nfor k v in ${(kv)cmds}; do\n    [[ ! -f $ZINIT[COMPLETIONS_DIR]/__tabtab.zsh ]] && \\\n        eval \"$k $v\" > \"$ZINIT[COMPLETIONS_DIR]/_$k\" || true\n    source \"$ZINIT[COMPLETIONS_DIR]/_$k\"\ndone\nunset cmds\n",
 			},
 		},
 	}
@@ -55,6 +63,47 @@ func TestInit(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			out := &bytes.Buffer{}
 			tt.vars.cfg.Out = out
+
+			err := Exec(&tt.vars.cfg, append([]string{tt.vars.cmd}, tt.vars.args...))
+			assert.NoError(t, err)
+
+			g := gold.New(t)
+			g.Assert(t, "init", out.Bytes())
 		})
 	}
 }
+
+func TestGenCheck(t *testing.T) {
+	root := t.TempDir()
+	out := &bytes.Buffer{}
+	cfg := &Config{
+		Aliases: map[string]string{"ll": "ls -la"},
+		Context: &plugins.Context{
+			Out: out,
+			Pather: devctlpath.NewPather(devctlpath.WithConfigRootFn(func() string {
+				return root
+			})),
+			Context: context.Background(),
+		},
+	}
+
+	err := Exec(cfg, []string{"gen", "--check"})
+	assert.ErrorIs(t, err, ErrDrift)
+	assert.Contains(t, out.String(), "exports: missing")
+	assert.Contains(t, out.String(), "aliases: missing")
+	assert.Contains(t, out.String(), "+alias ll='ls -la'")
+
+	assert.NoError(t, Exec(cfg, []string{"gen", "exports"}))
+	assert.NoError(t, Exec(cfg, []string{"gen", "aliases"}))
+	assert.NoError(t, Exec(cfg, []string{"gen", "completions"}))
+	out.Reset()
+	assert.NoError(t, Exec(cfg, []string{"gen", "--check"}))
+	assert.Empty(t, out.String())
+
+	aliases := filepath.Join(root, "config", "zsh", "init.d", "04-aliases.zsh")
+	assert.FileExists(t, aliases)
+	assert.NoError(t, os.Remove(aliases))
+	assert.ErrorIs(t, Exec(cfg, []string{"gen", "--check"}), ErrDrift)
+	assert.NotContains(t, out.String(), "exports:")
+	assert.Contains(t, out.String(), "aliases: missing")
+}
@@ -3,6 +3,7 @@ package zsh
 import (
 	"bytes"
 	_ "embed"
+	"io"
 	"strings"
 	"text/template"
 
@@ -12,40 +13,121 @@ import (
 //go:embed "templates/completions.tmpl"
 var completionsTemplateString string
 
+//go:embed "templates/aliases.tmpl"
+var aliasesTemplateString string
+
+//go:embed "templates/aliasdefs.tmpl"
+var aliasDefsTemplateString string
+
+//go:embed "templates/exports.tmpl"
+var exportsTemplateString string
+
+//go:embed "templates/init.tmpl"
+var initTemplateString string
+
 //go:embed "testdata/completions_test"
 var completionsExpectedString string
 
-var fileHeaderTemplateString = "{{ define \"fileheader\" }}#!/usr/bin/env zsh\n#\n# - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -\n# DO NOT MODIFY - AUTOGENERATED by github.com/alex-held/devctl\n# CONFIGURE USING {{ .CONFIGFILE }}\n# - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -\n{{ end }}"
+var fileHeaderTemplateString = "{{ define \"fileheader\" }}#!/usr/bin/env zsh\n#\n# - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -\n# DO NOT MODIFY - AUTOGENERATED by github.com/alex-held/devctl\n# CONFIGURE USING {{ .CONFIGFILE }}\n# CONTENT HASH {{ .HASH }}\n# - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -\n{{ end }}"
 
 type FileHeaderTmplData struct {
 	CONFIGFILE string
+	HASH       string
 }
 
 type CompletionsTmplData struct {
-	FileHeader      FileHeaderTmplData
 	Header          string
 	CONFIGFILE      string
 	COMPLETIONS_DIR string
 	Completions     CompletionsSpec
 }
 
+type AliasesTmplData struct {
+	Header     string
+	CONFIGFILE string
+	Aliases    map[string]string
+}
+
+type ExportsTmplData struct {
+	Header         string
+	CONFIGFILE     string
+	Vars           map[string]string
+	Exports        map[string]string
+	DeferExpansion bool
+
+	// Resolved contains the exports in the order of their dependencies
+	Resolved []Export
+}
+
+type InitTmplData struct {
+	CONFIGFILE      string
+	INIT_DIR        string
+	BIN_PATH        string
+	COMPLETIONS_DIR string
+	Aliases         map[string]string
+}
+
 var commonTemplates = map[string]*template.Template{}
 var tmpls = map[string]string{
 	"fileheader":  fileHeaderTemplateString,
 	"completions": completionsTemplateString,
+	"aliases":     aliasesTemplateString,
+	"aliasdefs":   aliasDefsTemplateString,
+	"exports":     exportsTemplateString,
+	"init":        initTemplateString,
+}
+
+var templateFuncs = template.FuncMap{
+	"squote": squote,
+	"dquote": dquote,
 }
 
-var templates = template.New("")
+var templates = template.New("").Funcs(templateFuncs)
 
 func init() {
 	templates, _ = templates.New("fileheader").Parse(fileHeaderTemplateString)
 	templates, _ = templates.New("completions").Parse(completionsTemplateString)
+	templates, _ = templates.New("aliasdefs").Parse(aliasDefsTemplateString)
+	templates, _ = templates.New("aliases").Parse(aliasesTemplateString)
+	templates, _ = templates.New("exports").Parse(exportsTemplateString)
+	templates, _ = templates.New("init").Parse(initTemplateString)
+}
+
+// renderFile renders the named template and prepends the fileheader,
+// which contains the ContentHash of the rendered content
+func renderFile(t *template.Template, w io.Writer, name, configFile string, data interface{}) (err error) {
+	body := &bytes.Buffer{}
+	if err = t.ExecuteTemplate(body, name, data); err != nil {
+		return err
+	}
+
+	header := FileHeaderTmplData{
+		CONFIGFILE: configFile,
+		HASH:       ContentHash(body.Bytes()),
+	}
+	if err = t.ExecuteTemplate(w, "fileheader", header); err != nil {
+		return err
+	}
+	_, err = w.Write(body.Bytes())
+	return err
+}
+
+// squote wraps s in single quotes, so that the shell does not expand its content
+func squote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dquote wraps s in double quotes, so that the shell still expands the variables in s.
+// Escaped dollars ($$) are kept literally.
+func dquote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`").Replace(s)
+	return `"` + strings.ReplaceAll(s, "$$", `\$`) + `"`
 }
 
 func (t CompletionsTmplData) Render() (s string, err error) {
 	b := &bytes.Buffer{}
 
-	if err := templates.ExecuteTemplate(b, "completions", &t); err != nil {
+	if err := renderFile(templates, b, "completions", t.CONFIGFILE, &t); err != nil {
 		return "", err
 	}
 
@@ -56,3 +138,33 @@ func (t CompletionsTmplData) Render() (s string, err error) {
 
 	return s, nil
 }
+
+func (t AliasesTmplData) Render() (s string, err error) {
+	b := &bytes.Buffer{}
+
+	if err := renderFile(templates, b, "aliases", t.CONFIGFILE, &t); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+func (t ExportsTmplData) Render() (s string, err error) {
+	b := &bytes.Buffer{}
+
+	if err := renderFile(templates, b, "exports", t.CONFIGFILE, &t); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+func (t InitTmplData) Render() (s string, err error) {
+	b := &bytes.Buffer{}
+
+	if err := renderFile(templates, b, "init", t.CONFIGFILE, &t); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
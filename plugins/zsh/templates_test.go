@@ -10,8 +10,6 @@ import (
 	"github.com/alex-held/devctl-kit/pkg/plugins"
 
 	"github.com/alex-held/devctl-kit/pkg/generation/banner"
-
-	"github.com/alex-held/devctl-kit/pkg/devctlpath"
 )
 
 var config = Config{
@@ -59,13 +57,11 @@ func TestGenerateExports(t *testing.T) {
 }
 
 func TestCompletionsTempl(t *testing.T) {
-	completionsDir := devctlpath.DevCtlConfigRoot("configs", "zsh", "completions")
-	configFile := devctlpath.DevCtlConfigRoot("configs", "zsh", "completions.yaml")
+	completionsDir := "/Users/dev/.devctl/configs/zsh/completions"
+	configFile := "/Users/dev/.devctl/configs/zsh/completions.yaml"
 
 	in := CompletionsTmplData{
-		FileHeader: FileHeaderTmplData{
-			CONFIGFILE: configFile,
-		},
+		CONFIGFILE:      configFile,
 		Header:          banner.GenerateBanner("Completions", banner.KIND_SHELL),
 		COMPLETIONS_DIR: completionsDir,
 		Completions: CompletionsSpec{
@@ -93,3 +89,51 @@ func TestCompletionsTempl(t *testing.T) {
 	g := gold.New(t)
 	g.Assert(t, "render", []byte(actual))
 }
+
+func TestAliasesTempl(t *testing.T) {
+	in := AliasesTmplData{
+		Header:     banner.GenerateBanner("Aliases", banner.KIND_SHELL),
+		CONFIGFILE: "/home/user/.devctl/config/zsh/config.yaml",
+		Aliases:    config.Aliases,
+	}
+
+	actual, err := in.Render()
+	assert.NoError(t, err)
+
+	g := gold.New(t)
+	g.Assert(t, "render", []byte(actual))
+}
+
+func TestInitTempl(t *testing.T) {
+	in := InitTmplData{
+		CONFIGFILE:      "/home/user/.devctl/config/zsh/config.yaml",
+		INIT_DIR:        "/home/user/.devctl/config/zsh/init.d",
+		BIN_PATH:        "/home/user/.devctl/bin",
+		COMPLETIONS_DIR: "/home/user/.devctl/config/zsh/completions",
+		Aliases:         config.Aliases,
+	}
+
+	actual, err := in.Render()
+	assert.NoError(t, err)
+
+	g := gold.New(t)
+	g.Assert(t, "render", []byte(actual))
+}
+
+func TestExportsTempl(t *testing.T) {
+	in := ExportsTmplData{
+		Header:     banner.GenerateBanner("Exports", banner.KIND_SHELL),
+		CONFIGFILE: "/home/user/.devctl/config/zsh/config.yaml",
+		Resolved: []Export{
+			{Key: "DEVCTL_ROOT", Value: "/home/user/.devctl"},
+			{Key: "GOROOT", Value: "$DEVCTL_ROOT/sdks/go/current"},
+			{Key: "QUOTED", Value: "say \"hi\" to $$USER"},
+		},
+	}
+
+	actual, err := in.Render()
+	assert.NoError(t, err)
+
+	g := gold.New(t)
+	g.Assert(t, "render", []byte(actual))
+}